@@ -1,5 +1,27 @@
 package api
 
+const LUA_MINSTACK = 20
+const LUAI_MAXSTACK = 1000000
+
+// pseudo-indices
+const LUA_REGISTRYINDEX = -LUAI_MAXSTACK - 1000
+
+/* predefined values in the registry */
+const (
+	LUA_RIDX_MAINTHREAD int64 = 1
+	LUA_RIDX_GLOBALS    int64 = 2
+	LUA_RIDX_LAST             = LUA_RIDX_GLOBALS
+)
+
+/* predefined references */
+const (
+	LUA_NOREF  = -2
+	LUA_REFNIL = -1
+)
+
+// option for multiple returns in 'Call'
+const LUA_MULTRET = -1
+
 const (
 	LUA_TNONE = iota - 1 // -1
 	LUA_TNIL
@@ -12,3 +34,39 @@ const (
 	LUA_TUSERDATA
 	LUA_TTHREAD
 )
+
+/* thread status */
+const (
+	LUA_OK = iota
+	LUA_YIELD
+	LUA_ERRRUN
+	LUA_ERRSYNTAX
+	LUA_ERRMEM // 拒绝过大的分配请求，Go 中真正的内存耗尽无法恢复
+	LUA_ERRGCMM
+	LUA_ERRERR
+	LUA_ERRFILE // lauxlib: 打不开要加载的文件
+)
+
+// 与 lua.h 中 LUA_OPADD ... LUA_OPBNOT 的顺序一致，也和 OP_ADD ... OP_BNOT 指令顺序一致
+const (
+	LUA_OPADD  = iota // +
+	LUA_OPSUB         // -
+	LUA_OPMUL         // *
+	LUA_OPMOD         // %
+	LUA_OPPOW         // ^
+	LUA_OPDIV         // /
+	LUA_OPIDIV        // //
+	LUA_OPBAND        // &
+	LUA_OPBOR         // |
+	LUA_OPBXOR        // ~
+	LUA_OPSHL         // <<
+	LUA_OPSHR         // >>
+	LUA_OPUNM         // - (unary)
+	LUA_OPBNOT        // ~ (unary)
+)
+
+const (
+	LUA_OPEQ = iota // ==
+	LUA_OPLT        // <
+	LUA_OPLE        // <=
+)
@@ -0,0 +1,18 @@
+package api
+
+type LuaVM interface {
+	LuaStateI
+	PC() int                 // 返回当前 PC（仅测试用）
+	AddPC(n int)             // 修改 PC（用于实现跳转指令）
+	Fetch() uint32           // 取出当前指令，并将 PC 指向下一条指令
+	GetConst(idx int)        // 将指定常量推入栈顶
+	GetRK(rk int)            // 将指定常量或寄存器的值推入栈顶
+	RegisterCount() int      // 当前函数使用的寄存器数量
+	LoadVararg(n int)        // 将 n 个变长参数推入栈顶，n < 0 时推入全部
+	LoadProto(idx int)       // 将子函数原型实例化为闭包推入栈顶
+	TailCall(nArgs int) bool // 尾调用栈顶的函数，复用了当前调用帧时返回 true
+	LoadUpvalue(idx int)     // 将当前闭包的 upvalue 推入栈顶
+	StoreUpvalue(idx int)    // 弹出栈顶值赋给当前闭包的 upvalue
+	CloseUpvalues(a int)     // 关闭 R(a-1) 及其之上的寄存器对应的 upvalue
+	RunError(msg string)     // 抛出带有当前指令位置信息的运行时错误
+}
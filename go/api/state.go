@@ -1,9 +1,19 @@
 package api
 
+import "io"
+
 type LuaType = int
 type ArithOp = int
 type CompareOp = int
 
+type GoFunction func(LuaStateI) int
+
+// lua: lua_upvalueindex
+// 当前 Go 闭包第 i 个 upvalue 的伪索引，i 从 1 开始
+func LuaUpvalueIndex(i int) int {
+	return LUA_REGISTRYINDEX - i
+}
+
 type LuaStateI interface {
 	/* basic stack manipulation */
 	GetTop() int
@@ -29,7 +39,9 @@ type LuaStateI interface {
 	IsString(idx int) bool
 	IsTable(idx int) bool
 	IsThread(idx int) bool
+	IsUserdata(idx int) bool
 	IsFunction(idx int) bool
+	IsGoFunction(idx int) bool
 	ToBoolean(idx int) bool
 	ToInteger(idx int) int64
 	ToIntegerX(idx int) (int64, bool)
@@ -37,16 +49,88 @@ type LuaStateI interface {
 	ToNumberX(idx int) (float64, bool)
 	ToString(idx int) string
 	ToStringX(idx int) (string, bool)
+	ToGoFunction(idx int) GoFunction
+	ToThread(idx int) LuaStateI
+	ToUserdata(idx int) interface{}
 	/* push functions (Go -> stack) */
 	PushNil()
 	PushBoolean(b bool)
 	PushInteger(n int64)
 	PushNumber(n float64)
 	PushString(s string)
+	PushGoFunction(f GoFunction)
+	PushGoClosure(f GoFunction, n int)
+	PushGlobalTable()
+	PushThread() bool
+	NewUserdata(value interface{})
+	PushLightUserdata(p interface{})
+	/* 'load' and 'call' functions */
+	Load(chunk []byte, chunkName, mode string) int
+	Dump(w io.Writer, strip bool) error
+	Call(nArgs, nResults int)
+	PCall(nArgs, nResults, msgh int) int
+	ProtectedCall(nArgs, nResults int) error
+	/* coroutine functions */
+	NewThread() LuaStateI
+	Resume(from LuaStateI, nArgs int) int
+	CloseThread(from LuaStateI) int
+	Yield(nResults int) int
+	Status() int
+	IsYieldable() bool
+	GetStack() bool
+	XMove(to LuaStateI, n int)
+	/* get functions (Lua -> stack) */
+	NewTable()
+	CreateTable(nArr, nRec int)
+	GetTable(idx int) LuaType
+	GetField(idx int, k string) LuaType
+	GetI(idx int, i int64) LuaType
+	RawGet(idx int) LuaType
+	RawGetI(idx int, i int64) LuaType
+	GetMetatable(idx int) bool
+	GetGlobal(name string) LuaType
+	GetUserValue(idx int) LuaType
+	/* set functions (stack -> Lua) */
+	SetTable(idx int)
+	SetField(idx int, k string)
+	SetI(idx int, i int64)
+	RawSet(idx int)
+	RawSetI(idx int, i int64)
+	SetMetatable(idx int)
+	SetGlobal(name string)
+	Register(name string, f GoFunction)
+	SetUserValue(idx int)
 
 	// append
 	Arith(op ArithOp)
 	Compare(idx1, idx2 int, op CompareOp) bool
+	RawEqual(idx1, idx2 int) bool
+	Error() int
 	Len(idx int)
 	Concat(n int)
+	RawLen(idx int) uint
+	Next(idx int) bool
+	StringToNumber(s string) int
+	/* debug API */
+	GetUpvalue(funcIdx, n int) (string, bool)
+	SetUpvalue(funcIdx, n int) (string, bool)
+	UpvalueID(funcIdx, n int) interface{}
+	UpvalueJoin(funcIdx1, n1, funcIdx2, n2 int)
+	/* auxiliary library */
+	ToString2(idx int) string
+	Where(level int)
+	Error2(format string, a ...interface{}) int
+	ArgError(arg int, extraMsg string) int
+	ArgCheck(cond bool, arg int, extraMsg string)
+	NewMetatable(tname string) bool
+	GetMetatable2(tname string) LuaType
+	SetMetatable2(tname string)
+	TestUdata(arg int, tname string) interface{}
+	CheckUdata(arg int, tname string) interface{}
+	Ref(t int) int
+	Unref(t, ref int)
+	LoadString(s string) error
+	LoadFile(filename string) error
+	DoString(s string) error
+	DoFile(filename string) error
 }
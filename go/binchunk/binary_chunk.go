@@ -2,9 +2,6 @@ package binchunk
 
 import (
 	"encoding/binary"
-	"errors"
-	"log"
-	"math"
 	"os"
 )
 
@@ -45,11 +42,13 @@ type Prototype struct {
 	LineInfo        []uint32     // 行号表，和指令表一一对应
 	LocVars         []LocVar     // 局部变量，变量名+起止指令索引
 	UpvalueNames    []string     // Upvalue名列表，和Upvalues一一对应
+	Version         byte         // 解析出来的二进制chunk的版本，LUAC_VERSION、LUAC_VERSION54 或 LUAC_VERSION51
 }
 
 type Upvalue struct {
 	Instack byte
 	Idx     byte
+	Kind    byte // lua 5.4 中变量的种类（普通、const、to-be-closed），5.3 中为 0
 }
 
 type LocVar struct {
@@ -60,180 +59,221 @@ type LocVar struct {
 
 type reader struct {
 	data []byte
+	pos  int // 下一个字节的偏移
+	Format
 }
 
-func (r *reader) readByte() byte {
-	b := r.data[0]
-	r.data = r.data[1:]
-	return b
+// 抛出 *ChunkError，由 ParseChunk 恢复
+func (self *reader) error(err error, offset int, field string) {
+	panic(&ChunkError{Err: err, Offset: offset, Field: field})
 }
 
-func (r *reader) readUint32() uint32 {
-	ret := binary.LittleEndian.Uint32(r.data)
-	r.data = r.data[4:]
+func (self *reader) readBytes(n uint64, field string) []byte {
+	if n > uint64(len(self.data)-self.pos) {
+		self.error(ErrTruncated, self.pos, field)
+	}
+	ret := self.data[self.pos : self.pos+int(n)]
+	self.pos += int(n)
 	return ret
 }
 
-func (r *reader) readUint64() uint64 {
-	ret := binary.LittleEndian.Uint64(r.data)
-	r.data = r.data[8:]
-	return ret
+func (self *reader) readByte(field string) byte {
+	return self.readBytes(1, field)[0]
+}
+
+func (self *reader) readInstruction(field string) uint32 {
+	return uint32(self.getUint(self.readBytes(INSTRUCTION_SIZE, field)))
+}
+
+// C 的 int，用于长度和行号
+func (self *reader) readInt(field string) uint64 {
+	return self.getUint(self.readBytes(uint64(self.IntSize), field))
 }
 
-func (r *reader) readLuaInteger() int64 {
-	return int64(r.readUint64())
+func (self *reader) readSizet(field string) uint64 {
+	return self.getUint(self.readBytes(uint64(self.SizetSize), field))
 }
 
-func (r *reader) readLuaNumber() float64 {
-	return math.Float64frombits(r.readUint64())
+func (self *reader) readLuaInteger(field string) int64 {
+	return self.getInteger(self.readBytes(uint64(self.IntegerSize), field))
 }
 
-func (r *reader) readString() string {
-	size := uint(r.readByte())
+func (self *reader) readLuaNumber(field string) float64 {
+	return self.getNumber(self.readBytes(uint64(self.NumberSize), field))
+}
+
+// lua: LoadString
+// 长度 + 1 后存储，0 表示空串（NULL），超过 0xfe 时 0xff 后面跟 size_t 长度
+func (self *reader) readString(field string) string {
+	size := uint64(self.readByte(field))
 	if size == 0 {
 		return ""
 	} else if size == 0xff { // long string
-		size = uint(r.readUint64())
+		size = self.readSizet(field)
+		if size == 0 {
+			self.error(ErrCorrupted, self.pos-self.SizetSize, field)
+		}
 	}
-	bytes := r.readBytes(size - 1)
-	return string(bytes)
+	return string(self.readBytes(size-1, field))
 }
 
-func (r *reader) readBytes(n uint) []byte {
-	ret := r.data[:n]
-	r.data = r.data[n:]
-	return ret
+// 读取数组长度，剩下的数据放不下 n 个至少 minSize 字节的元素时报告 chunk 被截断，
+// 避免按伪造的长度分配内存
+func (self *reader) readCount(minSize int, field string) int {
+	offset := self.pos
+	return self.checkCount(self.readInt(field), minSize, offset, field)
+}
+
+func (self *reader) checkCount(n uint64, minSize, offset int, field string) int {
+	if n > uint64(len(self.data)-self.pos)/uint64(minSize) {
+		self.error(ErrTruncated, offset, field)
+	}
+	return int(n)
 }
 
-func (r *reader) readCode() []uint32 {
-	code := make([]uint32, r.readUint32())
+func (self *reader) readCode() []uint32 {
+	code := make([]uint32, self.readCount(INSTRUCTION_SIZE, "code"))
 	for i := range code {
-		code[i] = r.readUint32()
+		code[i] = self.readInstruction("code")
 	}
 	return code
 }
 
-func (r *reader) readConstant() interface{} {
-	switch r.readByte() {
+func (self *reader) readConstant() interface{} {
+	offset := self.pos
+	switch self.readByte("constant") {
 	case TAG_NIL:
 		return nil
 	case TAG_BOOLEAN:
-		return r.readByte() != 0
+		return self.readByte("constant") != 0
 	case TAG_INTEGER:
-		return r.readLuaInteger()
+		return self.readLuaInteger("constant")
 	case TAG_NUMBER:
-		return r.readLuaNumber()
+		return self.readLuaNumber("constant")
 	case TAG_SHORT_STR, TAG_LONG_STR:
-		return r.readString()
+		return self.readString("constant")
 	default:
-		panic("corrupted!")
+		self.error(ErrBadTag, offset, "constant")
+		return nil
+	}
+}
+
+func (self *reader) readConstants() []interface{} {
+	constants := make([]interface{}, self.readCount(1, "constants"))
+	for i := range constants {
+		constants[i] = self.readConstant()
 	}
+	return constants
 }
 
-func (r *reader) readUpvalues() []Upvalue {
-	upvalues := make([]Upvalue, r.readUint32())
+func (self *reader) readUpvalues() []Upvalue {
+	upvalues := make([]Upvalue, self.readCount(2, "upvalues"))
 	for i := range upvalues {
 		upvalues[i] = Upvalue{
-			Instack: r.readByte(),
-			Idx:     r.readByte(),
+			Instack: self.readByte("upvalues"),
+			Idx:     self.readByte("upvalues"),
 		}
 	}
 	return upvalues
 }
 
-func (r *reader) readProtos(parentSource string) []*Prototype {
-	protos := make([]*Prototype, r.readUint32())
+func (self *reader) readProtos(parentSource string) []*Prototype {
+	protos := make([]*Prototype, self.readCount(self.protoMinSize(), "protos"))
 	for i := range protos {
-		protos[i] = r.readProto(parentSource)
+		protos[i] = self.readProto(parentSource)
 	}
 	return protos
 }
 
 func (self *reader) readLineInfo() []uint32 {
-	lineInfo := make([]uint32, self.readUint32())
+	lineInfo := make([]uint32, self.readCount(self.IntSize, "lineinfo"))
 	for i := range lineInfo {
-		lineInfo[i] = self.readUint32()
+		lineInfo[i] = uint32(self.readInt("lineinfo"))
 	}
 	return lineInfo
 }
 
 func (self *reader) readLocVars() []LocVar {
-	locVars := make([]LocVar, self.readUint32())
+	locVars := make([]LocVar, self.readCount(1+2*self.IntSize, "locvars"))
 	for i := range locVars {
 		locVars[i] = LocVar{
-			VarName: self.readString(),
-			StartPC: self.readUint32(),
-			EndPC:   self.readUint32(),
+			VarName: self.readString("locvars"),
+			StartPC: uint32(self.readInt("locvars")),
+			EndPC:   uint32(self.readInt("locvars")),
 		}
 	}
 	return locVars
 }
 
 func (self *reader) readUpvalueNames() []string {
-	names := make([]string, self.readUint32())
+	names := make([]string, self.readCount(1, "upvalue names"))
 	for i := range names {
-		names[i] = self.readString()
+		names[i] = self.readString("upvalue names")
 	}
 	return names
 }
 
-func (r *reader) readConstants() []interface{} {
-	constants := make([]interface{}, r.readUint32())
-	for i := range constants {
-		constants[i] = r.readConstant()
+func (self *reader) check(ok bool, err error, offset int, field string) {
+	if !ok {
+		self.error(err, offset, field)
 	}
-	return constants
 }
 
-func (r *reader) checkHeader() error {
-	if string(r.readBytes(4)) != LUA_SIGNATURE {
-		return errors.New("signature")
-	}
-	if r.readByte() != LUAC_VERSION {
-		return errors.New("version")
-	}
-	if r.readByte() != LUAC_FORMAT {
-		return errors.New("format")
-	}
-	if string(r.readBytes(6)) != LUAC_DATA {
-		return errors.New("corrupted")
-	}
-	if r.readByte() != CINT_SIZE {
-		return errors.New("int size")
-	}
-	if r.readByte() != CSIZET_SIZE {
-		return errors.New("sizet size")
-	}
-	if r.readByte() != INSTRUCTION_SIZE {
-		return errors.New("instruction size")
-	}
-	if r.readByte() != LUA_INTEGER_SIZE {
-		return errors.New("lua integer size")
-	}
-	if r.readByte() != LUA_NUMBER_SIZE {
-		return errors.New("lua number size")
-	}
-	if r.readLuaInteger() != LUAC_INT {
-		return errors.New("luac int")
-	}
-	if r.readLuaNumber() != LUAC_NUM {
-		return errors.New("luac num")
+// 读取签名和版本号
+func (self *reader) readVersion() byte {
+	self.check(string(self.readBytes(4, "signature")) == LUA_SIGNATURE, ErrBadSignature, 0, "signature")
+	return self.readByte("version")
+}
+
+// lua: checkHeader
+// 读取头部记录的各种长度，再根据 LUAC_INT 判断字节序
+func (self *reader) checkHeader() {
+	self.check(self.readByte("format") == LUAC_FORMAT, ErrFormatMismatch, 5, "format")
+	self.check(string(self.readBytes(6, "LUAC_DATA")) == LUAC_DATA, ErrCorrupted, 6, "LUAC_DATA")
+
+	self.IntSize = self.readSize("int size")
+	self.SizetSize = self.readSize("size_t size")
+	self.check(self.readByte("Instruction size") == INSTRUCTION_SIZE, ErrSizeMismatch, 14, "Instruction size")
+	self.IntegerSize = self.readSize("lua_Integer size")
+	self.NumberSize = self.readSize("lua_Number size")
+	self.checkLuacIntNum()
+}
+
+func (self *reader) readSize(field string) int {
+	n := int(self.readByte(field))
+	self.check(validSize(n), ErrSizeMismatch, self.pos-1, field)
+	return n
+}
+
+// 头部最后的 LUAC_INT 和 LUAC_NUM 用来确定字节序和数值格式
+func (self *reader) checkLuacIntNum() {
+	offset := self.pos
+	luacInt := self.readBytes(uint64(self.IntegerSize), "LUAC_INT")
+	if self.ByteOrder = binary.LittleEndian; self.getInteger(luacInt) != LUAC_INT {
+		self.ByteOrder = binary.BigEndian
+		self.check(self.getInteger(luacInt) == LUAC_INT, ErrEndianMismatch, offset, "LUAC_INT")
 	}
-	return nil
+	offset = self.pos
+	self.check(self.readLuaNumber("LUAC_NUM") == LUAC_NUM, ErrSizeMismatch, offset, "LUAC_NUM")
+}
+
+// 最小的函数原型：空的 source，两个行号，三个字节，7 个空数组
+func (self *reader) protoMinSize() int {
+	return 1 + 2*self.IntSize + 3 + 7*self.IntSize
 }
 
 func (self *reader) readProto(parentSource string) *Prototype {
-	source := self.readString()
+	source := self.readString("source")
 	if source == "" {
 		source = parentSource
 	}
 	return &Prototype{
 		Source:          source,
-		LineDefined:     self.readUint32(),
-		LastLineDefined: self.readUint32(),
-		NumParams:       self.readByte(),
-		IsVararg:        self.readByte(),
-		MaxStackSize:    self.readByte(),
+		LineDefined:     uint32(self.readInt("linedefined")),
+		LastLineDefined: uint32(self.readInt("lastlinedefined")),
+		NumParams:       self.readByte("numparams"),
+		IsVararg:        self.readByte("is_vararg"),
+		MaxStackSize:    self.readByte("maxstacksize"),
 		Code:            self.readCode(),
 		Constants:       self.readConstants(),
 		Upvalues:        self.readUpvalues(),
@@ -241,32 +281,47 @@ func (self *reader) readProto(parentSource string) *Prototype {
 		LineInfo:        self.readLineInfo(),
 		LocVars:         self.readLocVars(),
 		UpvalueNames:    self.readUpvalueNames(),
+		Version:         LUAC_VERSION,
 	}
 }
 
-func ParseChunk(s string) *Prototype {
-	rd := &reader{
-		data: []byte(s),
-	}
-	err := rd.checkHeader()
-	if err != nil {
-		log.Fatal(err)
+// lua: luaU_undump
+// ParseChunk 解析二进制 chunk，chunk 格式不对或者被截断时返回 *ChunkError，
+// 对任何输入都不会 panic
+func ParseChunk(data []byte) (proto *Prototype, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			chunkErr, ok := r.(*ChunkError)
+			if !ok {
+				panic(r)
+			}
+			proto, err = nil, chunkErr
+		}
+	}()
+
+	rd := &reader{data: data}
+	switch rd.readVersion() {
+	case LUAC_VERSION:
+		rd.checkHeader()
+		rd.readByte("sizeupvalues")
+		return rd.readProto(""), nil
+	case LUAC_VERSION54:
+		rd.checkHeader54()
+		rd.readByte("sizeupvalues")
+		return rd.readProto54(""), nil
+	case LUAC_VERSION51:
+		rd.checkHeader51()
+		return rd.readProto51(""), nil
+	default:
+		rd.error(ErrVersionMismatch, 4, "version")
+		return nil, nil
 	}
-	rd.readByte()
-	return rd.readProto("")
 }
 
-func ParseChunkFile(path string) *Prototype {
+func ParseChunkFile(path string) (*Prototype, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	return ParseChunk(string(b))
+	return ParseChunk(b)
 }
-
-func Cmd() {
-	ParseChunkFile("./lua/luac.out")
-}
-
-// ANCCY TODO
-// A Luac parser
@@ -0,0 +1,123 @@
+package binchunk
+
+import "encoding/binary"
+
+// lua 5.1: lundump.c
+// 5.1 的头部直接记录字节序，没有 LUAC_DATA、LUAC_INT 和 LUAC_NUM；
+// 字符串的长度是 size_t，包括末尾的 '\0'。函数原型里没有 upvalue 描述，
+// 只有 upvalue 的个数，子函数原型紧跟在常量后面
+
+// lua 5.1: LoadHeader
+func (self *reader) checkHeader51() {
+	self.check(self.readByte("format") == LUAC_FORMAT, ErrFormatMismatch, 5, "format")
+	switch self.readByte("endianness") {
+	case 0:
+		self.ByteOrder = binary.BigEndian
+	case 1:
+		self.ByteOrder = binary.LittleEndian
+	default:
+		self.error(ErrCorrupted, 6, "endianness")
+	}
+	self.IntSize = self.readSize("int size")
+	self.SizetSize = self.readSize("size_t size")
+	self.check(self.readByte("Instruction size") == INSTRUCTION_SIZE, ErrSizeMismatch, 9, "Instruction size")
+	self.NumberSize = self.readSize("lua_Number size")
+	switch self.readByte("integral flag") {
+	case 0:
+	case 1: // lua_Number 是整数类型
+		self.IntegerSize = self.NumberSize
+	default:
+		self.error(ErrCorrupted, 11, "integral flag")
+	}
+}
+
+// lua 5.1: LoadString
+func (self *reader) readString51(field string) string {
+	size := self.readSizet(field)
+	if size == 0 {
+		return ""
+	}
+	return string(self.readBytes(size, field)[:size-1])
+}
+
+// lua_Number 是整数类型时读出 int64，否则读出 float64
+func (self *reader) readNumber51(field string) interface{} {
+	if self.IntegerSize > 0 {
+		return self.readLuaInteger(field)
+	}
+	return self.readLuaNumber(field)
+}
+
+// lua 5.1: LoadConstants
+// 常量后面是子函数原型
+func (self *reader) readConstants51(source string) ([]interface{}, []*Prototype) {
+	constants := make([]interface{}, self.readCount(1, "constants"))
+	for i := range constants {
+		offset := self.pos
+		switch self.readByte("constant") {
+		case TAG_NIL:
+			constants[i] = nil
+		case TAG_BOOLEAN:
+			constants[i] = self.readByte("constant") != 0
+		case TAG_NUMBER:
+			constants[i] = self.readNumber51("constant")
+		case TAG_SHORT_STR:
+			constants[i] = self.readString51("constant")
+		default:
+			self.error(ErrBadTag, offset, "constant")
+		}
+	}
+
+	// 最小的函数原型：空的 source，两个行号，四个字节，6 个空数组
+	minSize := self.SizetSize + 2*self.IntSize + 4 + 6*self.IntSize
+	protos := make([]*Prototype, self.readCount(minSize, "protos"))
+	for i := range protos {
+		protos[i] = self.readProto51(source)
+	}
+	return constants, protos
+}
+
+func (self *reader) readLocVars51() []LocVar {
+	locVars := make([]LocVar, self.readCount(self.SizetSize+2*self.IntSize, "locvars"))
+	for i := range locVars {
+		locVars[i] = LocVar{
+			VarName: self.readString51("locvars"),
+			StartPC: uint32(self.readInt("locvars")),
+			EndPC:   uint32(self.readInt("locvars")),
+		}
+	}
+	return locVars
+}
+
+func (self *reader) readUpvalueNames51() []string {
+	names := make([]string, self.readCount(self.SizetSize, "upvalue names"))
+	for i := range names {
+		names[i] = self.readString51("upvalue names")
+	}
+	return names
+}
+
+// lua 5.1: LoadFunction
+// 5.1 没有 upvalue 描述，Upvalues 只有个数是有效的
+func (self *reader) readProto51(parentSource string) *Prototype {
+	source := self.readString51("source")
+	if source == "" {
+		source = parentSource
+	}
+	proto := &Prototype{
+		Source:          source,
+		LineDefined:     uint32(self.readInt("linedefined")),
+		LastLineDefined: uint32(self.readInt("lastlinedefined")),
+		Upvalues:        make([]Upvalue, self.readByte("nups")),
+		NumParams:       self.readByte("numparams"),
+		IsVararg:        self.readByte("is_vararg"),
+		MaxStackSize:    self.readByte("maxstacksize"),
+		Code:            self.readCode(),
+		Version:         LUAC_VERSION51,
+	}
+	proto.Constants, proto.Protos = self.readConstants51(source)
+	proto.LineInfo = self.readLineInfo()
+	proto.LocVars = self.readLocVars51()
+	proto.UpvalueNames = self.readUpvalueNames51()
+	return proto
+}
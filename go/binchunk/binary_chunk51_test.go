@@ -0,0 +1,135 @@
+package binchunk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// 按 lua 5.1 ldump.c 的格式拼出二进制 chunk，int 4 字节，size_t 8 字节
+type chunk51 struct {
+	bytes.Buffer
+	order binary.ByteOrder
+}
+
+func (self *chunk51) int(n int) {
+	binary.Write(self, self.order, int32(n))
+}
+
+func (self *chunk51) str(s string) {
+	binary.Write(self, self.order, uint64(len(s)+1))
+	self.WriteString(s)
+	self.WriteByte(0)
+}
+
+// function f(a) end
+// print("hello world!", 0.5, true)
+func helloWorld51(order binary.ByteOrder) []byte {
+	c := &chunk51{order: order}
+	c.WriteString(LUA_SIGNATURE)
+	endian := byte(0)
+	if order == binary.LittleEndian {
+		endian = 1
+	}
+	c.Write([]byte{LUAC_VERSION51, LUAC_FORMAT, endian, 4, 8, 4, 8, 0})
+
+	c.str("@hello.lua")
+	c.int(0)
+	c.int(0)
+	c.Write([]byte{0, 0, 2, 5})
+	c.int(4)
+	binary.Write(c, order, []uint32{
+		5,                  // GETGLOBAL 0 -1
+		1 | 1<<6 | 1<<14,   // LOADK 1 -2
+		28 | 2<<23 | 1<<14, // CALL 0 2 1
+		30 | 1<<23,         // RETURN 0 1
+	})
+	c.int(4)
+	c.WriteByte(TAG_SHORT_STR)
+	c.str("print")
+	c.WriteByte(TAG_SHORT_STR)
+	c.str("hello world!")
+	c.WriteByte(TAG_NUMBER)
+	binary.Write(c, order, math.Float64bits(0.5))
+	c.WriteByte(TAG_BOOLEAN)
+	c.WriteByte(1)
+
+	c.int(1) // protos
+	c.str("")
+	c.int(1)
+	c.int(1)
+	c.Write([]byte{1, 1, 0, 2})
+	c.int(1)
+	binary.Write(c, order, uint32(30|1<<23))
+	c.int(0)
+	c.int(0)
+	c.int(1)
+	c.int(1)
+	c.int(1)
+	c.str("a")
+	c.int(0)
+	c.int(1)
+	c.int(1)
+	c.str("x")
+
+	c.int(4) // lineinfo
+	for i := 0; i < 4; i++ {
+		c.int(2)
+	}
+	c.int(0)
+	c.int(0)
+	return c.Bytes()
+}
+
+func TestParseChunk51(t *testing.T) {
+	want := &Prototype{
+		Source:       "@hello.lua",
+		MaxStackSize: 5,
+		IsVararg:     2,
+		Code:         []uint32{5, 1 | 1<<6 | 1<<14, 28 | 2<<23 | 1<<14, 30 | 1<<23},
+		Constants:    []interface{}{"print", "hello world!", 0.5, true},
+		Upvalues:     []Upvalue{},
+		Protos: []*Prototype{{
+			Source:          "@hello.lua",
+			LineDefined:     1,
+			LastLineDefined: 1,
+			Upvalues:        []Upvalue{{}},
+			NumParams:       1,
+			MaxStackSize:    2,
+			Code:            []uint32{30 | 1<<23},
+			Constants:       []interface{}{},
+			Protos:          []*Prototype{},
+			LineInfo:        []uint32{1},
+			LocVars:         []LocVar{{VarName: "a", StartPC: 0, EndPC: 1}},
+			UpvalueNames:    []string{"x"},
+			Version:         LUAC_VERSION51,
+		}},
+		LineInfo:     []uint32{2, 2, 2, 2},
+		LocVars:      []LocVar{},
+		UpvalueNames: []string{},
+		Version:      LUAC_VERSION51,
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		proto, err := ParseChunk(helloWorld51(order))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(proto, want) {
+			t.Errorf("parse 5.1 err, want %+v, ret %+v", want, proto)
+		}
+	}
+
+	data := helloWorld51(binary.LittleEndian)
+	for n := 0; n < len(data); n++ {
+		if _, err := ParseChunk(data[:n]); !errors.Is(err, ErrTruncated) {
+			t.Errorf("truncate at %d err, ret %v", n, err)
+		}
+	}
+	data[6] = 2
+	if _, err := ParseChunk(data); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("endianness err, ret %v", err)
+	}
+}
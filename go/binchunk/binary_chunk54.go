@@ -0,0 +1,180 @@
+package binchunk
+
+import "math"
+
+// lua 5.4: lundump.c
+// 5.4 的头部不再记录 int 和 size_t 的长度，长度、行号等整数都用变长编码；
+// 行号表存储和上一条指令的行号差，读入时转换成和 5.3 一样的绝对行号
+
+// lua 5.4: checkHeader
+func (self *reader) checkHeader54() {
+	self.check(self.readByte("format") == LUAC_FORMAT, ErrFormatMismatch, 5, "format")
+	self.check(string(self.readBytes(6, "LUAC_DATA")) == LUAC_DATA, ErrCorrupted, 6, "LUAC_DATA")
+	self.check(self.readByte("Instruction size") == INSTRUCTION_SIZE, ErrSizeMismatch, 12, "Instruction size")
+	self.IntegerSize = self.readSize("lua_Integer size")
+	self.NumberSize = self.readSize("lua_Number size")
+	self.checkLuacIntNum()
+}
+
+// lua 5.4: loadUnsigned
+// 每个字节存 7 位，高位在前，最后一个字节的最高位为 1
+func (self *reader) readVarint(limit uint64, field string) uint64 {
+	offset := self.pos
+	var x uint64
+	for {
+		b := self.readByte(field)
+		if x >= limit>>7 {
+			self.error(ErrCorrupted, offset, field)
+		}
+		x = x<<7 | uint64(b&0x7f)
+		if b&0x80 != 0 {
+			return x
+		}
+	}
+}
+
+// lua 5.4: loadInt
+func (self *reader) readInt54(field string) uint32 {
+	return uint32(self.readVarint(math.MaxInt32, field))
+}
+
+func (self *reader) readCount54(minSize int, field string) int {
+	offset := self.pos
+	return self.checkCount(uint64(self.readInt54(field)), minSize, offset, field)
+}
+
+// lua 5.4: loadStringN
+// 长度 + 1 后存储，0 表示 NULL
+func (self *reader) readString54(field string) string {
+	size := self.readVarint(math.MaxInt64, field)
+	if size == 0 {
+		return ""
+	}
+	return string(self.readBytes(size-1, field))
+}
+
+func (self *reader) readCode54() []uint32 {
+	code := make([]uint32, self.readCount54(INSTRUCTION_SIZE, "code"))
+	for i := range code {
+		code[i] = self.readInstruction("code")
+	}
+	return code
+}
+
+func (self *reader) readConstants54() []interface{} {
+	constants := make([]interface{}, self.readCount54(1, "constants"))
+	for i := range constants {
+		offset := self.pos
+		switch self.readByte("constant") {
+		case LUA_VNIL:
+			constants[i] = nil
+		case LUA_VFALSE:
+			constants[i] = false
+		case LUA_VTRUE:
+			constants[i] = true
+		case LUA_VNUMINT:
+			constants[i] = self.readLuaInteger("constant")
+		case LUA_VNUMFLT:
+			constants[i] = self.readLuaNumber("constant")
+		case LUA_VSHRSTR, LUA_VLNGSTR:
+			constants[i] = self.readString54("constant")
+		default:
+			self.error(ErrBadTag, offset, "constant")
+		}
+	}
+	return constants
+}
+
+func (self *reader) readUpvalues54() []Upvalue {
+	upvalues := make([]Upvalue, self.readCount54(3, "upvalues"))
+	for i := range upvalues {
+		upvalues[i] = Upvalue{
+			Instack: self.readByte("upvalues"),
+			Idx:     self.readByte("upvalues"),
+			Kind:    self.readByte("upvalues"),
+		}
+	}
+	return upvalues
+}
+
+func (self *reader) readProtos54(parentSource string) []*Prototype {
+	// 最小的函数原型：空的 source，两个行号，三个字节，8 个空数组
+	protos := make([]*Prototype, self.readCount54(1+2+3+8, "protos"))
+	for i := range protos {
+		protos[i] = self.readProto54(parentSource)
+	}
+	return protos
+}
+
+// lua 5.4: luaG_getfuncline
+// lineinfo 是和上一条指令的行号差，为 ABSLINEINFO 时绝对行号记录在 abslineinfo 里
+func (self *reader) readLineInfo54(lineDefined uint32) []uint32 {
+	offset := self.pos
+	deltas := self.readBytes(uint64(self.readCount54(1, "lineinfo")), "lineinfo")
+
+	absLines := map[uint32]uint32{}
+	n := self.readCount54(2, "abslineinfo")
+	for i := 0; i < n; i++ {
+		pc := self.readInt54("abslineinfo")
+		absLines[pc] = self.readInt54("abslineinfo")
+	}
+
+	lineInfo := make([]uint32, len(deltas))
+	line := lineDefined
+	for pc, delta := range deltas {
+		if int8(delta) == ABSLINEINFO {
+			abs, ok := absLines[uint32(pc)]
+			self.check(ok, ErrCorrupted, offset, "abslineinfo")
+			line = abs
+		} else {
+			line += uint32(int8(delta))
+		}
+		lineInfo[pc] = line
+	}
+	return lineInfo
+}
+
+func (self *reader) readLocVars54() []LocVar {
+	locVars := make([]LocVar, self.readCount54(3, "locvars"))
+	for i := range locVars {
+		locVars[i] = LocVar{
+			VarName: self.readString54("locvars"),
+			StartPC: self.readInt54("locvars"),
+			EndPC:   self.readInt54("locvars"),
+		}
+	}
+	return locVars
+}
+
+func (self *reader) readUpvalueNames54() []string {
+	names := make([]string, self.readCount54(1, "upvalue names"))
+	for i := range names {
+		names[i] = self.readString54("upvalue names")
+	}
+	return names
+}
+
+// lua 5.4: loadFunction
+func (self *reader) readProto54(parentSource string) *Prototype {
+	source := self.readString54("source")
+	if source == "" {
+		source = parentSource
+	}
+	proto := &Prototype{
+		Source:          source,
+		LineDefined:     self.readInt54("linedefined"),
+		LastLineDefined: self.readInt54("lastlinedefined"),
+		NumParams:       self.readByte("numparams"),
+		IsVararg:        self.readByte("is_vararg"),
+		MaxStackSize:    self.readByte("maxstacksize"),
+		Code:            self.readCode54(),
+		Constants:       self.readConstants54(),
+		Upvalues:        self.readUpvalues54(),
+		Version:         LUAC_VERSION54,
+	}
+	proto.Protos = self.readProtos54(source)
+	proto.LineInfo = self.readLineInfo54(proto.LineDefined)
+	proto.LocVars = self.readLocVars54()
+	proto.UpvalueNames = self.readUpvalueNames54()
+	return proto
+}
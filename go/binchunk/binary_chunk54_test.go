@@ -0,0 +1,172 @@
+package binchunk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// 按 lua 5.4 ldump.c 的格式拼出二进制 chunk
+type chunk54 struct {
+	bytes.Buffer
+}
+
+func (self *chunk54) varint(x uint64) {
+	var b [10]byte
+	n := len(b) - 1
+	b[n] = byte(x&0x7f) | 0x80
+	for x >>= 7; x > 0; x >>= 7 {
+		n--
+		b[n] = byte(x & 0x7f)
+	}
+	self.Write(b[n:])
+}
+
+func (self *chunk54) str(s string) {
+	self.varint(uint64(len(s) + 1))
+	self.WriteString(s)
+}
+
+func (self *chunk54) uint64(x uint64) {
+	binary.Write(self, binary.LittleEndian, x)
+}
+
+func (self *chunk54) code(code ...uint32) {
+	self.varint(uint64(len(code)))
+	binary.Write(self, binary.LittleEndian, code)
+}
+
+func (self *chunk54) header() {
+	self.WriteString(LUA_SIGNATURE)
+	self.Write([]byte{LUAC_VERSION54, LUAC_FORMAT})
+	self.WriteString(LUAC_DATA)
+	self.Write([]byte{4, 8, 8})
+	self.uint64(LUAC_INT)
+	self.uint64(math.Float64bits(LUAC_NUM))
+}
+
+// local function f(a) end
+// print("hello world!")
+func helloWorld54() []byte {
+	c := &chunk54{}
+	c.header()
+	c.WriteByte(1) // sizeupvalues
+	c.str("@hello.lua")
+	c.varint(0)
+	c.varint(0)
+	c.Write([]byte{0, 1, 2})
+	c.code(
+		uint32(81),             // VARARGPREP 0
+		uint32(79|0<<7|0<<15),  // CLOSURE 0 0
+		uint32(11|1<<7),        // GETTABUP 1 0 0
+		uint32(3|2<<7|1<<15),   // LOADK 2 1
+		uint32(70|1<<16|1<<24), // RETURN 0 1 1
+	)
+	c.varint(5)
+	c.WriteByte(LUA_VSHRSTR)
+	c.str("print")
+	c.WriteByte(LUA_VSHRSTR)
+	c.str("hello world!")
+	c.WriteByte(LUA_VTRUE)
+	c.WriteByte(LUA_VNUMINT)
+	c.uint64(7)
+	c.WriteByte(LUA_VNUMFLT)
+	c.uint64(math.Float64bits(0.5))
+	c.varint(1)
+	c.Write([]byte{1, 0, 0})
+
+	c.varint(1) // protos
+	c.varint(0) // 和外层函数相同的 source
+	c.varint(1)
+	c.varint(1)
+	c.Write([]byte{1, 0, 2})
+	c.code(uint32(71)) // RETURN0
+	c.varint(0)
+	c.varint(0)
+	c.varint(0)
+	c.varint(1)
+	c.WriteByte(0)
+	c.varint(0)
+	c.varint(1)
+	c.str("a")
+	c.varint(0)
+	c.varint(1)
+	c.varint(0)
+
+	c.varint(5) // lineinfo
+	c.Write([]byte{1, 0, 0x80, 0, 1})
+	c.varint(1) // abslineinfo
+	c.varint(2)
+	c.varint(200)
+	c.varint(1) // locvars
+	c.str("f")
+	c.varint(2)
+	c.varint(5)
+	c.varint(1) // upvalue names
+	c.str("_ENV")
+	return c.Bytes()
+}
+
+func TestParseChunk54(t *testing.T) {
+	proto, err := ParseChunk(helloWorld54())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Prototype{
+		Source:       "@hello.lua",
+		IsVararg:     1,
+		MaxStackSize: 2,
+		Code:         proto.Code,
+		Constants:    []interface{}{"print", "hello world!", true, int64(7), 0.5},
+		Upvalues:     []Upvalue{{Instack: 1, Idx: 0, Kind: 0}},
+		Protos: []*Prototype{{
+			Source:          "@hello.lua",
+			LineDefined:     1,
+			LastLineDefined: 1,
+			NumParams:       1,
+			MaxStackSize:    2,
+			Code:            []uint32{71},
+			Constants:       []interface{}{},
+			Upvalues:        []Upvalue{},
+			Protos:          []*Prototype{},
+			LineInfo:        []uint32{1},
+			LocVars:         []LocVar{{VarName: "a", StartPC: 0, EndPC: 1}},
+			UpvalueNames:    []string{},
+			Version:         LUAC_VERSION54,
+		}},
+		LineInfo:     []uint32{1, 1, 200, 200, 201},
+		LocVars:      []LocVar{{VarName: "f", StartPC: 2, EndPC: 5}},
+		UpvalueNames: []string{"_ENV"},
+		Version:      LUAC_VERSION54,
+	}
+	if !reflect.DeepEqual(proto, want) {
+		t.Errorf("parse 5.4 err, want %+v, ret %+v", want, proto)
+	}
+
+	// 5.4 的函数原型不能写成 5.3 的 chunk
+	if err := Dump(proto, &bytes.Buffer{}, false); err == nil {
+		t.Errorf("dump 5.4 err, want error")
+	}
+}
+
+func TestParseChunk54Error(t *testing.T) {
+	data := helloWorld54()
+	for n := 0; n < len(data); n++ {
+		if _, err := ParseChunk(data[:n]); !errors.Is(err, ErrTruncated) {
+			t.Errorf("truncate at %d err, ret %v", n, err)
+		}
+	}
+
+	// 超出 int 范围的变长整数
+	c := &chunk54{}
+	c.header()
+	c.WriteByte(1)
+	c.varint(0)
+	c.Write([]byte{0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x80})
+	if _, err := ParseChunk(c.Bytes()); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("varint err, ret %v", err)
+	}
+}
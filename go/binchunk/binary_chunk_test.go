@@ -0,0 +1,94 @@
+package binchunk
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestParseChunk(t *testing.T) {
+	data := readLuacOut(t)
+	proto, err := ParseChunk(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proto.Source != "@./hello_world.lua" || len(proto.Code) != 4 || len(proto.Constants) != 2 {
+		t.Errorf("parse err, ret %+v", proto)
+	}
+}
+
+func TestParseChunkError(t *testing.T) {
+	data := readLuacOut(t)
+	mutate := func(offset int, b byte) []byte {
+		bad := append([]byte{}, data...)
+		bad[offset] = b
+		return bad
+	}
+	tag := bytes.Index(data, []byte("\x04\x06print"))
+
+	tests := []struct {
+		data   []byte
+		err    error
+		offset int
+		field  string
+	}{
+		{nil, ErrTruncated, 0, "signature"},
+		{data[:4], ErrTruncated, 4, "version"},
+		{mutate(1, 'X'), ErrBadSignature, 0, "signature"},
+		{mutate(4, 0x52), ErrVersionMismatch, 4, "version"},
+		{mutate(5, 1), ErrFormatMismatch, 5, "format"},
+		{mutate(8, 0), ErrCorrupted, 6, "LUAC_DATA"},
+		{mutate(13, 3), ErrSizeMismatch, 13, "size_t size"},
+		{mutate(tag, 0x09), ErrBadTag, tag, "constant"},
+		{data[:tag+3], ErrTruncated, tag + 2, "constant"},
+		{data[:len(data)-1], ErrTruncated, len(data) - 4, "upvalue names"},
+	}
+	for _, test := range tests {
+		proto, err := ParseChunk(test.data)
+		var chunkErr *ChunkError
+		if proto != nil || !errors.As(err, &chunkErr) || !errors.Is(err, test.err) ||
+			chunkErr.Offset != test.offset || chunkErr.Field != test.field {
+			t.Errorf("parse err, want %v at %d (%s), ret %v", test.err, test.offset, test.field, err)
+		}
+	}
+}
+
+// 截断或者随机修改过的 chunk 只返回错误，不会 panic
+func TestParseChunkNoPanic(t *testing.T) {
+	data := readLuacOut(t)
+	for n := 0; n < len(data); n++ {
+		if _, err := ParseChunk(data[:n]); !errors.Is(err, ErrTruncated) {
+			t.Errorf("truncate at %d err, ret %v", n, err)
+		}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		bad := append([]byte{}, data...)
+		for j := 0; j < 3; j++ {
+			bad[33+r.Intn(len(bad)-33)] = byte(r.Intn(256))
+		}
+		ParseChunk(bad)
+	}
+}
+
+// 伪造的数组长度不会导致分配大量内存
+func TestParseChunkHugeCount(t *testing.T) {
+	data := readLuacOut(t)
+	code := bytes.Index(data, []byte{4, 0, 0, 0}) // 主函数的指令条数
+	bad := append([]byte{}, data...)
+	copy(bad[code:], []byte{0xff, 0xff, 0xff, 0xff})
+	if _, err := ParseChunk(bad); !errors.Is(err, ErrTruncated) {
+		t.Errorf("huge count err, ret %v", err)
+	}
+}
+
+func readLuacOut(t *testing.T) []byte {
+	data, err := os.ReadFile("../../lua/luac.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
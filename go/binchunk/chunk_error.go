@@ -0,0 +1,33 @@
+package binchunk
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ChunkError.Err 的取值，可以用 errors.Is 判断
+var (
+	ErrTruncated       = errors.New("truncated chunk")
+	ErrBadSignature    = errors.New("not a binary chunk")
+	ErrVersionMismatch = errors.New("version mismatch")
+	ErrFormatMismatch  = errors.New("format mismatch")
+	ErrSizeMismatch    = errors.New("incompatible number or size format")
+	ErrEndianMismatch  = errors.New("endianness mismatch")
+	ErrBadTag          = errors.New("bad constant tag")
+	ErrCorrupted       = errors.New("corrupted chunk")
+)
+
+// ChunkError 是解析二进制 chunk 时遇到的错误
+type ChunkError struct {
+	Err    error  // 错误的种类，ErrTruncated 等
+	Offset int    // 出错的字段在 chunk 中的字节偏移
+	Field  string // 正在读取的字段
+}
+
+func (self *ChunkError) Error() string {
+	return fmt.Sprintf("%v at offset %d (reading %s)", self.Err, self.Offset, self.Field)
+}
+
+func (self *ChunkError) Unwrap() error {
+	return self.Err
+}
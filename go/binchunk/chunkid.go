@@ -0,0 +1,40 @@
+package binchunk
+
+import "strings"
+
+// maximum size for the description of the source of a function in debug information
+const LUA_IDSIZE = 60
+
+// lua: luaO_chunkid
+// 把函数原型的 Source 转换成错误信息和调用栈中显示的名字
+func ChunkID(source string) string {
+	if source == "" {
+		return "?"
+	}
+	switch source[0] {
+	case '=': // 'literal' source
+		if len(source) <= LUA_IDSIZE {
+			return source[1:]
+		}
+		return source[1:LUA_IDSIZE]
+	case '@': // file name
+		if len(source) <= LUA_IDSIZE {
+			return source[1:]
+		}
+		return "..." + source[len(source)-LUA_IDSIZE+4:]
+	default: // string; format as [string "source"]
+		const PRE, POS, RETS = `[string "`, `"]`, "..."
+		line := source
+		if nl := strings.IndexByte(source, '\n'); nl >= 0 {
+			line = source[:nl]
+		}
+		maxLen := LUA_IDSIZE - len(PRE) - len(RETS) - len(POS) - 1
+		if len(line) < len(source) || len(line) > maxLen {
+			if len(line) > maxLen {
+				line = line[:maxLen]
+			}
+			return PRE + line + RETS + POS
+		}
+		return PRE + line + POS
+	}
+}
@@ -0,0 +1,17 @@
+package binchunk
+
+import "testing"
+
+func TestChunkID(t *testing.T) {
+	tests := map[string]string{
+		"@test.lua":    "test.lua",
+		"=stdin":       "stdin",
+		"print(1)":     `[string "print(1)"]`,
+		"a = 1\nb = 2": `[string "a = 1..."]`,
+	}
+	for source, want := range tests {
+		if ret := ChunkID(source); ret != want {
+			t.Errorf("chunk id err, want %q, ret %q", want, ret)
+		}
+	}
+}
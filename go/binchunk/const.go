@@ -35,3 +35,23 @@ const (
 	TAG_SHORT_STR = 0x04
 	TAG_LONG_STR  = 0x14
 )
+
+// lua 5.1: lundump.h
+const LUAC_VERSION51 = 0x51
+
+// lua 5.4: lundump.h, lobject.h
+const (
+	LUAC_VERSION54 = 0x54
+	ABSLINEINFO    = -0x80 // lineinfo 中的这一项要到 abslineinfo 里找绝对行号
+)
+
+// lua 5.4 的常量类型，低 4 位是基本类型，高位是变体
+const (
+	LUA_VNIL    = 0x00
+	LUA_VFALSE  = 0x01
+	LUA_VTRUE   = 0x11
+	LUA_VNUMINT = 0x03
+	LUA_VNUMFLT = 0x13
+	LUA_VSHRSTR = 0x04
+	LUA_VLNGSTR = 0x14
+)
@@ -0,0 +1,197 @@
+package binchunk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// lua: LUAI_MAXSHORTLEN
+// 不超过这个长度的字符串常量是短字符串
+const LUAI_MAXSHORTLEN = 40
+
+type dumpState struct {
+	buf bytes.Buffer
+	err error
+	Format
+}
+
+// lua: luaU_dump
+// Dump 把函数原型写成和 luac 相同格式的二进制 chunk，
+// strip 为 true 时先用 Strip 去掉调试信息（源文件名、行号、局部变量名和 upvalue 名）
+func Dump(proto *Prototype, w io.Writer, strip bool) error {
+	return DumpWithFormat(proto, w, strip, NativeFormat)
+}
+
+// DumpWithFormat 和 Dump 一样，按 format 指定的长度和字节序写入数值，
+// 例如给 32 位平台生成 chunk；整数常量超出 lua_Integer 的范围时返回错误
+func DumpWithFormat(proto *Prototype, w io.Writer, strip bool, format Format) error {
+	if proto.Version != 0 && proto.Version != LUAC_VERSION {
+		return fmt.Errorf("cannot dump a chunk of version 0x%x", proto.Version)
+	}
+	if format.ByteOrder == nil || !validSize(format.IntSize) || !validSize(format.SizetSize) ||
+		!validSize(format.IntegerSize) || !validSize(format.NumberSize) {
+		return fmt.Errorf("unsupported chunk format %+v", format)
+	}
+	if strip {
+		proto = Strip(proto)
+	}
+	d := &dumpState{Format: format}
+	d.dumpHeader()
+	d.dumpByte(byte(len(proto.Upvalues)))
+	d.dumpFunction(proto, "")
+	if d.err != nil {
+		return d.err
+	}
+	_, err := w.Write(d.buf.Bytes())
+	return err
+}
+
+func (self *dumpState) dumpByte(b byte) {
+	self.buf.WriteByte(b)
+}
+
+// 按字节序写入 size 字节的无符号整数
+func (self *dumpState) dumpUint(n uint64, size int) {
+	var b [8]byte
+	self.putUint(b[:size], n)
+	self.buf.Write(b[:size])
+}
+
+func (self *dumpState) dumpInstruction(i uint32) {
+	self.dumpUint(uint64(i), INSTRUCTION_SIZE)
+}
+
+func (self *dumpState) dumpInt(n int) {
+	self.dumpUint(uint64(n), self.IntSize)
+}
+
+func (self *dumpState) dumpInteger(n int64) {
+	if self.IntegerSize == 4 && int64(int32(n)) != n && self.err == nil {
+		self.err = fmt.Errorf("integer constant %d does not fit in a 4-byte lua_Integer", n)
+	}
+	self.dumpUint(uint64(n), self.IntegerSize)
+}
+
+func (self *dumpState) dumpNumber(n float64) {
+	if self.NumberSize == 4 {
+		self.dumpUint(uint64(math.Float32bits(float32(n))), 4)
+	} else {
+		self.dumpUint(math.Float64bits(n), 8)
+	}
+}
+
+// 和 readString 对应，null 为 true 时写入 NULL
+func (self *dumpState) dumpString(s string, null bool) {
+	if null {
+		self.dumpByte(0)
+		return
+	}
+	size := len(s) + 1
+	if size < 0xff {
+		self.dumpByte(byte(size))
+	} else {
+		self.dumpByte(0xff)
+		self.dumpUint(uint64(size), self.SizetSize)
+	}
+	self.buf.WriteString(s)
+}
+
+func (self *dumpState) dumpHeader() {
+	self.buf.WriteString(LUA_SIGNATURE)
+	self.dumpByte(LUAC_VERSION)
+	self.dumpByte(LUAC_FORMAT)
+	self.buf.WriteString(LUAC_DATA)
+	self.dumpByte(byte(self.IntSize))
+	self.dumpByte(byte(self.SizetSize))
+	self.dumpByte(INSTRUCTION_SIZE)
+	self.dumpByte(byte(self.IntegerSize))
+	self.dumpByte(byte(self.NumberSize))
+	self.dumpInteger(LUAC_INT)
+	self.dumpNumber(LUAC_NUM)
+}
+
+// 子函数和外层函数的 source 相同时不重复写入
+func (self *dumpState) dumpFunction(f *Prototype, parentSource string) {
+	self.dumpString(f.Source, f.Source == parentSource)
+	self.dumpInt(int(f.LineDefined))
+	self.dumpInt(int(f.LastLineDefined))
+	self.dumpByte(f.NumParams)
+	self.dumpByte(f.IsVararg)
+	self.dumpByte(f.MaxStackSize)
+	self.dumpCode(f)
+	self.dumpConstants(f)
+	self.dumpUpvalues(f)
+	self.dumpProtos(f)
+	self.dumpDebug(f)
+}
+
+func (self *dumpState) dumpCode(f *Prototype) {
+	self.dumpInt(len(f.Code))
+	for _, i := range f.Code {
+		self.dumpInstruction(i)
+	}
+}
+
+func (self *dumpState) dumpConstants(f *Prototype) {
+	self.dumpInt(len(f.Constants))
+	for _, k := range f.Constants {
+		switch x := k.(type) {
+		case nil:
+			self.dumpByte(TAG_NIL)
+		case bool:
+			self.dumpByte(TAG_BOOLEAN)
+			if x {
+				self.dumpByte(1)
+			} else {
+				self.dumpByte(0)
+			}
+		case float64:
+			self.dumpByte(TAG_NUMBER)
+			self.dumpNumber(x)
+		case int64:
+			self.dumpByte(TAG_INTEGER)
+			self.dumpInteger(x)
+		case string:
+			if len(x) <= LUAI_MAXSHORTLEN {
+				self.dumpByte(TAG_SHORT_STR)
+			} else {
+				self.dumpByte(TAG_LONG_STR)
+			}
+			self.dumpString(x, false)
+		}
+	}
+}
+
+func (self *dumpState) dumpUpvalues(f *Prototype) {
+	self.dumpInt(len(f.Upvalues))
+	for _, upval := range f.Upvalues {
+		self.dumpByte(upval.Instack)
+		self.dumpByte(upval.Idx)
+	}
+}
+
+func (self *dumpState) dumpProtos(f *Prototype) {
+	self.dumpInt(len(f.Protos))
+	for _, p := range f.Protos {
+		self.dumpFunction(p, f.Source)
+	}
+}
+
+func (self *dumpState) dumpDebug(f *Prototype) {
+	self.dumpInt(len(f.LineInfo))
+	for _, line := range f.LineInfo {
+		self.dumpInt(int(line))
+	}
+	self.dumpInt(len(f.LocVars))
+	for _, locVar := range f.LocVars {
+		self.dumpString(locVar.VarName, false)
+		self.dumpInt(int(locVar.StartPC))
+		self.dumpInt(int(locVar.EndPC))
+	}
+	self.dumpInt(len(f.UpvalueNames))
+	for _, name := range f.UpvalueNames {
+		self.dumpString(name, false)
+	}
+}
@@ -0,0 +1,84 @@
+package binchunk
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 解析 luac 的输出再写回去，和原来的字节完全相同
+func TestDumpLuacOut(t *testing.T) {
+	data := readLuacOut(t)
+	proto, err := ParseChunk(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := Dump(proto, buf, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("dump err, want\n% x\nret\n% x", data, buf.Bytes())
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	sub := &Prototype{
+		Source:       "@test.lua",
+		LineDefined:  2,
+		NumParams:    1,
+		MaxStackSize: 2,
+		Code:         []uint32{0x26000000, 0x00800026},
+		Constants:    []interface{}{},
+		Upvalues:     []Upvalue{{Instack: 1, Idx: 0}},
+		Protos:       []*Prototype{},
+		LineInfo:     []uint32{2, 3},
+		LocVars:      []LocVar{},
+		UpvalueNames: []string{"x"},
+		Version:      LUAC_VERSION,
+	}
+	proto := &Prototype{
+		Source:       "@test.lua",
+		IsVararg:     1,
+		MaxStackSize: 2,
+		Code:         []uint32{0x00800026},
+		Constants: []interface{}{nil, true, false, int64(-1), 0.5, "",
+			strings.Repeat("s", LUAI_MAXSHORTLEN), long},
+		Upvalues:     []Upvalue{{Instack: 1, Idx: 0}},
+		Protos:       []*Prototype{sub},
+		LineInfo:     []uint32{4},
+		LocVars:      []LocVar{{VarName: "a", StartPC: 0, EndPC: 1}},
+		UpvalueNames: []string{"_ENV"},
+		Version:      LUAC_VERSION,
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Dump(proto, buf, false); err != nil {
+		t.Fatal(err)
+	}
+	// 长字符串的标记和长度
+	if !bytes.Contains(buf.Bytes(), append([]byte{TAG_LONG_STR, 0xff, 45, 1, 0, 0, 0, 0, 0, 0}, long...)) {
+		t.Errorf("long string err")
+	}
+	ret, err := ParseChunk(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ret, proto) {
+		t.Errorf("round trip err, want %+v, ret %+v", proto, ret)
+	}
+
+	// 去掉调试信息
+	buf.Reset()
+	Dump(proto, buf, true)
+	ret, err = ParseChunk(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Source != "" || len(ret.LineInfo) > 0 || len(ret.LocVars) > 0 ||
+		len(ret.Protos[0].UpvalueNames) > 0 || len(ret.Code) != 1 {
+		t.Errorf("strip err, ret %+v", ret)
+	}
+}
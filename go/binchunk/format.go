@@ -0,0 +1,61 @@
+package binchunk
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Format 是二进制 chunk 头部记录的平台参数，决定数值在 chunk 中占用的字节数和字节序。
+// 32 位的 luac 和 LUA_32BITS 配置生成的 chunk 可以读入，
+// lua_Integer 和 lua_Number 会转换成 Prototype 中的 int64 和 float64
+type Format struct {
+	ByteOrder   binary.ByteOrder
+	IntSize     int // sizeof(int)：数组长度、行号
+	SizetSize   int // sizeof(size_t)：长字符串的长度
+	IntegerSize int // sizeof(lua_Integer)
+	NumberSize  int // sizeof(lua_Number)
+}
+
+// NativeFormat 是 64 位小端平台上 luac 的默认格式，也是 Dump 使用的格式
+var NativeFormat = Format{
+	ByteOrder:   binary.LittleEndian,
+	IntSize:     CINT_SIZE,
+	SizetSize:   CSIZET_SIZE,
+	IntegerSize: LUA_INTEGER_SIZE,
+	NumberSize:  LUA_NUMBER_SIZE,
+}
+
+// 各种长度只支持 4 字节和 8 字节
+func validSize(n int) bool {
+	return n == 4 || n == 8
+}
+
+// 按字节序把 4 或者 8 字节解码成无符号整数
+func (self Format) getUint(b []byte) uint64 {
+	if len(b) == 4 {
+		return uint64(self.ByteOrder.Uint32(b))
+	}
+	return self.ByteOrder.Uint64(b)
+}
+
+func (self Format) putUint(b []byte, n uint64) {
+	if len(b) == 4 {
+		self.ByteOrder.PutUint32(b, uint32(n))
+	} else {
+		self.ByteOrder.PutUint64(b, n)
+	}
+}
+
+func (self Format) getInteger(b []byte) int64 {
+	if len(b) == 4 {
+		return int64(int32(self.ByteOrder.Uint32(b)))
+	}
+	return int64(self.ByteOrder.Uint64(b))
+}
+
+func (self Format) getNumber(b []byte) float64 {
+	if len(b) == 4 {
+		return float64(math.Float32frombits(self.ByteOrder.Uint32(b)))
+	}
+	return math.Float64frombits(self.ByteOrder.Uint64(b))
+}
@@ -0,0 +1,69 @@
+package binchunk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// 32 位大端平台上 luac 生成的头部
+const header32BE = "\x1bLua\x53\x00\x19\x93\r\n\x1a\n\x04\x04\x04\x04\x04" +
+	"\x00\x00\x56\x78\x43\xb9\x40\x00"
+
+func TestFormats(t *testing.T) {
+	want, err := ParseChunk(readLuacOut(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, size := range []int{4, 8} {
+			format := Format{order, size, size, size, size}
+			buf := &bytes.Buffer{}
+			if err := DumpWithFormat(want, buf, false, format); err != nil {
+				t.Fatal(err)
+			}
+			ret, err := ParseChunk(buf.Bytes())
+			if err != nil {
+				t.Errorf("format %+v err: %v", format, err)
+			} else if !reflect.DeepEqual(ret, want) {
+				t.Errorf("format %+v err, want %+v, ret %+v", format, want, ret)
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	DumpWithFormat(want, buf, false, Format{binary.BigEndian, 4, 4, 4, 4})
+	if !bytes.HasPrefix(buf.Bytes(), []byte(header32BE)) {
+		t.Errorf("header err, want\n% x\nret\n% x", header32BE, buf.Bytes()[:len(header32BE)])
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	proto := &Prototype{Constants: []interface{}{int64(1) << 40, 0.1}}
+	buf := &bytes.Buffer{}
+	if err := DumpWithFormat(proto, buf, false, Format{binary.LittleEndian, 4, 4, 4, 8}); err == nil {
+		t.Errorf("dump err, want integer overflow error")
+	}
+	if err := DumpWithFormat(proto, buf, false, Format{binary.LittleEndian, 2, 4, 4, 8}); err == nil {
+		t.Errorf("dump err, want unsupported format error")
+	}
+
+	// float 精度的 lua_Number
+	proto.Constants = proto.Constants[1:]
+	if err := DumpWithFormat(proto, buf, false, Format{binary.LittleEndian, 4, 4, 8, 4}); err != nil {
+		t.Fatal(err)
+	}
+	ret, err := ParseChunk(buf.Bytes())
+	if err != nil || ret.Constants[0] != float64(float32(0.1)) {
+		t.Errorf("float err, ret %v %v", ret, err)
+	}
+
+	// LUAC_INT 不是 0x5678
+	bad := []byte(header32BE)
+	bad[20] = 0x79
+	if _, err := ParseChunk(bad); !errors.Is(err, ErrEndianMismatch) {
+		t.Errorf("endian err, ret %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package binchunk
+
+// lua: luac -s
+// Strip 返回去掉了调试信息（源文件名、行号、局部变量名和 upvalue 名）的函数原型，
+// 子函数也一并去掉，proto 本身不变。没有调试信息时错误消息中的位置显示为 "?"
+func Strip(proto *Prototype) *Prototype {
+	stripped := *proto
+	stripped.Source = ""
+	stripped.LineInfo = nil
+	stripped.LocVars = nil
+	stripped.UpvalueNames = nil
+	if proto.Protos != nil {
+		stripped.Protos = make([]*Prototype, len(proto.Protos))
+		for i, p := range proto.Protos {
+			stripped.Protos[i] = Strip(p)
+		}
+	}
+	return &stripped
+}
@@ -0,0 +1,44 @@
+package binchunk
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	proto, err := ParseChunk(readLuacOut(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped := Strip(proto)
+
+	var check func(p, s *Prototype)
+	check = func(p, s *Prototype) {
+		if s.Source != "" || s.LineInfo != nil || s.LocVars != nil || s.UpvalueNames != nil {
+			t.Errorf("strip err, debug info left in %+v", s)
+		}
+		if len(s.Code) != len(p.Code) || len(s.Upvalues) != len(p.Upvalues) || len(s.Protos) != len(p.Protos) {
+			t.Errorf("strip err, want %+v, ret %+v", p, s)
+			return
+		}
+		for i := range p.Protos {
+			check(p.Protos[i], s.Protos[i])
+		}
+	}
+	check(proto, stripped)
+	if proto.Source == "" || len(proto.LineInfo) == 0 {
+		t.Errorf("strip err, original proto modified")
+	}
+
+	// 和 Dump 时去掉调试信息的结果相同
+	want, ret := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := Dump(proto, want, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Dump(stripped, ret, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want.Bytes(), ret.Bytes()) {
+		t.Errorf("dump stripped err, want %x, ret %x", want.Bytes(), ret.Bytes())
+	}
+}
@@ -0,0 +1,26 @@
+package ast
+
+// Pos 是节点在源代码中的位置，一般是节点第一个记号的位置，行号和列号都从 1 开始
+type Pos struct {
+	Line   int
+	Column int
+}
+
+func (self Pos) Position() Pos {
+	return self
+}
+
+// Node 是所有 AST 节点都实现的接口
+type Node interface {
+	Position() Pos
+}
+
+// chunk ::= block
+// block ::= {stat} [retstat]
+// retstat ::= return [explist] [';']
+type Block struct {
+	Pos
+	LastLine int // 块结束的行号
+	Stats    []Stat
+	RetExps  []Exp // 没有 return 语句时为 nil
+}
@@ -0,0 +1,130 @@
+package ast
+
+/*
+exp ::=  nil | false | true | Numeral | LiteralString | '...' | functiondef |
+
+	prefixexp | tableconstructor | exp binop exp | unop exp
+
+prefixexp ::= var | functioncall | '(' exp ')'
+
+var ::=  Name | prefixexp '[' exp ']' | prefixexp '.' Name
+
+functioncall ::=  prefixexp args | prefixexp ':' Name args
+*/
+type Exp interface {
+	Node
+	expNode()
+}
+
+type NilExp struct{ Pos }    // nil
+type TrueExp struct{ Pos }   // true
+type FalseExp struct{ Pos }  // false
+type VarargExp struct{ Pos } // ...
+
+// Numeral
+type IntegerExp struct {
+	Pos
+	Val int64
+}
+type FloatExp struct {
+	Pos
+	Val float64
+}
+
+// LiteralString
+type StringExp struct {
+	Pos
+	Str string
+}
+
+// unop exp
+type UnopExp struct {
+	Pos
+	Op  int // lexer 的 token kind
+	Exp Exp
+}
+
+// exp1 op exp2，位置是运算符的位置
+type BinopExp struct {
+	Pos
+	Op   int // lexer 的 token kind
+	Exp1 Exp
+	Exp2 Exp
+}
+
+// exp1 .. exp2 .. expN，连续的 .. 合并成一个节点，位置是第一个运算符的位置
+type ConcatExp struct {
+	Pos
+	Exps []Exp
+}
+
+// tableconstructor ::= '{' [fieldlist] '}'
+// fieldlist ::= field {fieldsep field} [fieldsep]
+// field ::= '[' exp ']' '=' exp | Name '=' exp | exp
+// fieldsep ::= ',' | ';'
+type TableConstructorExp struct {
+	Pos
+	LastLine int   // '}' 所在的行
+	KeyExps  []Exp // 位置字段的键为 nil
+	ValExps  []Exp
+}
+
+// functiondef ::= function funcbody
+// funcbody ::= '(' [parlist] ')' block end
+// parlist ::= namelist [',' '...'] | '...'
+// namelist ::= Name {',' Name}
+type FuncDefExp struct {
+	Pos
+	LastLine int // end 所在的行
+	ParList  []string
+	IsVararg bool
+	Block    *Block
+}
+
+// Name
+type NameExp struct {
+	Pos
+	Name string
+}
+
+// '(' exp ')'
+type ParensExp struct {
+	Pos
+	Exp Exp
+}
+
+// prefixexp '[' exp ']'
+// prefixexp '.' Name 的 KeyExp 是 StringExp
+type TableAccessExp struct {
+	Pos
+	LastLine  int // ']' 或者 Name 所在的行
+	PrefixExp Exp
+	KeyExp    Exp
+}
+
+// functioncall ::=  prefixexp args | prefixexp ':' Name args
+// args ::=  '(' [explist] ')' | tableconstructor | LiteralString
+type FuncCallExp struct {
+	Pos
+	LastLine  int // ')' 或者最后一个参数所在的行
+	PrefixExp Exp
+	NameExp   *StringExp // 方法调用的方法名，否则为 nil
+	Args      []Exp
+}
+
+func (*NilExp) expNode()              {}
+func (*TrueExp) expNode()             {}
+func (*FalseExp) expNode()            {}
+func (*VarargExp) expNode()           {}
+func (*IntegerExp) expNode()          {}
+func (*FloatExp) expNode()            {}
+func (*StringExp) expNode()           {}
+func (*UnopExp) expNode()             {}
+func (*BinopExp) expNode()            {}
+func (*ConcatExp) expNode()           {}
+func (*TableConstructorExp) expNode() {}
+func (*FuncDefExp) expNode()          {}
+func (*NameExp) expNode()             {}
+func (*ParensExp) expNode()           {}
+func (*TableAccessExp) expNode()      {}
+func (*FuncCallExp) expNode()         {}
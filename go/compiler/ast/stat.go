@@ -0,0 +1,123 @@
+package ast
+
+/*
+stat ::=  ';' |
+
+	varlist '=' explist |
+	functioncall |
+	label |
+	break |
+	goto Name |
+	do block end |
+	while exp do block end |
+	repeat block until exp |
+	if exp then block {elseif exp then block} [else block] end |
+	for Name '=' exp ',' exp [',' exp] do block end |
+	for namelist in explist do block end |
+	function funcname funcbody |
+	local function Name funcbody |
+	local namelist ['=' explist]
+*/
+type Stat interface {
+	Node
+	statNode()
+}
+
+type EmptyStat struct{ Pos } // ';'
+type BreakStat struct{ Pos } // break
+type LabelStat struct {      // '::' Name '::'
+	Pos
+	Name string
+}
+type GotoStat struct { // goto Name
+	Pos
+	Name string
+}
+type DoStat struct { // do block end
+	Pos
+	Block *Block
+}
+
+// functioncall
+type FuncCallStat = FuncCallExp
+
+// while exp do block end
+type WhileStat struct {
+	Pos
+	Exp   Exp
+	Block *Block
+}
+
+// repeat block until exp
+type RepeatStat struct {
+	Pos
+	Block *Block
+	Exp   Exp
+}
+
+// if exp then block {elseif exp then block} [else block] end
+// else 分支表示为条件是 TrueExp 的 elseif 分支
+type IfStat struct {
+	Pos
+	Exps   []Exp
+	Blocks []*Block
+}
+
+// for Name '=' exp ',' exp [',' exp] do block end
+type ForNumStat struct {
+	Pos
+	LineOfDo int
+	VarName  string
+	InitExp  Exp
+	LimitExp Exp
+	StepExp  Exp // 省略时为 nil
+	Block    *Block
+}
+
+// for namelist in explist do block end
+type ForInStat struct {
+	Pos
+	LineOfDo int
+	NameList []string
+	ExpList  []Exp
+	Block    *Block
+}
+
+// local namelist ['=' explist]
+type LocalVarDeclStat struct {
+	Pos
+	LastLine int
+	NameList []string
+	ExpList  []Exp
+}
+
+// varlist '=' explist
+// function funcname funcbody 也表示为赋值语句
+type AssignStat struct {
+	Pos
+	LastLine int
+	VarList  []Exp // NameExp 或者 TableAccessExp
+	ExpList  []Exp
+}
+
+// local function Name funcbody
+type LocalFuncDefStat struct {
+	Pos
+	Name string
+	Exp  *FuncDefExp
+}
+
+func (*EmptyStat) statNode()        {}
+func (*BreakStat) statNode()        {}
+func (*LabelStat) statNode()        {}
+func (*GotoStat) statNode()         {}
+func (*DoStat) statNode()           {}
+func (*FuncCallExp) statNode()      {}
+func (*WhileStat) statNode()        {}
+func (*RepeatStat) statNode()       {}
+func (*IfStat) statNode()           {}
+func (*ForNumStat) statNode()       {}
+func (*ForInStat) statNode()        {}
+func (*LocalVarDeclStat) statNode() {}
+func (*AssignStat) statNode()       {}
+func (*LocalFuncDefStat) statNode() {}
@@ -0,0 +1,82 @@
+package codegen
+
+import . "github.com/anccy/luago/go/compiler/ast"
+
+// 块的作用域由调用者负责进入和退出
+func cgBlock(fi *funcInfo, node *Block) {
+	for i, stat := range node.Stats {
+		if label, ok := stat.(*LabelStat); ok {
+			cgLabelStat(fi, label, isLastLabel(fi, node, i))
+		} else {
+			cgStat(fi, stat)
+		}
+	}
+
+	if node.RetExps != nil {
+		cgRetStat(fi, node.RetExps, node.LastLine)
+	}
+}
+
+// 标签之后直到块结束都是空语句或者标签
+func isLastLabel(fi *funcInfo, node *Block, i int) bool {
+	if node.RetExps != nil || fi.scope().withUntil {
+		return false
+	}
+	for _, stat := range node.Stats[i+1:] {
+		switch stat.(type) {
+		case *EmptyStat, *LabelStat:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// 在新的作用域中生成块的代码
+func cgScopedBlock(fi *funcInfo, node *Block) {
+	fi.enterScope(false)
+	cgBlock(fi, node)
+	fi.exitScope(node.LastLine)
+}
+
+func cgRetStat(fi *funcInfo, exps []Exp, lastLine int) {
+	nExps := len(exps)
+	if nExps == 0 {
+		fi.emitReturn(lastLine, 0, 0)
+		return
+	}
+
+	if nExps == 1 {
+		if nameExp, ok := exps[0].(*NameExp); ok {
+			if r := fi.slotOfLocVar(nameExp.Name); r >= 0 {
+				fi.emitReturn(lastLine, r, 1)
+				return
+			}
+		}
+		if fcExp, ok := exps[0].(*FuncCallExp); ok {
+			r := fi.allocReg()
+			cgTailCallExp(fi, fcExp, r)
+			fi.freeReg()
+			fi.emitReturn(lastLine, r, -1)
+			return
+		}
+	}
+
+	multRet := isVarargOrFuncCall(exps[nExps-1])
+	for i, exp := range exps {
+		r := fi.allocReg()
+		if i == nExps-1 && multRet {
+			cgExp(fi, exp, r, -1)
+		} else {
+			cgExp(fi, exp, r, 1)
+		}
+	}
+	fi.freeRegs(nExps)
+
+	a := fi.usedRegs
+	if multRet {
+		fi.emitReturn(lastLine, a, -1)
+	} else {
+		fi.emitReturn(lastLine, a, nExps)
+	}
+}
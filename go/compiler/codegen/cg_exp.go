@@ -0,0 +1,322 @@
+package codegen
+
+import (
+	. "github.com/anccy/luago/go/compiler/ast"
+	. "github.com/anccy/luago/go/compiler/lexer"
+	. "github.com/anccy/luago/go/vm"
+)
+
+// kind of operands
+const (
+	ARG_CONST = 1 // const index
+	ARG_REG   = 2 // register index
+	ARG_UPVAL = 4 // upvalue index
+	ARG_RK    = ARG_REG | ARG_CONST
+	ARG_RU    = ARG_REG | ARG_UPVAL
+	ARG_RUK   = ARG_REG | ARG_UPVAL | ARG_CONST
+)
+
+// 把表达式的 n 个值放到 r[a] 开始的寄存器，n 为 -1 时保留全部值；
+// 函数调用和表构造器要用 a 之后的寄存器，调用时 a 必须是最后分配的寄存器
+func cgExp(fi *funcInfo, node Exp, a, n int) {
+	switch exp := node.(type) {
+	case *NilExp:
+		fi.emitLoadNil(exp.Line, a, n)
+	case *FalseExp:
+		fi.emitLoadBool(exp.Line, a, 0, 0)
+	case *TrueExp:
+		fi.emitLoadBool(exp.Line, a, 1, 0)
+	case *IntegerExp:
+		fi.emitLoadK(exp.Line, a, exp.Val)
+	case *FloatExp:
+		fi.emitLoadK(exp.Line, a, exp.Val)
+	case *StringExp:
+		fi.emitLoadK(exp.Line, a, exp.Str)
+	case *ParensExp:
+		cgExp(fi, exp.Exp, a, 1)
+	case *VarargExp:
+		fi.emitVararg(exp.Line, a, n)
+	case *FuncDefExp:
+		cgFuncDefExp(fi, exp, a)
+	case *TableConstructorExp:
+		cgTableConstructorExp(fi, exp, a)
+	case *UnopExp:
+		cgUnopExp(fi, exp, a)
+	case *BinopExp:
+		cgBinopExp(fi, exp, a)
+	case *ConcatExp:
+		cgConcatExp(fi, exp, a)
+	case *NameExp:
+		cgNameExp(fi, exp, a)
+	case *TableAccessExp:
+		cgTableAccessExp(fi, exp, a)
+	case *FuncCallExp:
+		cgFuncCallExp(fi, exp, a, n)
+	}
+}
+
+// f[a] := function(args) body end
+func cgFuncDefExp(fi *funcInfo, node *FuncDefExp, a int) {
+	subFI := newFuncInfo(fi, node)
+	fi.subFuncs = append(fi.subFuncs, subFI)
+
+	subFI.enterScope(false)
+	for _, param := range node.ParList {
+		subFI.addLocVar(param, 0)
+	}
+	cgBlock(subFI, node.Block)
+	subFI.emitReturn(node.LastLine, 0, 0)
+	subFI.exitScope(node.LastLine)
+
+	bx := len(fi.subFuncs) - 1
+	fi.emitClosure(node.LastLine, a, bx)
+}
+
+// lua: constructor
+// 数组部分的值先留在 r[a] 之后的寄存器里，攒够 LFIELDS_PER_FLUSH 个再用 SETLIST 存入表中
+func cgTableConstructorExp(fi *funcInfo, node *TableConstructorExp, a int) {
+	nArr := 0
+	for _, keyExp := range node.KeyExps {
+		if keyExp == nil {
+			nArr++
+		}
+	}
+	nExps := len(node.KeyExps)
+	multRet := nExps > 0 &&
+		node.KeyExps[nExps-1] == nil &&
+		isVarargOrFuncCall(node.ValExps[nExps-1])
+	if multRet {
+		nArr--
+	}
+
+	pc := fi.emitNewTable(node.Line, a, 0, 0)
+	nStored, nPending := 0, 0 // 已经存入表中的和还在寄存器里的数组元素
+	flush := func(line, b int) {
+		nStored += nPending
+		fi.emitSetList(line, a, b, (nStored-1)/LFIELDS_PER_FLUSH+1)
+		fi.freeRegs(nPending)
+		nPending = 0
+	}
+
+	for i, keyExp := range node.KeyExps {
+		valExp := node.ValExps[i]
+		if nPending == LFIELDS_PER_FLUSH {
+			flush(lastLineOf(node.ValExps[i-1]), nPending)
+		}
+		if keyExp != nil {
+			oldRegs := fi.usedRegs
+			b, _ := expToOpArg(fi, keyExp, ARG_RK)
+			c, _ := expToOpArg(fi, valExp, ARG_RK)
+			fi.usedRegs = oldRegs
+			fi.emitSetTable(lastLineOf(valExp), a, b, c)
+			continue
+		}
+
+		tmp := fi.allocReg()
+		nPending++
+		if i == nExps-1 && multRet {
+			cgExp(fi, valExp, tmp, -1)
+			flush(lastLineOf(valExp), 0)
+		} else {
+			cgExp(fi, valExp, tmp, 1)
+		}
+	}
+	if nPending > 0 {
+		flush(node.LastLine, nPending)
+	}
+
+	nRec := nExps - nArr
+	if multRet {
+		nRec--
+	}
+	fi.insts[pc] = uint32(Int2fb(nArr)<<23|Int2fb(nRec)<<14) | fi.insts[pc]
+}
+
+// r[a] := op exp
+func cgUnopExp(fi *funcInfo, node *UnopExp, a int) {
+	oldRegs := fi.usedRegs
+	b, _ := expToOpArg(fi, node.Exp, ARG_REG)
+	fi.emitUnaryOp(node.Line, node.Op, a, b)
+	fi.usedRegs = oldRegs
+}
+
+// r[a] := exp1 op exp2
+func cgBinopExp(fi *funcInfo, node *BinopExp, a int) {
+	switch node.Op {
+	case TOKEN_OP_AND, TOKEN_OP_OR:
+		oldRegs := fi.usedRegs
+
+		b, _ := expToOpArg(fi, node.Exp1, ARG_REG)
+		fi.usedRegs = oldRegs
+		if node.Op == TOKEN_OP_AND {
+			fi.emitTestSet(node.Line, a, b, 0)
+		} else {
+			fi.emitTestSet(node.Line, a, b, 1)
+		}
+		pcOfJmp := fi.emitJmp(node.Line, 0, 0)
+
+		b, _ = expToOpArg(fi, node.Exp2, ARG_REG)
+		fi.usedRegs = oldRegs
+		fi.emitMove(node.Line, a, b)
+		fi.fixSbx(pcOfJmp, fi.pc()-pcOfJmp)
+	default:
+		oldRegs := fi.usedRegs
+		b, _ := expToOpArg(fi, node.Exp1, ARG_RK)
+		c, _ := expToOpArg(fi, node.Exp2, ARG_RK)
+		fi.emitBinaryOp(node.Line, node.Op, a, b, c)
+		fi.usedRegs = oldRegs
+	}
+}
+
+// r[a] := exp1 .. exp2 .. ... expN
+// 操作数要放在连续的寄存器里，a 是最后分配的寄存器时从 a 开始放
+func cgConcatExp(fi *funcInfo, node *ConcatExp, a int) {
+	oldRegs := fi.usedRegs
+	b := a
+	if a != fi.usedRegs-1 {
+		b = fi.allocReg()
+	}
+	cgExp(fi, node.Exps[0], b, 1)
+	for _, subExp := range node.Exps[1:] {
+		tmp := fi.allocReg()
+		cgExp(fi, subExp, tmp, 1)
+	}
+	c := fi.usedRegs - 1
+	fi.usedRegs = oldRegs
+	fi.emitABC(node.Line, OP_CONCAT, a, b, c)
+}
+
+// r[a] := name
+func cgNameExp(fi *funcInfo, node *NameExp, a int) {
+	if r := fi.slotOfLocVar(node.Name); r >= 0 {
+		if r != a {
+			fi.emitMove(node.Line, a, r)
+		}
+	} else if idx := fi.indexOfUpval(node.Name); idx >= 0 {
+		fi.emitGetUpval(node.Line, a, idx)
+	} else { // x => _ENV['x']
+		cgTableAccessExp(fi, envAccess(node), a)
+	}
+}
+
+// 全局变量 x 就是 _ENV.x
+func envAccess(node *NameExp) *TableAccessExp {
+	return &TableAccessExp{
+		Pos:       node.Pos,
+		LastLine:  node.Line,
+		PrefixExp: &NameExp{Pos: node.Pos, Name: "_ENV"},
+		KeyExp:    &StringExp{Pos: node.Pos, Str: node.Name},
+	}
+}
+
+// r[a] := prefix[key]
+func cgTableAccessExp(fi *funcInfo, node *TableAccessExp, a int) {
+	oldRegs := fi.usedRegs
+	b, kindB := expToOpArg(fi, node.PrefixExp, ARG_RU)
+	c, _ := expToOpArg(fi, node.KeyExp, ARG_RK)
+	fi.usedRegs = oldRegs
+
+	if kindB == ARG_UPVAL {
+		fi.emitGetTabUp(node.LastLine, a, b, c)
+	} else {
+		fi.emitGetTable(node.LastLine, a, b, c)
+	}
+}
+
+// r[a] := f(args)
+func cgFuncCallExp(fi *funcInfo, node *FuncCallExp, a, n int) {
+	nArgs := prepFuncCall(fi, node, a)
+	fi.emitCall(node.Line, a, nArgs, n)
+}
+
+// return f(args)
+func cgTailCallExp(fi *funcInfo, node *FuncCallExp, a int) {
+	nArgs := prepFuncCall(fi, node, a)
+	fi.emitTailCall(node.Line, a, nArgs)
+}
+
+// 把函数和参数依次放到 r[a] 开始的寄存器，返回参数个数，参数个数不定时返回 -1
+func prepFuncCall(fi *funcInfo, node *FuncCallExp, a int) int {
+	nArgs := len(node.Args)
+	lastArgIsVarargOrFuncCall := false
+
+	if node.NameExp != nil {
+		// 对象是局部变量时 SELF 直接使用它的寄存器
+		b := a
+		if nameExp, ok := node.PrefixExp.(*NameExp); ok && fi.slotOfLocVar(nameExp.Name) >= 0 {
+			b = fi.slotOfLocVar(nameExp.Name)
+		} else {
+			cgExp(fi, node.PrefixExp, a, 1)
+		}
+		fi.allocReg()
+		c, k := expToOpArg(fi, node.NameExp, ARG_RK)
+		fi.emitSelf(node.Line, a, b, c)
+		if k == ARG_REG {
+			fi.freeReg()
+		}
+	} else {
+		cgExp(fi, node.PrefixExp, a, 1)
+	}
+	for i, arg := range node.Args {
+		tmp := fi.allocReg()
+		if i == nArgs-1 && isVarargOrFuncCall(arg) {
+			lastArgIsVarargOrFuncCall = true
+			cgExp(fi, arg, tmp, -1)
+		} else {
+			cgExp(fi, arg, tmp, 1)
+		}
+	}
+	fi.freeRegs(nArgs)
+
+	if node.NameExp != nil {
+		fi.freeReg()
+		nArgs++
+	}
+	if lastArgIsVarargOrFuncCall {
+		nArgs = -1
+	}
+	return nArgs
+}
+
+// 把表达式转换成指令的操作数，argKinds 是允许的操作数种类：
+// 能放进 RK 的常量、局部变量的寄存器或者 upvalue 的索引，
+// 都不行时求值到新分配的寄存器，由调用者负责释放
+func expToOpArg(fi *funcInfo, node Exp, argKinds int) (arg, argKind int) {
+	if argKinds&ARG_CONST > 0 {
+		idx := -1
+		switch x := node.(type) {
+		case *NilExp:
+			idx = fi.indexOfConstant(nil)
+		case *FalseExp:
+			idx = fi.indexOfConstant(false)
+		case *TrueExp:
+			idx = fi.indexOfConstant(true)
+		case *IntegerExp:
+			idx = fi.indexOfConstant(x.Val)
+		case *FloatExp:
+			idx = fi.indexOfConstant(x.Val)
+		case *StringExp:
+			idx = fi.indexOfConstant(x.Str)
+		}
+		if idx >= 0 && idx <= maxIndexRK {
+			return 0x100 + idx, ARG_CONST
+		}
+	}
+
+	if nameExp, ok := node.(*NameExp); ok {
+		if argKinds&ARG_REG > 0 {
+			if r := fi.slotOfLocVar(nameExp.Name); r >= 0 {
+				return r, ARG_REG
+			}
+		}
+		if argKinds&ARG_UPVAL > 0 {
+			if idx := fi.indexOfUpval(nameExp.Name); idx >= 0 {
+				return idx, ARG_UPVAL
+			}
+		}
+	}
+
+	a := fi.allocReg()
+	cgExp(fi, node, a, 1)
+	return a, ARG_REG
+}
@@ -0,0 +1,374 @@
+package codegen
+
+import (
+	. "github.com/anccy/luago/go/compiler/ast"
+	. "github.com/anccy/luago/go/compiler/lexer"
+)
+
+func cgStat(fi *funcInfo, node Stat) {
+	switch stat := node.(type) {
+	case *FuncCallStat:
+		cgFuncCallStat(fi, stat)
+	case *BreakStat:
+		fi.addGoto("break", stat.Line)
+	case *GotoStat:
+		fi.addGoto(stat.Name, stat.Line)
+	case *LabelStat:
+		cgLabelStat(fi, stat, false)
+	case *DoStat:
+		cgScopedBlock(fi, stat.Block)
+	case *WhileStat:
+		cgWhileStat(fi, stat)
+	case *RepeatStat:
+		cgRepeatStat(fi, stat)
+	case *IfStat:
+		cgIfStat(fi, stat)
+	case *ForNumStat:
+		cgForNumStat(fi, stat)
+	case *ForInStat:
+		cgForInStat(fi, stat)
+	case *AssignStat:
+		cgAssignStat(fi, stat)
+	case *LocalVarDeclStat:
+		cgLocalVarDeclStat(fi, stat)
+	case *LocalFuncDefStat:
+		cgLocalFuncDefStat(fi, stat)
+	}
+}
+
+// f(args)，丢弃全部返回值
+func cgFuncCallStat(fi *funcInfo, node *FuncCallStat) {
+	r := fi.allocReg()
+	cgFuncCallExp(fi, node, r, 0)
+	fi.freeReg()
+}
+
+// ::name::
+func cgLabelStat(fi *funcInfo, node *LabelStat, last bool) {
+	fi.checkRepeatedLabel(node.Name, node.Line)
+	fi.addLabel(node.Name, node.Line, last)
+}
+
+// 生成条件跳转，条件为假时执行返回的 JMP 指令，条件总是为真时不生成跳转，返回 -1
+func cgCondJump(fi *funcInfo, node Exp) int {
+	line := lastLineOf(node)
+	switch exp := node.(type) {
+	case *TrueExp, *IntegerExp, *FloatExp, *StringExp:
+		return -1
+	case *NilExp, *FalseExp:
+		return fi.emitJmp(line, 0, 0)
+	case *BinopExp:
+		switch exp.Op {
+		case TOKEN_OP_EQ, TOKEN_OP_NE, TOKEN_OP_LT, TOKEN_OP_LE, TOKEN_OP_GT, TOKEN_OP_GE:
+			oldRegs := fi.usedRegs
+			b, _ := expToOpArg(fi, exp.Exp1, ARG_RK)
+			c, _ := expToOpArg(fi, exp.Exp2, ARG_RK)
+			fi.usedRegs = oldRegs
+			fi.emitCompare(exp.Line, exp.Op, 0, b, c)
+			return fi.emitJmp(exp.Line, 0, 0)
+		}
+	}
+
+	oldRegs := fi.usedRegs
+	a, _ := expToOpArg(fi, node, ARG_REG)
+	fi.usedRegs = oldRegs
+	fi.emitTest(line, a, 0)
+	return fi.emitJmp(line, 0, 0)
+}
+
+/*
+	  ______________
+	 /  false? jmp  |
+	/               |
+
+while exp do block end <-'
+
+	^           \
+	|___________/
+	     jmp
+*/
+func cgWhileStat(fi *funcInfo, node *WhileStat) {
+	pcBeforeExp := fi.pc()
+	pcJmpToEnd := cgCondJump(fi, node.Exp)
+
+	fi.enterScope(true)
+	cgScopedBlock(fi, node.Block)
+	fi.emitJmp(node.Block.LastLine, 0, pcBeforeExp-fi.pc()-1)
+	fi.exitScope(node.Block.LastLine)
+
+	if pcJmpToEnd >= 0 {
+		fi.fixSbx(pcJmpToEnd, fi.pc()-pcJmpToEnd)
+	}
+}
+
+/*
+        ______________
+       |  false? jmp  |
+       V              /
+repeat block until exp
+*/
+// until 条件在循环体的作用域中，可以使用循环体中的局部变量
+func cgRepeatStat(fi *funcInfo, node *RepeatStat) {
+	pcBeforeBlock := fi.pc()
+	fi.enterScope(true)
+	scope := fi.enterScope(false)
+	scope.withUntil = true
+
+	cgBlock(fi, node.Block)
+	pcJmp := cgCondJump(fi, node.Exp)
+	if pcJmp >= 0 && scope.upval {
+		fi.patchClose(pcJmp, scope.nActVar)
+	}
+	line := lastLineOf(node.Exp)
+	fi.exitScope(line)
+	if pcJmp >= 0 {
+		fi.fixSbx(pcJmp, pcBeforeBlock-pcJmp)
+	}
+	fi.exitScope(line)
+}
+
+/*
+	  _________________       _________________       _____________
+	 / false? jmp      |     / false? jmp      |     / false? jmp  |
+	/                  V    /                  V    /              V
+
+if exp1 then block1 elseif exp2 then block2 elseif true then block3 end <-.
+
+	\                       \                       \      |
+	 \_______________________\_______________________\_____|
+	 jmp                     jmp                     jmp
+*/
+func cgIfStat(fi *funcInfo, node *IfStat) {
+	var pcJmpToEnds []int
+	for i, exp := range node.Exps {
+		pcJmpToNextExp := cgCondJump(fi, exp)
+
+		block := node.Blocks[i]
+		cgScopedBlock(fi, block)
+		if i < len(node.Exps)-1 {
+			pcJmpToEnds = append(pcJmpToEnds, fi.emitJmp(block.LastLine, 0, 0))
+		}
+
+		if pcJmpToNextExp >= 0 {
+			fi.fixSbx(pcJmpToNextExp, fi.pc()-pcJmpToNextExp)
+		}
+	}
+
+	for _, pc := range pcJmpToEnds {
+		fi.fixSbx(pc, fi.pc()-pc)
+	}
+}
+
+// 三个控制变量都是局部变量，名字以 ( 开头，源代码中不能访问它们
+func cgForNumStat(fi *funcInfo, node *ForNumStat) {
+	stepExp := node.StepExp
+	if stepExp == nil {
+		stepExp = &IntegerExp{Pos: Pos{Line: node.LineOfDo}, Val: 1}
+	}
+
+	fi.enterScope(true)
+	cgLocalVarDeclStat(fi, &LocalVarDeclStat{
+		LastLine: node.LineOfDo,
+		NameList: []string{"(for index)", "(for limit)", "(for step)"},
+		ExpList:  []Exp{node.InitExp, node.LimitExp, stepExp},
+	})
+
+	a := fi.usedRegs - 3
+	pcForPrep := fi.emitForPrep(node.LineOfDo, a, 0)
+	fi.enterScope(false)
+	fi.addLocVar(node.VarName, fi.pc()+1)
+	cgScopedBlock(fi, node.Block)
+	fi.exitScope(node.Block.LastLine)
+	pcForLoop := fi.emitForLoop(node.Line, a, 0)
+
+	fi.fixSbx(pcForPrep, pcForLoop-pcForPrep-1)
+	fi.fixSbx(pcForLoop, pcForPrep-pcForLoop)
+	fi.exitScope(node.Block.LastLine)
+}
+
+func cgForInStat(fi *funcInfo, node *ForInStat) {
+	fi.enterScope(true)
+	cgLocalVarDeclStat(fi, &LocalVarDeclStat{
+		LastLine: node.LineOfDo,
+		NameList: []string{"(for generator)", "(for state)", "(for control)"},
+		ExpList:  node.ExpList,
+	})
+	fi.checkStack(3) // TFORCALL 调用迭代器时要复制函数和两个参数
+
+	a := fi.usedRegs - 3
+	pcJmpToTFC := fi.emitJmp(node.LineOfDo, 0, 0)
+	fi.enterScope(false)
+	for _, name := range node.NameList {
+		fi.addLocVar(name, fi.pc()+1)
+	}
+	cgScopedBlock(fi, node.Block)
+	fi.exitScope(node.Block.LastLine)
+
+	fi.fixSbx(pcJmpToTFC, fi.pc()-pcJmpToTFC)
+	fi.emitTForCall(node.Line, a, len(node.NameList))
+	fi.emitTForLoop(node.Line, a+2, pcJmpToTFC-fi.pc()-1)
+	fi.exitScope(node.Block.LastLine)
+}
+
+// local function f() end 中的 f 在函数体中可见
+func cgLocalFuncDefStat(fi *funcInfo, node *LocalFuncDefStat) {
+	r := fi.addLocVar(node.Name, fi.pc()+2)
+	cgFuncDefExp(fi, node.Exp, r)
+}
+
+// 表达式的值先放到新分配的寄存器，全部求值以后它们才成为局部变量
+func cgLocalVarDeclStat(fi *funcInfo, node *LocalVarDeclStat) {
+	oldRegs := fi.usedRegs
+	adjustExps(fi, removeTailNils(node.ExpList), len(node.NameList), node.LastLine)
+	fi.usedRegs = oldRegs
+
+	startPC := fi.pc() + 1
+	for _, name := range node.NameList {
+		fi.addLocVar(name, startPC)
+	}
+}
+
+// lua: adjust_assign
+// 把表达式的值调整成 n 个，依次放到新分配的寄存器
+func adjustExps(fi *funcInfo, exps []Exp, n, line int) {
+	nExps := len(exps)
+	multRet := false
+	for i, exp := range exps {
+		a := fi.allocReg()
+		if i == nExps-1 && isVarargOrFuncCall(exp) {
+			multRet = true
+			if nExps > n {
+				cgExp(fi, exp, a, 0) // 多余的值直接丢弃
+			} else {
+				cgExp(fi, exp, a, n-nExps+1)
+				fi.allocRegs(n - nExps)
+			}
+		} else {
+			cgExp(fi, exp, a, 1)
+		}
+	}
+	if !multRet && nExps < n {
+		a := fi.allocRegs(n - nExps)
+		fi.emitLoadNil(line, a, n-nExps)
+	}
+}
+
+func cgAssignStat(fi *funcInfo, node *AssignStat) {
+	if len(node.VarList) == 1 && len(node.ExpList) == 1 {
+		cgSingleAssign(fi, node.VarList[0], node.ExpList[0], node.LastLine)
+		return
+	}
+
+	nVars := len(node.VarList)
+	oldRegs := fi.usedRegs
+
+	// 先求出全部表和键，再求出全部值，最后依次赋值，
+	// 局部变量和 upvalue 可能在这个语句中被赋值，所以表和键都复制到新的寄存器
+	tArgs := make([]int, nVars)
+	tKinds := make([]int, nVars)
+	kArgs := make([]int, nVars)
+	for i, v := range node.VarList {
+		taExp, ok := v.(*TableAccessExp)
+		if !ok {
+			nameExp := v.(*NameExp)
+			if fi.slotOfLocVar(nameExp.Name) >= 0 || fi.indexOfUpval(nameExp.Name) >= 0 {
+				continue
+			}
+			taExp = envAccess(nameExp)
+		}
+
+		if nameExp, ok := taExp.PrefixExp.(*NameExp); ok &&
+			fi.slotOfLocVar(nameExp.Name) < 0 && !isAssigned(node, nameExp.Name) {
+			if idx := fi.indexOfUpval(nameExp.Name); idx >= 0 {
+				tArgs[i], tKinds[i] = idx, ARG_UPVAL
+			}
+		}
+		if tKinds[i] != ARG_UPVAL {
+			tArgs[i], tKinds[i] = fi.allocReg(), ARG_REG
+			cgExp(fi, taExp.PrefixExp, tArgs[i], 1)
+		}
+		kArgs[i], _ = expToOpArg(fi, taExp.KeyExp, ARG_CONST)
+	}
+
+	vBase := fi.usedRegs
+	adjustExps(fi, removeTailNils(node.ExpList), nVars, node.LastLine)
+
+	line := node.LastLine
+	for i := nVars - 1; i >= 0; i-- {
+		v := vBase + i
+		if tKinds[i] == ARG_UPVAL {
+			fi.emitSetTabUp(line, tArgs[i], kArgs[i], v)
+		} else if tKinds[i] == ARG_REG {
+			fi.emitSetTable(line, tArgs[i], kArgs[i], v)
+		} else {
+			name := node.VarList[i].(*NameExp).Name
+			if r := fi.slotOfLocVar(name); r >= 0 {
+				fi.emitMove(line, r, v)
+			} else {
+				fi.emitSetUpval(line, v, fi.indexOfUpval(name))
+			}
+		}
+	}
+	fi.usedRegs = oldRegs
+}
+
+// 变量列表中有名字为 name 的变量
+func isAssigned(node *AssignStat, name string) bool {
+	for _, v := range node.VarList {
+		if nameExp, ok := v.(*NameExp); ok && nameExp.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// var = exp，值直接放到局部变量的寄存器或者作为 RK 操作数
+func cgSingleAssign(fi *funcInfo, v, exp Exp, line int) {
+	oldRegs := fi.usedRegs
+	defer func() { fi.usedRegs = oldRegs }()
+
+	if nameExp, ok := v.(*NameExp); ok {
+		if r := fi.slotOfLocVar(nameExp.Name); r >= 0 {
+			if needsTopReg(exp) {
+				tmp := fi.allocReg()
+				cgExp(fi, exp, tmp, 1)
+				fi.emitMove(line, r, tmp)
+			} else {
+				cgExp(fi, exp, r, 1)
+			}
+			return
+		}
+		if idx := fi.indexOfUpval(nameExp.Name); idx >= 0 {
+			b, _ := expToOpArg(fi, exp, ARG_REG)
+			fi.emitSetUpval(line, b, idx)
+			return
+		}
+		v = envAccess(nameExp)
+	}
+
+	taExp := v.(*TableAccessExp)
+	a, kindA := expToOpArg(fi, taExp.PrefixExp, ARG_RU)
+	b, _ := expToOpArg(fi, taExp.KeyExp, ARG_RK)
+	c, _ := expToOpArg(fi, exp, ARG_RK)
+	if kindA == ARG_UPVAL {
+		fi.emitSetTabUp(line, a, b, c)
+	} else {
+		fi.emitSetTable(line, a, b, c)
+	}
+}
+
+// 函数调用和表构造器要用目标寄存器之后的寄存器，只能放在最后分配的寄存器
+func needsTopReg(exp Exp) bool {
+	for {
+		parensExp, ok := exp.(*ParensExp)
+		if !ok {
+			break
+		}
+		exp = parensExp.Exp
+	}
+	switch exp.(type) {
+	case *FuncCallExp, *TableConstructorExp:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+// Package codegen 把语法树编译成和二进制 chunk 中一样的函数原型
+package codegen
+
+import (
+	"github.com/anccy/luago/go/binchunk"
+	. "github.com/anccy/luago/go/compiler/ast"
+	"github.com/anccy/luago/go/compiler/lexer"
+)
+
+// GenProto 生成主函数的原型，主函数是变长参数函数，唯一的 upvalue 是 _ENV；
+// goto 找不到标签、寄存器不够用等错误以 *lexer.SyntaxError 返回
+func GenProto(chunk *Block, chunkName string) (proto *binchunk.Prototype, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*lexer.SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			proto, err = nil, syntaxErr
+		}
+	}()
+
+	// 主函数是一个假想的外层函数中的闭包，_ENV 是外层函数的局部变量
+	fd := &FuncDefExp{IsVararg: true, Block: chunk}
+	fi := newFuncInfo(nil, fd)
+	fi.chunkName = chunkName
+	fi.enterScope(false)
+	fi.addLocVar("_ENV", 0)
+
+	mainFI := newFuncInfo(fi, fd)
+	fi.subFuncs = append(fi.subFuncs, mainFI)
+	mainFI.enterScope(false)
+	cgBlock(mainFI, chunk)
+	// 和 luac 一样，主函数最后的 RETURN 在最后一条语句所在的行
+	line := mainFI.curLine()
+	if line == 0 {
+		line = 1
+	}
+	mainFI.emitReturn(line, 0, 0)
+	mainFI.exitScope(chunk.LastLine)
+	return toProto(mainFI), nil
+}
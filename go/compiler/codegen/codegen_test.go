@@ -0,0 +1,165 @@
+package codegen
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/compiler/parser"
+	"github.com/anccy/luago/go/vm"
+)
+
+func compile(chunk string) (*binchunk.Prototype, error) {
+	block, err := parser.Parse(chunk, "@test.lua")
+	if err != nil {
+		return nil, err
+	}
+	return GenProto(block, "@test.lua")
+}
+
+// 和 luac -l 一样的指令列表，每行一条指令
+func listing(proto *binchunk.Prototype) string {
+	lines := make([]string, len(proto.Code))
+	for pc, code := range proto.Code {
+		i := vm.Instruction(code)
+		lines[pc] = strings.Replace(i.String(), "\t", " ", 1)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 编译结果和 luac 生成的 lua/luac.out 相同
+func TestHelloWorld(t *testing.T) {
+	src, err := os.ReadFile("../../../lua/hello_world.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := parser.Parse(string(src), "@./hello_world.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proto, err := GenProto(block, "@./hello_world.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := binchunk.ParseChunkFile("../../../lua/luac.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(proto, want) {
+		t.Errorf("hello world err, want %+v, ret %+v", want, proto)
+	}
+}
+
+func TestCodegen(t *testing.T) {
+	tests := []struct {
+		chunk string
+		want  string
+	}{
+		{"local a, b = 1", "LOADK 0 -1\nLOADNIL 1 0"},
+		{"local a; a = a + 1", "LOADNIL 0 0\nADD 0 0 -1"},
+		{"x = y", "GETTABUP 0 0 -2\nSETTABUP 0 -1 0"},
+		{"local t = {}; t.x = 1", "NEWTABLE 0 0 0\nSETTABLE 0 -1 -2"},
+		{"local t = {1, 2, x = 3}", "NEWTABLE 0 2 1\nLOADK 1 -1\nLOADK 2 -2\nSETTABLE 0 -3 -4\nSETLIST 0 2 1"},
+		{"local t = {f()}", "NEWTABLE 0 0 0\nGETTABUP 1 0 -1\nCALL 1 1 0\nSETLIST 0 0 1"},
+		{"local a, b; local c = a .. b", "LOADNIL 0 1\nMOVE 2 0\nMOVE 3 1\nCONCAT 2 2 3"},
+		{"local a; if a == 1 then a = 2 end", "LOADNIL 0 0\nEQ 0 0 -1\nJMP 0 1\nLOADK 0 -2"},
+		{"local a = x and y", "GETTABUP 1 0 -1\nTESTSET 0 1 0\nJMP 0 2\nGETTABUP 1 0 -2\nMOVE 0 1"},
+		{"local o; o:m(1)", "LOADNIL 0 0\nSELF 1 0 -1\nLOADK 3 -2\nCALL 1 3 1"},
+		{"return f(...)", "GETTABUP 0 0 -1\nVARARG 1 0\nTAILCALL 0 0 0\nRETURN 0 0"},
+		{"for i = 1, 2 do end", "LOADK 0 -1\nLOADK 1 -2\nLOADK 2 -1\nFORPREP 0 0\nFORLOOP 0 -1"},
+		{"while x do end", "GETTABUP 0 0 -1\nTEST 0 0\nJMP 0 1\nJMP 0 -4"},
+	}
+	for _, test := range tests {
+		proto, err := compile(test.chunk)
+		if err != nil {
+			t.Errorf("compile %q err: %v", test.chunk, err)
+			continue
+		}
+		if err := vm.Verify(proto); err != nil {
+			t.Errorf("verify %q err: %v", test.chunk, err)
+		}
+		// 去掉末尾的 RETURN 0 1
+		ret := strings.TrimSuffix(listing(proto), "\nRETURN 0 1")
+		if ret != test.want {
+			t.Errorf("compile %q err, want\n%s\nret\n%s", test.chunk, test.want, ret)
+		}
+	}
+}
+
+// 局部变量被闭包捕获时，离开作用域和跳出循环都要关闭 upvalue
+func TestCloseUpvalues(t *testing.T) {
+	chunk := `
+for i = 1, 3 do
+  local f = function() return i end
+  if i == 2 then break end
+end`
+	proto, err := compile(chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `LOADK 0 -1
+LOADK 1 -2
+LOADK 2 -1
+FORPREP 0 5
+CLOSURE 4 0
+EQ 0 3 -3
+JMP 0 1
+JMP 4 2
+JMP 4 0
+FORLOOP 0 -6
+RETURN 0 1`
+	if ret := listing(proto); ret != want {
+		t.Errorf("close upvalues err, want\n%s\nret\n%s", want, ret)
+	}
+	if uv := proto.Protos[0].Upvalues[0]; uv.Instack != 1 || uv.Idx != 3 {
+		t.Errorf("upvalue err, ret %+v", uv)
+	}
+}
+
+func TestGoto(t *testing.T) {
+	chunk := `
+for i = 1, 3 do
+  if i == 2 then goto continue end
+  local x = i
+  ::continue::
+end
+::top:: goto top`
+	proto, err := compile(chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `LOADK 0 -1
+LOADK 1 -2
+LOADK 2 -1
+FORPREP 0 4
+EQ 0 3 -3
+JMP 0 1
+JMP 0 1
+MOVE 4 3
+FORLOOP 0 -5
+JMP 0 -1
+RETURN 0 1`
+	if ret := listing(proto); ret != want {
+		t.Errorf("goto err, want\n%s\nret\n%s", want, ret)
+	}
+}
+
+func TestCodegenError(t *testing.T) {
+	tests := map[string]string{
+		"goto x":                               "test.lua:1: no visible label 'x' for <goto> at line 1",
+		"if x then\nbreak\nend":                "test.lua:3: <break> at line 2 not inside a loop",
+		"goto l; local a; ::l:: print(a)":      "test.lua:1: <goto l> at line 1 jumps into the scope of local 'a'",
+		"::a::\n::a::":                         "test.lua:2: label 'a' already defined on line 1",
+		"repeat goto l; local a ::l:: until a": "test.lua:1: <goto l> at line 1 jumps into the scope of local 'a'",
+		"local a" + strings.Repeat(", a", 200): "test.lua:1: too many local variables (limit is 200) in main function",
+	}
+	for chunk, want := range tests {
+		_, err := compile(chunk)
+		if err == nil || err.Error() != want {
+			t.Errorf("compile %q err, want %q, ret %v", chunk, want, err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package codegen
+
+import (
+	. "github.com/anccy/luago/go/compiler/lexer"
+	. "github.com/anccy/luago/go/vm"
+)
+
+const maxArgC = 1<<9 - 1 // lua: MAXARG_C
+
+var arithAndBitwiseBinops = map[int]int{
+	TOKEN_OP_ADD:  OP_ADD,
+	TOKEN_OP_SUB:  OP_SUB,
+	TOKEN_OP_MUL:  OP_MUL,
+	TOKEN_OP_MOD:  OP_MOD,
+	TOKEN_OP_POW:  OP_POW,
+	TOKEN_OP_DIV:  OP_DIV,
+	TOKEN_OP_IDIV: OP_IDIV,
+	TOKEN_OP_BAND: OP_BAND,
+	TOKEN_OP_BOR:  OP_BOR,
+	TOKEN_OP_BXOR: OP_BXOR,
+	TOKEN_OP_SHL:  OP_SHL,
+	TOKEN_OP_SHR:  OP_SHR,
+}
+
+// 最后一条指令的 pc
+func (self *funcInfo) pc() int {
+	return len(self.insts) - 1
+}
+
+// 修改跳转指令的 sBx
+func (self *funcInfo) fixSbx(pc, sBx int) {
+	if sBx > MAXARG_sBx || sBx < -MAXARG_sBx {
+		self.error(int(self.lineNums[pc]), "control structure too long")
+	}
+	i := self.insts[pc]
+	i = i << 18 >> 18                  // clear sBx
+	i = i | uint32(sBx+MAXARG_sBx)<<14 // reset sBx
+	self.insts[pc] = i
+}
+
+// lua: luaK_patchclose
+// 跳转的同时关闭寄存器 level 及以上的 upvalue
+func (self *funcInfo) patchClose(pc, level int) {
+	i := self.insts[pc]
+	i = i&^(0xff<<6) | uint32(level+1)<<6
+	self.insts[pc] = i
+}
+
+func (self *funcInfo) emitABC(line, opcode, a, b, c int) {
+	i := b<<23 | c<<14 | a<<6 | opcode
+	self.insts = append(self.insts, uint32(i))
+	self.lineNums = append(self.lineNums, uint32(line))
+}
+
+func (self *funcInfo) emitABx(line, opcode, a, bx int) {
+	i := bx<<14 | a<<6 | opcode
+	self.insts = append(self.insts, uint32(i))
+	self.lineNums = append(self.lineNums, uint32(line))
+}
+
+func (self *funcInfo) emitAsBx(line, opcode, a, sBx int) {
+	i := (sBx+MAXARG_sBx)<<14 | a<<6 | opcode
+	self.insts = append(self.insts, uint32(i))
+	self.lineNums = append(self.lineNums, uint32(line))
+}
+
+func (self *funcInfo) emitAx(line, opcode, ax int) {
+	i := ax<<6 | opcode
+	self.insts = append(self.insts, uint32(i))
+	self.lineNums = append(self.lineNums, uint32(line))
+}
+
+// r[a] = r[b]
+func (self *funcInfo) emitMove(line, a, b int) {
+	self.emitABC(line, OP_MOVE, a, b, 0)
+}
+
+// r[a], r[a+1], ..., r[a+n-1] = nil
+func (self *funcInfo) emitLoadNil(line, a, n int) {
+	self.emitABC(line, OP_LOADNIL, a, n-1, 0)
+}
+
+// r[a] = b; if c then pc++
+func (self *funcInfo) emitLoadBool(line, a, b, c int) {
+	self.emitABC(line, OP_LOADBOOL, a, b, c)
+}
+
+// r[a] = kst[bx]
+func (self *funcInfo) emitLoadK(line, a int, k interface{}) {
+	idx := self.indexOfConstant(k)
+	if idx <= MAXARG_Bx {
+		self.emitABx(line, OP_LOADK, a, idx)
+	} else {
+		self.emitABx(line, OP_LOADKX, a, 0)
+		self.emitAx(line, OP_EXTRAARG, idx)
+	}
+}
+
+// r[a], r[a+1], ..., r[a+n-2] = vararg
+func (self *funcInfo) emitVararg(line, a, n int) {
+	self.emitABC(line, OP_VARARG, a, n+1, 0)
+}
+
+// r[a] = emitClosure(proto[bx])
+func (self *funcInfo) emitClosure(line, a, bx int) {
+	self.emitABx(line, OP_CLOSURE, a, bx)
+}
+
+// r[a] = {}
+func (self *funcInfo) emitNewTable(line, a, nArr, nRec int) int {
+	self.emitABC(line, OP_NEWTABLE, a, Int2fb(nArr), Int2fb(nRec))
+	return self.pc()
+}
+
+// lua: luaK_setlist
+// r[a][(c-1)*FPF+i] := r[a+i], 1 <= i <= b
+func (self *funcInfo) emitSetList(line, a, b, c int) {
+	if c <= maxArgC {
+		self.emitABC(line, OP_SETLIST, a, b, c)
+	} else {
+		self.emitABC(line, OP_SETLIST, a, b, 0)
+		self.emitAx(line, OP_EXTRAARG, c)
+	}
+}
+
+// r[a] := r[b][rk(c)]
+func (self *funcInfo) emitGetTable(line, a, b, c int) {
+	self.emitABC(line, OP_GETTABLE, a, b, c)
+}
+
+// r[a][rk(b)] = rk(c)
+func (self *funcInfo) emitSetTable(line, a, b, c int) {
+	self.emitABC(line, OP_SETTABLE, a, b, c)
+}
+
+// r[a] = upval[b]
+func (self *funcInfo) emitGetUpval(line, a, b int) {
+	self.emitABC(line, OP_GETUPVAL, a, b, 0)
+}
+
+// upval[b] = r[a]
+func (self *funcInfo) emitSetUpval(line, a, b int) {
+	self.emitABC(line, OP_SETUPVAL, a, b, 0)
+}
+
+// r[a] = upval[b][rk(c)]
+func (self *funcInfo) emitGetTabUp(line, a, b, c int) {
+	self.emitABC(line, OP_GETTABUP, a, b, c)
+}
+
+// upval[a][rk(b)] = rk(c)
+func (self *funcInfo) emitSetTabUp(line, a, b, c int) {
+	self.emitABC(line, OP_SETTABUP, a, b, c)
+}
+
+// r[a], ..., r[a+c-2] = r[a](r[a+1], ..., r[a+b-1])
+func (self *funcInfo) emitCall(line, a, nArgs, nRet int) {
+	self.emitABC(line, OP_CALL, a, nArgs+1, nRet+1)
+}
+
+// return r[a](r[a+1], ... ,r[a+b-1])
+func (self *funcInfo) emitTailCall(line, a, nArgs int) {
+	self.emitABC(line, OP_TAILCALL, a, nArgs+1, 0)
+}
+
+// return r[a], ... ,r[a+b-2]
+func (self *funcInfo) emitReturn(line, a, n int) {
+	self.emitABC(line, OP_RETURN, a, n+1, 0)
+}
+
+// r[a+1] := r[b]; r[a] := r[b][rk(c)]
+func (self *funcInfo) emitSelf(line, a, b, c int) {
+	self.emitABC(line, OP_SELF, a, b, c)
+}
+
+// pc+=sBx; if (a) close all upvalues >= r[a - 1]
+func (self *funcInfo) emitJmp(line, a, sBx int) int {
+	self.emitAsBx(line, OP_JMP, a, sBx)
+	return self.pc()
+}
+
+// if not (r[a] <=> c) then pc++
+func (self *funcInfo) emitTest(line, a, c int) {
+	self.emitABC(line, OP_TEST, a, 0, c)
+}
+
+// if (r[b] <=> c) then r[a] := r[b] else pc++
+func (self *funcInfo) emitTestSet(line, a, b, c int) {
+	self.emitABC(line, OP_TESTSET, a, b, c)
+}
+
+func (self *funcInfo) emitForPrep(line, a, sBx int) int {
+	self.emitAsBx(line, OP_FORPREP, a, sBx)
+	return self.pc()
+}
+
+func (self *funcInfo) emitForLoop(line, a, sBx int) int {
+	self.emitAsBx(line, OP_FORLOOP, a, sBx)
+	return self.pc()
+}
+
+func (self *funcInfo) emitTForCall(line, a, c int) {
+	self.emitABC(line, OP_TFORCALL, a, 0, c)
+}
+
+func (self *funcInfo) emitTForLoop(line, a, sBx int) {
+	self.emitAsBx(line, OP_TFORLOOP, a, sBx)
+}
+
+// r[a] = op r[b]
+func (self *funcInfo) emitUnaryOp(line, op, a, b int) {
+	switch op {
+	case TOKEN_OP_NOT:
+		self.emitABC(line, OP_NOT, a, b, 0)
+	case TOKEN_OP_BNOT:
+		self.emitABC(line, OP_BNOT, a, b, 0)
+	case TOKEN_OP_LEN:
+		self.emitABC(line, OP_LEN, a, b, 0)
+	case TOKEN_OP_UNM:
+		self.emitABC(line, OP_UNM, a, b, 0)
+	}
+}
+
+// r[a] = rk[b] op rk[c]
+// 比较运算的结果用 LOADBOOL 物化成布尔值
+func (self *funcInfo) emitBinaryOp(line, op, a, b, c int) {
+	if opcode, found := arithAndBitwiseBinops[op]; found {
+		self.emitABC(line, opcode, a, b, c)
+	} else {
+		self.emitCompare(line, op, 1, b, c)
+		self.emitJmp(line, 0, 1)
+		self.emitLoadBool(line, a, 0, 1)
+		self.emitLoadBool(line, a, 1, 0)
+	}
+}
+
+// 比较结果等于 cond 时执行下一条指令（一般是 JMP），否则跳过它
+func (self *funcInfo) emitCompare(line, op, cond, b, c int) {
+	switch op {
+	case TOKEN_OP_EQ:
+		self.emitABC(line, OP_EQ, cond, b, c)
+	case TOKEN_OP_NE:
+		self.emitABC(line, OP_EQ, 1-cond, b, c)
+	case TOKEN_OP_LT:
+		self.emitABC(line, OP_LT, cond, b, c)
+	case TOKEN_OP_GT:
+		self.emitABC(line, OP_LT, cond, c, b)
+	case TOKEN_OP_LE:
+		self.emitABC(line, OP_LE, cond, b, c)
+	case TOKEN_OP_GE:
+		self.emitABC(line, OP_LE, cond, c, b)
+	}
+}
@@ -0,0 +1,46 @@
+package codegen
+
+import . "github.com/anccy/luago/go/compiler/ast"
+
+// 值的个数不定的表达式
+func isVarargOrFuncCall(exp Exp) bool {
+	switch exp.(type) {
+	case *VarargExp, *FuncCallExp:
+		return true
+	}
+	return false
+}
+
+// 去掉末尾的 nil，它们和不写是一样的
+func removeTailNils(exps []Exp) []Exp {
+	for n := len(exps) - 1; n >= 0; n-- {
+		if _, ok := exps[n].(*NilExp); !ok {
+			return exps[0 : n+1]
+		}
+	}
+	return nil
+}
+
+// 表达式最后一个记号所在的行
+func lastLineOf(exp Exp) int {
+	switch x := exp.(type) {
+	case *TableConstructorExp:
+		return x.LastLine
+	case *FuncDefExp:
+		return x.LastLine
+	case *TableAccessExp:
+		return x.LastLine
+	case *FuncCallExp:
+		return x.LastLine
+	case *ParensExp:
+		return lastLineOf(x.Exp)
+	case *UnopExp:
+		return lastLineOf(x.Exp)
+	case *BinopExp:
+		return lastLineOf(x.Exp2)
+	case *ConcatExp:
+		return lastLineOf(x.Exps[len(x.Exps)-1])
+	default:
+		return exp.Position().Line
+	}
+}
@@ -0,0 +1,378 @@
+package codegen
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/anccy/luago/go/compiler/ast"
+	"github.com/anccy/luago/go/compiler/lexer"
+)
+
+const (
+	maxRegs    = 255 // lua: MAXREGS
+	maxVars    = 200 // lua: LUAI_MAXVARS
+	maxUpvals  = 255 // lua: MAXUPVAL
+	maxIndexRK = 255 // lua: MAXINDEXRK
+)
+
+type upvalInfo struct {
+	locVarSlot int // 捕获外层函数的局部变量时是它的寄存器，否则为 -1
+	upvalIndex int // 捕获外层函数的 upvalue 时是它的索引，否则为 -1
+	index      int // 在本函数 upvalue 表中的索引
+}
+
+type locVarInfo struct {
+	prev     *locVarInfo // 被遮蔽的同名变量
+	name     string
+	scopeLv  int
+	slot     int
+	startPC  int
+	endPC    int
+	captured bool
+}
+
+// lua: BlockCnt
+type scopeInfo struct {
+	isLoop    bool
+	withUntil bool // repeat 的循环体，块后面还有 until 条件
+	nActVar   int  // 进入作用域时活动局部变量的个数
+	upval     bool // 作用域中有局部变量被子函数捕获
+	labels    []*labelInfo
+	gotos     []*labelInfo // 还没有找到目标的 goto
+}
+
+// lua: Labeldesc
+// 描述标签或者 goto，goto 的 pc 是它的 JMP 指令
+type labelInfo struct {
+	name    string
+	line    int
+	pc      int
+	nActVar int
+}
+
+// floatKey 作为浮点数常量在常量表中的键，这样 0.0 和 -0.0 不会被当成同一个常量
+type floatKey uint64
+
+// lua: FuncState
+type funcInfo struct {
+	chunkName string
+	parent    *funcInfo
+	subFuncs  []*funcInfo
+	usedRegs  int
+	maxRegs   int
+	scopes    []*scopeInfo
+	locVars   []*locVarInfo // 全部局部变量，用于调试信息
+	locNames  map[string]*locVarInfo
+	upvalues  map[string]upvalInfo
+	constants map[interface{}]int
+	consts    []interface{}
+	insts     []uint32
+	lineNums  []uint32
+	line      int
+	lastLine  int
+	numParams int
+	isVararg  bool
+}
+
+func newFuncInfo(parent *funcInfo, fd *ast.FuncDefExp) *funcInfo {
+	fi := &funcInfo{
+		parent:    parent,
+		locNames:  map[string]*locVarInfo{},
+		upvalues:  map[string]upvalInfo{},
+		constants: map[interface{}]int{},
+		line:      fd.Line,
+		lastLine:  fd.LastLine,
+		numParams: len(fd.ParList),
+		isVararg:  fd.IsVararg,
+	}
+	if parent != nil {
+		fi.chunkName = parent.chunkName
+	}
+	return fi
+}
+
+// 编译期发现的错误，和语法错误一样报告
+func (self *funcInfo) error(line int, format string, a ...interface{}) {
+	panic(&lexer.SyntaxError{
+		Source: self.chunkName,
+		Line:   line,
+		Msg:    fmt.Sprintf(format, a...),
+	})
+}
+
+// lua: errorlimit
+func (self *funcInfo) errorLimit(limit int, what string) {
+	where := "main function"
+	if self.line != 0 {
+		where = fmt.Sprintf("function at line %d", self.line)
+	}
+	self.error(self.curLine(), "too many %s (limit is %d) in %s", what, limit, where)
+}
+
+// 最后一条指令的行号，用于没有具体位置的错误
+func (self *funcInfo) curLine() int {
+	if n := len(self.lineNums); n > 0 {
+		return int(self.lineNums[n-1])
+	}
+	return self.line
+}
+
+/* constants */
+
+// lua: addk
+func (self *funcInfo) indexOfConstant(k interface{}) int {
+	key := k
+	if f, ok := k.(float64); ok {
+		key = floatKey(math.Float64bits(f))
+	}
+	if idx, found := self.constants[key]; found {
+		return idx
+	}
+	idx := len(self.consts)
+	self.constants[key] = idx
+	self.consts = append(self.consts, k)
+	return idx
+}
+
+/* registers */
+
+func (self *funcInfo) allocReg() int {
+	return self.allocRegs(1)
+}
+
+func (self *funcInfo) freeReg() {
+	self.freeRegs(1)
+}
+
+// lua: luaK_reserveregs
+// 返回第一个寄存器
+func (self *funcInfo) allocRegs(n int) int {
+	self.checkStack(n)
+	self.usedRegs += n
+	return self.usedRegs - n
+}
+
+func (self *funcInfo) freeRegs(n int) {
+	self.usedRegs -= n
+}
+
+// lua: luaK_checkstack
+// 保证已用寄存器之上还有 n 个寄存器
+func (self *funcInfo) checkStack(n int) {
+	newStack := self.usedRegs + n
+	if newStack > self.maxRegs {
+		if newStack >= maxRegs {
+			self.error(self.curLine(), "function or expression needs too many registers")
+		}
+		self.maxRegs = newStack
+	}
+}
+
+/* lexical scope */
+
+// lua: enterblock
+func (self *funcInfo) enterScope(isLoop bool) *scopeInfo {
+	scope := &scopeInfo{isLoop: isLoop, nActVar: self.usedRegs}
+	self.scopes = append(self.scopes, scope)
+	return scope
+}
+
+func (self *funcInfo) scope() *scopeInfo {
+	return self.scopes[len(self.scopes)-1]
+}
+
+// lua: leaveblock
+// line 是块结束的行号
+func (self *funcInfo) exitScope(line int) {
+	scope := self.scope()
+	outer := len(self.scopes) == 1
+	if !outer && scope.upval {
+		// 跳转到下一条指令，只为了关闭 upvalue
+		self.emitJmp(line, scope.nActVar+1, 0)
+	}
+	if scope.isLoop {
+		self.addLabel("break", line, false)
+	}
+
+	self.scopes = self.scopes[:len(self.scopes)-1]
+	for name, locVar := range self.locNames {
+		for locVar != nil && locVar.scopeLv > len(self.scopes) {
+			locVar.endPC = self.pc() + 1
+			locVar = locVar.prev
+		}
+		if locVar == nil {
+			delete(self.locNames, name)
+		} else {
+			self.locNames[name] = locVar
+		}
+	}
+	self.usedRegs = scope.nActVar
+
+	if !outer {
+		self.moveGotosOut(scope)
+	} else if len(scope.gotos) > 0 {
+		self.undefGoto(scope.gotos[0], line)
+	}
+}
+
+// lua: new_localvar + adjustlocalvars
+func (self *funcInfo) addLocVar(name string, startPC int) int {
+	if self.usedRegs+1 > maxVars {
+		self.errorLimit(maxVars, "local variables")
+	}
+	newVar := &locVarInfo{
+		prev:    self.locNames[name],
+		name:    name,
+		scopeLv: len(self.scopes),
+		slot:    self.allocReg(),
+		startPC: startPC,
+	}
+	self.locVars = append(self.locVars, newVar)
+	self.locNames[name] = newVar
+	return newVar.slot
+}
+
+// 没有这个局部变量时返回 -1
+func (self *funcInfo) slotOfLocVar(name string) int {
+	if locVar, found := self.locNames[name]; found {
+		return locVar.slot
+	}
+	return -1
+}
+
+// 活动局部变量中寄存器为 slot 的那个
+func (self *funcInfo) locVarOfSlot(slot int) *locVarInfo {
+	for _, locVar := range self.locNames {
+		for v := locVar; v != nil; v = v.prev {
+			if v.slot == slot {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+/* upvalues */
+
+// lua: singlevaraux
+// 没有这个 upvalue 时返回 -1
+func (self *funcInfo) indexOfUpval(name string) int {
+	if upval, ok := self.upvalues[name]; ok {
+		return upval.index
+	}
+	if self.parent == nil {
+		return -1
+	}
+	if locVar, found := self.parent.locNames[name]; found {
+		idx := self.addUpval(name, locVar.slot, -1)
+		locVar.captured = true
+		self.parent.scopes[locVar.scopeLv-1].upval = true
+		return idx
+	}
+	if uvIdx := self.parent.indexOfUpval(name); uvIdx >= 0 {
+		return self.addUpval(name, -1, uvIdx)
+	}
+	return -1
+}
+
+func (self *funcInfo) addUpval(name string, locVarSlot, upvalIndex int) int {
+	idx := len(self.upvalues)
+	if idx >= maxUpvals {
+		self.errorLimit(maxUpvals, "upvalues")
+	}
+	self.upvalues[name] = upvalInfo{locVarSlot, upvalIndex, idx}
+	return idx
+}
+
+/* labels and gotos */
+
+// lua: createlabel
+// last 表示标签后面直到块结束都是空语句，这时块中的局部变量已经不可见了
+func (self *funcInfo) addLabel(name string, line int, last bool) {
+	scope := self.scope()
+	label := &labelInfo{name, line, self.pc() + 1, self.usedRegs}
+	if last {
+		label.nActVar = scope.nActVar
+	}
+	scope.labels = append(scope.labels, label)
+
+	// 向前跳转的 goto
+	gotos := scope.gotos[:0]
+	for _, gt := range scope.gotos {
+		if gt.name == name {
+			self.closeGoto(gt, label)
+		} else {
+			gotos = append(gotos, gt)
+		}
+	}
+	scope.gotos = gotos
+}
+
+// lua: checkrepeated
+func (self *funcInfo) checkRepeatedLabel(name string, line int) {
+	for _, label := range self.scope().labels {
+		if label.name == name {
+			self.error(line, "label '%s' already defined on line %d", name, label.line)
+		}
+	}
+}
+
+// lua: gotostat
+func (self *funcInfo) addGoto(name string, line int) {
+	pc := self.emitJmp(line, 0, 0)
+	gt := &labelInfo{name, line, pc, self.usedRegs}
+	if !self.findLabel(gt) {
+		scope := self.scope()
+		scope.gotos = append(scope.gotos, gt)
+	}
+}
+
+// lua: findlabel
+// 在当前作用域已有的标签中寻找 goto 的目标，这是向后的跳转
+func (self *funcInfo) findLabel(gt *labelInfo) bool {
+	for _, label := range self.scope().labels {
+		if label.name == gt.name {
+			if gt.nActVar > label.nActVar {
+				self.patchClose(gt.pc, label.nActVar)
+			}
+			self.closeGoto(gt, label)
+			return true
+		}
+	}
+	return false
+}
+
+// lua: closegoto
+func (self *funcInfo) closeGoto(gt, label *labelInfo) {
+	if gt.nActVar < label.nActVar {
+		varName := self.locVarOfSlot(gt.nActVar).name
+		self.error(label.line, "<goto %s> at line %d jumps into the scope of local '%s'",
+			gt.name, gt.line, varName)
+	}
+	self.fixSbx(gt.pc, label.pc-gt.pc-1)
+}
+
+// lua: movegotosout
+// 作用域结束时，其中没有找到目标的 goto 移到外层作用域
+func (self *funcInfo) moveGotosOut(scope *scopeInfo) {
+	outer := self.scope()
+	for _, gt := range scope.gotos {
+		if gt.nActVar > scope.nActVar {
+			if scope.upval {
+				self.patchClose(gt.pc, scope.nActVar)
+			}
+			gt.nActVar = scope.nActVar
+		}
+		if !self.findLabel(gt) {
+			outer.gotos = append(outer.gotos, gt)
+		}
+	}
+}
+
+// lua: undefgoto
+func (self *funcInfo) undefGoto(gt *labelInfo, line int) {
+	if gt.name == "break" {
+		self.error(line, "<break> at line %d not inside a loop", gt.line)
+	}
+	self.error(line, "no visible label '%s' for <goto> at line %d", gt.name, gt.line)
+}
@@ -0,0 +1,47 @@
+// Package compiler 把 Lua 源代码编译成函数原型
+package compiler
+
+import (
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/compiler/codegen"
+	"github.com/anccy/luago/go/compiler/optimizer"
+	"github.com/anccy/luago/go/compiler/parser"
+)
+
+// Compile 编译一段 Lua 源代码，chunkName 的写法和 Prototype.Source 相同，例如 "@hello.lua"；
+// 源代码有错误时返回 *lexer.SyntaxError
+func Compile(chunk, chunkName string) (*binchunk.Prototype, error) {
+	block, err := parser.Parse(chunk, chunkName)
+	if err != nil {
+		return nil, err
+	}
+	optimizer.FoldConstants(block)
+	proto, err := codegen.GenProto(block, chunkName)
+	if err != nil {
+		return nil, err
+	}
+	optimizer.Peephole(proto)
+	return proto, nil
+}
+
+// CompileWithStats 和 Compile 一样，同时返回优化前后的指令条数；
+// 为了得到优化前的结果，源代码要多编译一次
+func CompileWithStats(chunk, chunkName string) (*binchunk.Prototype, optimizer.Stats, error) {
+	var stats optimizer.Stats
+	block, err := parser.Parse(chunk, chunkName)
+	if err != nil {
+		return nil, stats, err
+	}
+	proto, err := codegen.GenProto(block, chunkName)
+	if err != nil {
+		return nil, stats, err
+	}
+	stats.Before = optimizer.CountInstructions(proto)
+
+	proto, err = Compile(chunk, chunkName)
+	if err != nil {
+		return nil, stats, err
+	}
+	stats.After = optimizer.CountInstructions(proto)
+	return proto, stats, nil
+}
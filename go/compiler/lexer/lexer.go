@@ -0,0 +1,546 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/number"
+)
+
+// SyntaxError 是编译源代码时遇到的词法或语法错误
+type SyntaxError struct {
+	Source string // chunk 名字，和 Prototype.Source 的格式相同
+	Line   int
+	Msg    string
+}
+
+func (self *SyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", binchunk.ChunkID(self.Source), self.Line, self.Msg)
+}
+
+type Lexer struct {
+	chunk     string // 源代码
+	chunkName string
+	pos       int // 下一个字符的位置
+	line      int // 当前行号
+	lineStart int // 当前行第一个字符的位置
+	ahead     *Token
+}
+
+func NewLexer(chunk, chunkName string) *Lexer {
+	return &Lexer{chunk: chunk, chunkName: chunkName, line: 1}
+}
+
+func (self *Lexer) ChunkName() string {
+	return self.chunkName
+}
+
+// Line 返回当前行号，也就是最近读取的记号之后的位置
+func (self *Lexer) Line() int {
+	return self.line
+}
+
+// Peek 返回下一个记号，但不读取它
+func (self *Lexer) Peek() Token {
+	if self.ahead == nil {
+		tok := self.scan()
+		self.ahead = &tok
+	}
+	return *self.ahead
+}
+
+// LookAhead 返回下一个记号的种类，但不读取它
+func (self *Lexer) LookAhead() int {
+	return self.Peek().Kind
+}
+
+func (self *Lexer) NextToken() Token {
+	if self.ahead != nil {
+		tok := *self.ahead
+		self.ahead = nil
+		return tok
+	}
+	return self.scan()
+}
+
+// NextTokenOfKind 读取下一个记号，它不是 kind 种类时报错
+func (self *Lexer) NextTokenOfKind(kind int) Token {
+	tok := self.NextToken()
+	if tok.Kind != kind {
+		self.ErrorNear(tok, "%s expected", TokenName(kind))
+	}
+	return tok
+}
+
+func (self *Lexer) NextIdentifier() Token {
+	return self.NextTokenOfKind(TOKEN_IDENTIFIER)
+}
+
+// lua: luaX_syntaxerror
+// 在 tok 所在的行抛出 *SyntaxError，错误信息后面附加 near 和记号的原文
+func (self *Lexer) ErrorNear(tok Token, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	panic(&SyntaxError{self.chunkName, tok.Line, msg + " near " + tokenText(tok)})
+}
+
+// 在当前行抛出 *SyntaxError
+func (self *Lexer) Error(format string, a ...interface{}) {
+	panic(&SyntaxError{self.chunkName, self.line, fmt.Sprintf(format, a...)})
+}
+
+// lua: txtToken
+func tokenText(tok Token) string {
+	switch tok.Kind {
+	case TOKEN_IDENTIFIER, TOKEN_STRING, TOKEN_INTEGER, TOKEN_FLOAT:
+		return "'" + tok.raw + "'"
+	default:
+		if tok.raw != "" && tok.raw != tokenNames[tok.Kind] {
+			return "'" + tok.raw + "'" // 无法识别的字符
+		}
+		return TokenName(tok.Kind)
+	}
+}
+
+// lua: llex
+func (self *Lexer) scan() Token {
+	self.skipWhiteSpacesAndComments()
+
+	start := self.pos
+	tok := Token{Line: self.line, Column: start - self.lineStart + 1}
+	if start >= len(self.chunk) {
+		tok.Kind = TOKEN_EOF
+		tok.Value = tokenNames[TOKEN_EOF]
+		return tok
+	}
+
+	c := self.chunk[start]
+	switch c {
+	case ';', ',', '(', ')', ']', '{', '}', '+', '-', '*', '^', '%', '&', '|', '#':
+		self.pos++
+	case '[':
+		if sep := self.skipSep(); sep >= 0 {
+			tok.Kind = TOKEN_STRING
+			tok.Value = self.readLongString(tok, sep, false)
+			tok.raw = self.chunk[start:self.pos]
+			return tok
+		} else if sep != -1 {
+			self.errorNearRaw(start, TOKEN_STRING, "invalid long string delimiter")
+		}
+		// self.pos 已经跳过了 '['
+	case '.':
+		if self.test("...") {
+			self.pos += 3
+		} else if self.test("..") {
+			self.pos += 2
+		} else if start+1 < len(self.chunk) && isDigit(self.chunk[start+1]) {
+			return self.readNumeral(tok)
+		} else {
+			self.pos++
+		}
+	case ':', '/', '=', '<', '>', '~':
+		// 双字符记号
+		for _, op := range []string{"::", "//", "==", "<<", "<=", ">>", ">=", "~="} {
+			if self.test(op) {
+				self.pos += 2
+				break
+			}
+		}
+		if self.pos == start {
+			self.pos++
+		}
+	case '"', '\'':
+		tok.Kind = TOKEN_STRING
+		tok.Value = self.readString(tok, c)
+		tok.raw = self.chunk[start:self.pos]
+		return tok
+	default:
+		if isDigit(c) {
+			return self.readNumeral(tok)
+		}
+		if c == '_' || isLetter(c) {
+			for self.pos < len(self.chunk) && (isLetter(self.current()) || isDigit(self.current()) || self.current() == '_') {
+				self.pos++
+			}
+			tok.Value = self.chunk[start:self.pos]
+			tok.raw = tok.Value
+			if kind, found := keywords[tok.Value]; found {
+				tok.Kind = kind
+			} else {
+				tok.Kind = TOKEN_IDENTIFIER
+			}
+			return tok
+		}
+		self.pos++
+		tok.raw = self.chunk[start:self.pos]
+		if c < ' ' || c >= 0x7f { // control character or not ASCII
+			tok.raw = fmt.Sprintf("<\\%d>", c)
+		}
+		self.ErrorNear(tok, "unexpected symbol")
+	}
+
+	tok.raw = self.chunk[start:self.pos]
+	tok.Value = tok.raw
+	tok.Kind = operators[tok.raw]
+	return tok
+}
+
+var operators = map[string]int{}
+
+func init() {
+	for kind := TOKEN_VARARG; kind <= TOKEN_OP_LEN; kind++ {
+		operators[tokenNames[kind]] = kind
+	}
+}
+
+func (self *Lexer) current() byte {
+	return self.chunk[self.pos]
+}
+
+func (self *Lexer) test(s string) bool {
+	return strings.HasPrefix(self.chunk[self.pos:], s)
+}
+
+// lua: inclinenumber
+// 跳过 '\n'、'\r'、"\n\r" 或者 "\r\n"，行号加 1
+func (self *Lexer) incLineNumber() {
+	old := self.current()
+	self.pos++
+	if self.pos < len(self.chunk) && isNewLine(self.current()) && self.current() != old {
+		self.pos++
+	}
+	self.line++
+	self.lineStart = self.pos
+}
+
+func (self *Lexer) skipWhiteSpacesAndComments() {
+	for self.pos < len(self.chunk) {
+		c := self.current()
+		if isNewLine(c) {
+			self.incLineNumber()
+		} else if isWhiteSpace(c) {
+			self.pos++
+		} else if self.test("--") {
+			self.skipComment()
+		} else {
+			break
+		}
+	}
+}
+
+func (self *Lexer) skipComment() {
+	start := self.pos
+	self.pos += 2 // skip --
+	// long comment?
+	if self.pos < len(self.chunk) && self.current() == '[' {
+		if sep := self.skipSep(); sep >= 0 {
+			tok := Token{Line: self.line, Column: start - self.lineStart + 1}
+			self.readLongString(tok, sep, true)
+			return
+		}
+	}
+	// short comment
+	for self.pos < len(self.chunk) && !isNewLine(self.current()) {
+		self.pos++
+	}
+}
+
+// lua: skip_sep
+// 当前字符是 '[' 或者 ']'，跳过它和后面的 '='。'=' 之后是相同的括号时返回 '=' 的个数，
+// 否则返回 -('=' 的个数)-1
+func (self *Lexer) skipSep() int {
+	s := self.current()
+	self.pos++
+	count := 0
+	for self.pos < len(self.chunk) && self.current() == '=' {
+		self.pos++
+		count++
+	}
+	if self.pos < len(self.chunk) && self.current() == s {
+		return count
+	}
+	return -count - 1
+}
+
+// lua: read_long_string
+// 第一个 '[' 和 '=' 已经被跳过，当前字符是第二个 '['
+func (self *Lexer) readLongString(tok Token, sep int, isComment bool) string {
+	self.pos++ // skip 2nd '['
+	// string starts with a newline? skip it
+	if self.pos < len(self.chunk) && isNewLine(self.current()) {
+		self.incLineNumber()
+	}
+
+	sb := &strings.Builder{}
+	for {
+		if self.pos >= len(self.chunk) {
+			what := "string"
+			if isComment {
+				what = "comment"
+			}
+			tok.Kind, tok.Line = TOKEN_EOF, self.line
+			self.ErrorNear(tok, "unfinished long %s", what)
+		}
+		switch c := self.current(); c {
+		case ']':
+			pos := self.pos
+			if self.skipSep() == sep {
+				self.pos++ // skip 2nd ']'
+				return sb.String()
+			}
+			sb.WriteString(self.chunk[pos:self.pos])
+		case '\n', '\r':
+			sb.WriteByte('\n')
+			self.incLineNumber()
+		default:
+			sb.WriteByte(c)
+			self.pos++
+		}
+	}
+}
+
+// lua: read_string
+func (self *Lexer) readString(tok Token, del byte) string {
+	start := self.pos
+	self.pos++ // skip delimiter
+
+	sb := &strings.Builder{}
+	for {
+		if self.pos >= len(self.chunk) {
+			tok.Kind, tok.Line = TOKEN_EOF, self.line
+			self.ErrorNear(tok, "unfinished string")
+		}
+		c := self.current()
+		if c == del {
+			self.pos++
+			return sb.String()
+		}
+		switch c {
+		case '\n', '\r':
+			self.errorNearRaw(start, TOKEN_STRING, "unfinished string")
+		case '\\':
+			self.readEscape(sb, start)
+		default:
+			sb.WriteByte(c)
+			self.pos++
+		}
+	}
+}
+
+// 当前字符是 '\\'，把转义序列表示的字符写入 sb
+func (self *Lexer) readEscape(sb *strings.Builder, start int) {
+	self.pos++ // skip '\\'
+	if self.pos >= len(self.chunk) {
+		return // will raise an error next loop
+	}
+
+	c := self.current()
+	switch c {
+	case 'a':
+		sb.WriteByte('\a')
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'r':
+		sb.WriteByte('\r')
+	case 't':
+		sb.WriteByte('\t')
+	case 'v':
+		sb.WriteByte('\v')
+	case '\\', '"', '\'':
+		sb.WriteByte(c)
+	case '\n', '\r':
+		sb.WriteByte('\n')
+		self.incLineNumber()
+		return
+	case 'x':
+		sb.WriteByte(self.readHexaEscape(start))
+		return
+	case 'u':
+		sb.Write(self.readUTF8Escape(start))
+		return
+	case 'z': // zap following span of spaces
+		self.pos++
+		for self.pos < len(self.chunk) && isSpace(self.current()) {
+			if isNewLine(self.current()) {
+				self.incLineNumber()
+			} else {
+				self.pos++
+			}
+		}
+		return
+	default:
+		if !isDigit(c) {
+			self.pos++
+			self.errorNearRaw(start, TOKEN_STRING, "invalid escape sequence")
+		}
+		sb.WriteByte(self.readDecimalEscape(start))
+		return
+	}
+	self.pos++
+}
+
+// lua: readhexaesc
+// \xXX，必须有两个十六进制数字
+func (self *Lexer) readHexaEscape(start int) byte {
+	self.pos++ // skip 'x'
+	r := 0
+	for i := 0; i < 2; i++ {
+		r = r<<4 + self.hexDigit(start)
+	}
+	return byte(r)
+}
+
+// lua: readutf8esc
+// \u{XXX}，最大为 7FFFFFFF
+func (self *Lexer) readUTF8Escape(start int) []byte {
+	self.pos++ // skip 'u'
+	if self.pos >= len(self.chunk) || self.current() != '{' {
+		self.errorNearRaw(start, TOKEN_STRING, "missing '{'")
+	}
+	self.pos++
+	r := uint32(self.hexDigit(start)) // must have at least one digit
+	for self.pos < len(self.chunk) && isHexDigit(self.current()) {
+		if r > 0x7FFFFFF {
+			self.pos++
+			self.errorNearRaw(start, TOKEN_STRING, "UTF-8 value too large")
+		}
+		r = r<<4 + uint32(self.hexDigit(start))
+	}
+	if self.pos >= len(self.chunk) || self.current() != '}' {
+		self.errorNearRaw(start, TOKEN_STRING, "missing '}'")
+	}
+	self.pos++ // skip '}'
+	return utf8Esc(r)
+}
+
+// lua: readdecesc
+// \ddd，最多三个十进制数字
+func (self *Lexer) readDecimalEscape(start int) byte {
+	r := 0
+	for i := 0; i < 3 && self.pos < len(self.chunk) && isDigit(self.current()); i++ {
+		r = 10*r + int(self.current()-'0')
+		self.pos++
+	}
+	if r > 255 {
+		self.errorNearRaw(start, TOKEN_STRING, "decimal escape too large")
+	}
+	return byte(r)
+}
+
+func (self *Lexer) hexDigit(start int) int {
+	if self.pos < len(self.chunk) && isHexDigit(self.current()) {
+		c := self.current()
+		self.pos++
+		switch {
+		case c <= '9':
+			return int(c - '0')
+		case c >= 'a':
+			return int(c-'a') + 10
+		default:
+			return int(c-'A') + 10
+		}
+	}
+	if self.pos < len(self.chunk) {
+		self.pos++
+	}
+	self.errorNearRaw(start, TOKEN_STRING, "hexadecimal digit expected")
+	return 0
+}
+
+// lua: luaO_utf8esc
+// 和 Lua 一样使用最多 6 个字节的扩展 UTF-8 编码
+func utf8Esc(x uint32) []byte {
+	if x < 0x80 { // ascii?
+		return []byte{byte(x)}
+	}
+	var buf [6]byte
+	n := 0
+	mfb := uint32(0x3f) // maximum that fits in first byte
+	// add continuation bytes
+	for {
+		buf[len(buf)-1-n] = byte(0x80 | (x & 0x3f))
+		n++
+		x >>= 6   // remove added bits
+		mfb >>= 1 // now there is one less bit available in first byte
+		if x <= mfb {
+			break
+		}
+	}
+	buf[len(buf)-1-n] = byte((^mfb << 1) | x) // add first byte
+	n++
+	return buf[len(buf)-n:]
+}
+
+// lua: read_numeral
+// 和 Lua 一样先按宽松的规则读取，再用 number 包检查格式
+func (self *Lexer) readNumeral(tok Token) Token {
+	start := self.pos
+	expo := "Ee"
+	if self.test("0x") || self.test("0X") {
+		expo = "Pp"
+		self.pos += 2
+	}
+	for self.pos < len(self.chunk) {
+		c := self.current()
+		if strings.IndexByte(expo, c) >= 0 { // exponent part?
+			self.pos++
+			if self.pos < len(self.chunk) && (self.current() == '+' || self.current() == '-') {
+				self.pos++ // optional exponent sign
+			}
+		} else if isHexDigit(c) || c == '.' {
+			self.pos++
+		} else {
+			break
+		}
+	}
+
+	tok.Value = self.chunk[start:self.pos]
+	tok.raw = tok.Value
+	if _, ok := number.ParseInteger(tok.Value); ok {
+		tok.Kind = TOKEN_INTEGER
+	} else if _, ok := number.ParseFloat(tok.Value); ok {
+		tok.Kind = TOKEN_FLOAT
+	} else {
+		tok.Kind = TOKEN_FLOAT
+		self.ErrorNear(tok, "malformed number")
+	}
+	return tok
+}
+
+// 以从 start 开始到当前位置为止的原文作为 near 后的文本报错
+func (self *Lexer) errorNearRaw(start, kind int, msg string) {
+	tok := Token{Kind: kind, Line: self.line, raw: self.chunk[start:self.pos]}
+	self.ErrorNear(tok, msg)
+}
+
+func isWhiteSpace(c byte) bool {
+	switch c {
+	case '\t', '\v', '\f', ' ':
+		return true
+	}
+	return false
+}
+
+func isNewLine(c byte) bool {
+	return c == '\r' || c == '\n'
+}
+
+func isSpace(c byte) bool {
+	return isWhiteSpace(c) || isNewLine(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,167 @@
+package lexer
+
+import "testing"
+
+func scanAll(chunk string) []Token {
+	lexer := NewLexer(chunk, "@test.lua")
+	var tokens []Token
+	for {
+		tok := lexer.NextToken()
+		if tok.Kind == TOKEN_EOF {
+			return tokens
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// 返回 chunk 的词法错误信息，没有错误时返回空字符串
+func scanError(chunk string) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = r.(*SyntaxError).Error()
+		}
+	}()
+	scanAll(chunk)
+	return ""
+}
+
+func TestOperators(t *testing.T) {
+	chunk := "... .. . :: : // / >> >= > << <= < == = ~= ~ ; , ( ) [ ] { } + - * ^ % & | #"
+	kinds := []int{
+		TOKEN_VARARG, TOKEN_OP_CONCAT, TOKEN_SEP_DOT, TOKEN_SEP_LABEL, TOKEN_SEP_COLON,
+		TOKEN_OP_IDIV, TOKEN_OP_DIV, TOKEN_OP_SHR, TOKEN_OP_GE, TOKEN_OP_GT,
+		TOKEN_OP_SHL, TOKEN_OP_LE, TOKEN_OP_LT, TOKEN_OP_EQ, TOKEN_OP_ASSIGN,
+		TOKEN_OP_NE, TOKEN_OP_WAVE, TOKEN_SEP_SEMI, TOKEN_SEP_COMMA,
+		TOKEN_SEP_LPAREN, TOKEN_SEP_RPAREN, TOKEN_SEP_LBRACK, TOKEN_SEP_RBRACK,
+		TOKEN_SEP_LCURLY, TOKEN_SEP_RCURLY, TOKEN_OP_ADD, TOKEN_OP_MINUS,
+		TOKEN_OP_MUL, TOKEN_OP_POW, TOKEN_OP_MOD, TOKEN_OP_BAND, TOKEN_OP_BOR, TOKEN_OP_LEN,
+	}
+	tokens := scanAll(chunk)
+	if len(tokens) != len(kinds) {
+		t.Fatalf("token count err, want %d, ret %d", len(kinds), len(tokens))
+	}
+	for i, tok := range tokens {
+		if tok.Kind != kinds[i] {
+			t.Errorf("token %d err, want %s, ret %s", i, TokenName(kinds[i]), TokenName(tok.Kind))
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		chunk string
+		kind  int
+	}{
+		{"3", TOKEN_INTEGER},
+		{"0xff", TOKEN_INTEGER},
+		{"0xffffffffffffffffff", TOKEN_INTEGER},
+		{"9223372036854775808", TOKEN_FLOAT},
+		{"3.0", TOKEN_FLOAT},
+		{".5", TOKEN_FLOAT},
+		{"1e10", TOKEN_FLOAT},
+		{"3E-2", TOKEN_FLOAT},
+		{"0x1p4", TOKEN_FLOAT},
+		{"0xA.8p-1", TOKEN_FLOAT},
+		{"0x.1", TOKEN_FLOAT},
+	}
+	for _, test := range tests {
+		tokens := scanAll(test.chunk)
+		if len(tokens) != 1 || tokens[0].Kind != test.kind || tokens[0].Value != test.chunk {
+			t.Errorf("scan %q err, ret %v", test.chunk, tokens)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := map[string]string{
+		`'abc'`:              "abc",
+		`"a\tb\\\"\'"`:       "a\tb\\\"'",
+		`"\65\066\0671"`:     "ABC1",
+		`"\x41\x6a"`:         "Aj",
+		`"\u{48}\u{e4}"`:     "Hä",
+		`"\u{7FFFFFFF}"`:     "\xfd\xbf\xbf\xbf\xbf\xbf",
+		"\"a\\z  \n\t  b\"":  "ab",
+		"\"a\\\nb\"":         "a\nb",
+		"[[\nfirst]]":        "first",
+		"[==[a]]b]=]c]==]":   "a]]b]=]c",
+		"[[a\r\nb\n\rc\rd]]": "a\nb\nc\nd",
+	}
+	for chunk, want := range tests {
+		tokens := scanAll(chunk)
+		if len(tokens) != 1 || tokens[0].Kind != TOKEN_STRING || tokens[0].Value != want {
+			t.Errorf("scan %q err, want %q, ret %v", chunk, want, tokens)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	chunk := "local a = 1 -- comment\n--[==[ long\ncomment ]==]  return [[\nx\n]]\r\n  goto done"
+	want := []struct {
+		kind, line, column int
+	}{
+		{TOKEN_KW_LOCAL, 1, 1},
+		{TOKEN_IDENTIFIER, 1, 7},
+		{TOKEN_OP_ASSIGN, 1, 9},
+		{TOKEN_INTEGER, 1, 11},
+		{TOKEN_KW_RETURN, 3, 15},
+		{TOKEN_STRING, 3, 22},
+		{TOKEN_KW_GOTO, 6, 3},
+		{TOKEN_IDENTIFIER, 6, 8},
+	}
+	tokens := scanAll(chunk)
+	if len(tokens) != len(want) {
+		t.Fatalf("token count err, want %d, ret %d", len(want), len(tokens))
+	}
+	for i, tok := range tokens {
+		w := want[i]
+		if tok.Kind != w.kind || tok.Line != w.line || tok.Column != w.column {
+			t.Errorf("token %q err, want %s %d:%d, ret %d:%d",
+				tok.Value, TokenName(w.kind), w.line, w.column, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestLexError(t *testing.T) {
+	tests := map[string]string{
+		`x = "abc`:             "test.lua:1: unfinished string near <eof>",
+		"x = 'abc\ny'":         "test.lua:1: unfinished string near ''abc'",
+		"x = [[abc\n":          "test.lua:2: unfinished long string near <eof>",
+		"--[[ abc\n\n":         "test.lua:3: unfinished long comment near <eof>",
+		"x = [=abc":            "test.lua:1: invalid long string delimiter near '[='",
+		`x = "\q"`:             `test.lua:1: invalid escape sequence near '"\q'`,
+		`x = "\x4g"`:           `test.lua:1: hexadecimal digit expected near '"\x4g'`,
+		`x = "\256"`:           `test.lua:1: decimal escape too large near '"\256'`,
+		`x = "\u{80000000}"`:   `test.lua:1: UTF-8 value too large near '"\u{80000000'`,
+		`x = "\u{41"`:          `test.lua:1: missing '}' near '"\u{41'`,
+		`x = "\u41"`:           `test.lua:1: missing '{' near '"\u'`,
+		"x = 3.4.5":            "test.lua:1: malformed number near '3.4.5'",
+		"x = 0x":               "test.lua:1: malformed number near '0x'",
+		"\n\nx = @":            "test.lua:3: unexpected symbol near '@'",
+		"x = \x01":             `test.lua:1: unexpected symbol near '<\1>'`,
+		"local x <const> = 1;": "",
+	}
+	for chunk, want := range tests {
+		if msg := scanError(chunk); msg != want {
+			t.Errorf("scan %q err, want %q, ret %q", chunk, want, msg)
+		}
+	}
+}
+
+func TestLookAhead(t *testing.T) {
+	lexer := NewLexer("a.b", "=test")
+	if kind := lexer.LookAhead(); kind != TOKEN_IDENTIFIER {
+		t.Errorf("LookAhead err, ret %s", TokenName(kind))
+	}
+	if tok := lexer.NextIdentifier(); tok.Value != "a" {
+		t.Errorf("NextIdentifier err, ret %q", tok.Value)
+	}
+	lexer.NextTokenOfKind(TOKEN_SEP_DOT)
+
+	defer func() {
+		err, _ := recover().(*SyntaxError)
+		if err == nil || err.Error() != "test:1: '(' expected near 'b'" {
+			t.Errorf("NextTokenOfKind err, ret %v", err)
+		}
+	}()
+	lexer.NextTokenOfKind(TOKEN_SEP_LPAREN)
+}
@@ -0,0 +1,281 @@
+package optimizer
+
+import (
+	"math"
+
+	. "github.com/anccy/luago/go/compiler/ast"
+	. "github.com/anccy/luago/go/compiler/lexer"
+	"github.com/anccy/luago/go/number"
+)
+
+// lua: constfolding
+// FoldConstants 在语法树上原地折叠常量表达式，运算规则和虚拟机相同；
+// 会出错的运算（除以零、对非整数做位运算）以及结果为 nan 或浮点数零的运算不折叠，留到运行时
+func FoldConstants(block *Block) {
+	foldBlock(block)
+}
+
+func foldBlock(block *Block) {
+	for _, stat := range block.Stats {
+		foldStat(stat)
+	}
+	foldExps(block.RetExps)
+}
+
+func foldStat(node Stat) {
+	switch stat := node.(type) {
+	case *FuncCallStat:
+		foldExp(stat)
+	case *DoStat:
+		foldBlock(stat.Block)
+	case *WhileStat:
+		stat.Exp = foldExp(stat.Exp)
+		foldBlock(stat.Block)
+	case *RepeatStat:
+		foldBlock(stat.Block)
+		stat.Exp = foldExp(stat.Exp)
+	case *IfStat:
+		foldExps(stat.Exps)
+		for _, block := range stat.Blocks {
+			foldBlock(block)
+		}
+	case *ForNumStat:
+		stat.InitExp = foldExp(stat.InitExp)
+		stat.LimitExp = foldExp(stat.LimitExp)
+		if stat.StepExp != nil {
+			stat.StepExp = foldExp(stat.StepExp)
+		}
+		foldBlock(stat.Block)
+	case *ForInStat:
+		foldExps(stat.ExpList)
+		foldBlock(stat.Block)
+	case *LocalVarDeclStat:
+		foldExps(stat.ExpList)
+	case *AssignStat:
+		foldExps(stat.VarList)
+		foldExps(stat.ExpList)
+	case *LocalFuncDefStat:
+		foldBlock(stat.Exp.Block)
+	}
+}
+
+func foldExps(exps []Exp) {
+	for i, exp := range exps {
+		exps[i] = foldExp(exp)
+	}
+}
+
+// 返回折叠后的表达式，子表达式原地替换
+func foldExp(node Exp) Exp {
+	switch exp := node.(type) {
+	case *ParensExp:
+		exp.Exp = foldExp(exp.Exp)
+		if isConstant(exp.Exp) {
+			return exp.Exp
+		}
+	case *UnopExp:
+		exp.Exp = foldExp(exp.Exp)
+		return foldUnop(exp)
+	case *BinopExp:
+		exp.Exp1 = foldExp(exp.Exp1)
+		exp.Exp2 = foldExp(exp.Exp2)
+		return foldBinop(exp)
+	case *ConcatExp:
+		foldExps(exp.Exps)
+	case *TableConstructorExp:
+		for i, keyExp := range exp.KeyExps {
+			if keyExp != nil {
+				exp.KeyExps[i] = foldExp(keyExp)
+			}
+		}
+		foldExps(exp.ValExps)
+	case *FuncDefExp:
+		foldBlock(exp.Block)
+	case *TableAccessExp:
+		exp.PrefixExp = foldExp(exp.PrefixExp)
+		exp.KeyExp = foldExp(exp.KeyExp)
+	case *FuncCallExp:
+		exp.PrefixExp = foldExp(exp.PrefixExp)
+		foldExps(exp.Args)
+	}
+	return node
+}
+
+// 值在编译时就确定的表达式
+func isConstant(exp Exp) bool {
+	switch exp.(type) {
+	case *NilExp, *TrueExp, *FalseExp, *IntegerExp, *FloatExp, *StringExp:
+		return true
+	}
+	return false
+}
+
+func foldUnop(exp *UnopExp) Exp {
+	switch exp.Op {
+	case TOKEN_OP_NOT:
+		if isConstant(exp.Exp) {
+			if isTruthy(exp.Exp) {
+				return &FalseExp{Pos: exp.Pos}
+			}
+			return &TrueExp{Pos: exp.Pos}
+		}
+	case TOKEN_OP_UNM:
+		switch x := exp.Exp.(type) {
+		case *IntegerExp:
+			return &IntegerExp{Pos: exp.Pos, Val: -x.Val}
+		case *FloatExp:
+			return floatExp(exp.Pos, -x.Val, exp)
+		}
+	case TOKEN_OP_BNOT:
+		if i, ok := toInteger(exp.Exp); ok {
+			return &IntegerExp{Pos: exp.Pos, Val: ^i}
+		}
+	}
+	return exp
+}
+
+func foldBinop(exp *BinopExp) Exp {
+	switch exp.Op {
+	case TOKEN_OP_AND, TOKEN_OP_OR:
+		return foldLogical(exp)
+	case TOKEN_OP_BAND, TOKEN_OP_BOR, TOKEN_OP_BXOR, TOKEN_OP_SHL, TOKEN_OP_SHR:
+		return foldBitwise(exp)
+	case TOKEN_OP_ADD, TOKEN_OP_SUB, TOKEN_OP_MUL, TOKEN_OP_MOD, TOKEN_OP_IDIV,
+		TOKEN_OP_DIV, TOKEN_OP_POW:
+		return foldArith(exp)
+	}
+	return exp
+}
+
+// 左操作数是常量时 and/or 的结果已经确定
+func foldLogical(exp *BinopExp) Exp {
+	if !isConstant(exp.Exp1) {
+		return exp
+	}
+	if isTruthy(exp.Exp1) == (exp.Op == TOKEN_OP_OR) {
+		return exp.Exp1
+	}
+	switch exp.Exp2.(type) {
+	case *FuncCallExp, *VarargExp:
+		// 作为 and/or 的操作数时只取第一个值
+		return &ParensExp{Pos: exp.Exp2.Position(), Exp: exp.Exp2}
+	}
+	return exp.Exp2
+}
+
+func foldBitwise(exp *BinopExp) Exp {
+	a, ok1 := toInteger(exp.Exp1)
+	b, ok2 := toInteger(exp.Exp2)
+	if !ok1 || !ok2 {
+		return exp
+	}
+	var i int64
+	switch exp.Op {
+	case TOKEN_OP_BAND:
+		i = a & b
+	case TOKEN_OP_BOR:
+		i = a | b
+	case TOKEN_OP_BXOR:
+		i = a ^ b
+	case TOKEN_OP_SHL:
+		i = number.ShiftLeft(a, b)
+	case TOKEN_OP_SHR:
+		i = number.ShiftRight(a, b)
+	}
+	return &IntegerExp{Pos: exp.Pos, Val: i}
+}
+
+func foldArith(exp *BinopExp) Exp {
+	// 两个整数做 / 和 ^ 以外的运算，结果是整数
+	if x, ok := exp.Exp1.(*IntegerExp); ok {
+		if y, ok := exp.Exp2.(*IntegerExp); ok {
+			a, b := x.Val, y.Val
+			switch exp.Op {
+			case TOKEN_OP_ADD:
+				return &IntegerExp{Pos: exp.Pos, Val: a + b}
+			case TOKEN_OP_SUB:
+				return &IntegerExp{Pos: exp.Pos, Val: a - b}
+			case TOKEN_OP_MUL:
+				return &IntegerExp{Pos: exp.Pos, Val: a * b}
+			case TOKEN_OP_MOD:
+				if b != 0 {
+					return &IntegerExp{Pos: exp.Pos, Val: number.IMod(a, b)}
+				}
+				return exp
+			case TOKEN_OP_IDIV:
+				if b != 0 {
+					return &IntegerExp{Pos: exp.Pos, Val: number.IFloorDiv(a, b)}
+				}
+				return exp
+			}
+		}
+	}
+
+	a, ok1 := toFloat(exp.Exp1)
+	b, ok2 := toFloat(exp.Exp2)
+	if !ok1 || !ok2 {
+		return exp
+	}
+	var f float64
+	switch exp.Op {
+	case TOKEN_OP_ADD:
+		f = a + b
+	case TOKEN_OP_SUB:
+		f = a - b
+	case TOKEN_OP_MUL:
+		f = a * b
+	case TOKEN_OP_POW:
+		f = math.Pow(a, b)
+	case TOKEN_OP_DIV, TOKEN_OP_MOD, TOKEN_OP_IDIV:
+		if b == 0 {
+			return exp
+		}
+		switch exp.Op {
+		case TOKEN_OP_DIV:
+			f = a / b
+		case TOKEN_OP_MOD:
+			f = number.FMod(a, b)
+		default:
+			f = number.FFloorDiv(a, b)
+		}
+	}
+	return floatExp(exp.Pos, f, exp)
+}
+
+// 结果为 nan 或者零（可能是 -0.0）时不折叠，返回原来的表达式
+func floatExp(pos Pos, f float64, orig Exp) Exp {
+	if math.IsNaN(f) || f == 0 {
+		return orig
+	}
+	return &FloatExp{Pos: pos, Val: f}
+}
+
+// 只有 nil 和 false 为假
+func isTruthy(exp Exp) bool {
+	switch exp.(type) {
+	case *NilExp, *FalseExp:
+		return false
+	}
+	return true
+}
+
+// 整数，或者能精确转换成整数的浮点数
+func toInteger(exp Exp) (int64, bool) {
+	switch x := exp.(type) {
+	case *IntegerExp:
+		return x.Val, true
+	case *FloatExp:
+		return number.FloatToInteger(x.Val)
+	}
+	return 0, false
+}
+
+func toFloat(exp Exp) (float64, bool) {
+	switch x := exp.(type) {
+	case *IntegerExp:
+		return float64(x.Val), true
+	case *FloatExp:
+		return x.Val, true
+	}
+	return 0, false
+}
@@ -0,0 +1,152 @@
+package optimizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/compiler/codegen"
+	"github.com/anccy/luago/go/compiler/parser"
+	"github.com/anccy/luago/go/vm"
+)
+
+func compile(t *testing.T, chunk string, fold, peephole bool) *binchunk.Prototype {
+	block, err := parser.Parse(chunk, "@test.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fold {
+		FoldConstants(block)
+	}
+	proto, err := codegen.GenProto(block, "@test.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peephole {
+		Peephole(proto)
+	}
+	return proto
+}
+
+func listing(proto *binchunk.Prototype) string {
+	lines := make([]string, len(proto.Code))
+	for pc, code := range proto.Code {
+		i := vm.Instruction(code)
+		lines[pc] = strings.Replace(i.String(), "\t", " ", 1)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		chunk string
+		want  interface{} // 折叠后的常量，nil 表示不能折叠
+	}{
+		{"return 1 + 2", int64(3)},
+		{"return 7 // 2", int64(3)},
+		{"return -7 % 3", int64(2)},
+		{"return 7 / 2", 3.5},
+		{"return 2 ^ 0.5 * 2", 2.8284271247461903},
+		{"return 1.0 | 2", int64(3)},
+		{"return ~0", int64(-1)},
+		{"return 1 << 64", int64(0)},
+		{"return 1 << -9223372036854775808", int64(0)},       // math.mininteger
+		{"return 1 >> (-9223372036854775807 - 1)", int64(0)}, // math.mininteger
+		{"return -(2)", int64(-2)},
+		{"return (3 - 1) * 4", int64(8)},
+		{"return 9223372036854775807 + 1", int64(-9223372036854775808)},
+		{"return 1 // 0", nil},
+		{"return 1 % 0", nil},
+		{"return 1.0 / 0", nil},
+		{"return 1.5 | 2", nil},
+		{"return -0.0", nil},
+		{"return 0 / 0", nil},
+		{"return '1' + 2", nil},
+	}
+	for _, test := range tests {
+		proto := compile(t, test.chunk, true, false)
+		// 折叠之后只剩 LOADK 和 RETURN
+		folded := vm.Instruction(proto.Code[0]).Opcode() == vm.OP_LOADK &&
+			vm.Instruction(proto.Code[1]).Opcode() == vm.OP_RETURN
+		if test.want == nil {
+			if folded {
+				t.Errorf("fold %q err, want no fold, ret %v", test.chunk, proto.Constants)
+			}
+			continue
+		}
+		if !folded || len(proto.Constants) != 1 || proto.Constants[0] != test.want {
+			t.Errorf("fold %q err, want %v, ret %v", test.chunk, test.want, proto.Constants)
+		}
+	}
+}
+
+func TestFoldLogical(t *testing.T) {
+	tests := []struct {
+		chunk string
+		want  string
+	}{
+		{"local a = nil and x", "LOADNIL 0 0"},
+		{"local a = 1 or x", "LOADK 0 -1"},
+		{"local a = not nil", "LOADBOOL 0 1 0"},
+		{"local a = false or f()", "GETTABUP 0 0 -1\nCALL 0 1 2"},
+		{"local a, b = true and f()", "GETTABUP 0 0 -1\nCALL 0 1 2\nLOADNIL 1 0"},
+	}
+	for _, test := range tests {
+		proto := compile(t, test.chunk, true, false)
+		ret := strings.TrimSuffix(listing(proto), "\nRETURN 0 1")
+		if ret != test.want {
+			t.Errorf("fold %q err, want\n%s\nret\n%s", test.chunk, test.want, ret)
+		}
+	}
+}
+
+func TestPeephole(t *testing.T) {
+	tests := []struct {
+		chunk string
+		want  string
+	}{
+		// 跳到 JMP 的 JMP 直接跳到最终目标
+		{"local a; if a then if a then a = 1 end else a = 2 end",
+			"LOADNIL 0 0\nTEST 0 0\nJMP 0 4\nTEST 0 0\nJMP 0 3\nLOADK 0 -1\nJMP 0 1\nLOADK 0 -2\nRETURN 0 1"},
+		// return 之后的指令执行不到
+		{"do return 1 end local a = 2",
+			"LOADK 0 -1\nRETURN 0 2"},
+		// 跳到下一条指令的 JMP 什么也不做
+		{"for i = 1, 2 do goto c; ::c:: end",
+			"LOADK 0 -1\nLOADK 1 -2\nLOADK 2 -1\nFORPREP 0 0\nFORLOOP 0 -1\nRETURN 0 1"},
+		// 条件跳转后面的 JMP 不能去掉
+		{"local a; if a == 1 then end",
+			"LOADNIL 0 0\nEQ 0 0 -1\nJMP 0 0\nRETURN 0 1"},
+	}
+	for _, test := range tests {
+		proto := compile(t, test.chunk, false, true)
+		if ret := listing(proto); ret != test.want {
+			t.Errorf("peephole %q err, want\n%s\nret\n%s", test.chunk, test.want, ret)
+		}
+	}
+}
+
+// 去掉指令之后行号和局部变量的作用范围跟着调整
+func TestPeepholeDebugInfo(t *testing.T) {
+	proto := compile(t, "do return end\nlocal a = 1\nlocal b = 2", false, true)
+	if len(proto.Code) != 1 || len(proto.LineInfo) != 1 {
+		t.Fatalf("dead code err, ret\n%s", listing(proto))
+	}
+	for _, locVar := range proto.LocVars {
+		if locVar.StartPC != 1 || locVar.EndPC != 1 {
+			t.Errorf("locvar err, want [1, 1], ret %+v", locVar)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	before := compile(t, "local f = function() do return 1 + 2 end return 3 end", false, false)
+	after := compile(t, "local f = function() do return 1 + 2 end return 3 end", true, true)
+	stats := Stats{CountInstructions(before), CountInstructions(after)}
+	if stats.Before != 7 || stats.After != 4 {
+		t.Errorf("stats err, want 7 -> 4, ret %v", stats)
+	}
+	if want := "instructions: 7 -> 4 (42.9% removed)"; stats.String() != want {
+		t.Errorf("stats err, want %q, ret %q", want, stats.String())
+	}
+}
@@ -0,0 +1,163 @@
+package optimizer
+
+import (
+	"github.com/anccy/luago/go/binchunk"
+	. "github.com/anccy/luago/go/vm"
+)
+
+// Peephole 原地优化函数原型及其嵌套函数的指令：
+// 跳转到无条件跳转的指令直接跳到最终目标，去掉跳到下一条指令的 JMP，
+// 去掉 RETURN 之后等执行不到的指令，同时修正行号和局部变量的调试信息
+func Peephole(proto *binchunk.Prototype) {
+	threadJumps(proto.Code)
+	removeDeadCode(proto)
+	for _, p := range proto.Protos {
+		Peephole(p)
+	}
+}
+
+// 带 sBx 跳转偏移的指令
+func isJump(op int) bool {
+	switch op {
+	case OP_JMP, OP_FORPREP, OP_FORLOOP, OP_TFORLOOP:
+		return true
+	}
+	return false
+}
+
+// 条件成立时跳过下一条指令
+func isSkip(i Instruction) bool {
+	switch i.Opcode() {
+	case OP_EQ, OP_LT, OP_LE, OP_TEST, OP_TESTSET:
+		return true
+	case OP_LOADBOOL:
+		_, _, c := i.ABC()
+		return c != 0
+	}
+	return false
+}
+
+func jumpTarget(code []uint32, pc int) int {
+	_, sBx := Instruction(code[pc]).AsBx()
+	return pc + 1 + sBx
+}
+
+func setJumpTarget(code []uint32, pc, target int) {
+	i := Instruction(code[pc])
+	a, _ := i.AsBx()
+	code[pc] = uint32((target-pc-1+MAXARG_sBx)<<14 | a<<6 | i.Opcode())
+}
+
+func setJumpA(code []uint32, pc, a int) {
+	code[pc] = code[pc]&^(0xff<<6) | uint32(a)<<6
+}
+
+// 消除跳转到跳转的情况。JMP 的 A 不为 0 时还要关闭 upvalue，
+// 两个 JMP 合并时关闭较低层级以上的全部 upvalue，其他跳转指令只能越过不关闭 upvalue 的 JMP
+func threadJumps(code []uint32) {
+	for pc := range code {
+		op := Instruction(code[pc]).Opcode()
+		if !isJump(op) || op == OP_FORPREP {
+			continue
+		}
+		for n := 0; n < len(code); n++ { // 避免死循环
+			target := jumpTarget(code, pc)
+			if target == pc || Instruction(code[target]).Opcode() != OP_JMP {
+				break
+			}
+			a, _ := Instruction(code[pc]).AsBx()
+			ta, _ := Instruction(code[target]).AsBx()
+			if ta != 0 {
+				if op != OP_JMP {
+					break
+				}
+				if a == 0 || ta < a {
+					setJumpA(code, pc, ta)
+				}
+			}
+			setJumpTarget(code, pc, jumpTarget(code, target))
+		}
+	}
+}
+
+// 去掉执行不到的指令和不关闭 upvalue 的空跳转，然后修正跳转偏移和调试信息
+func removeDeadCode(proto *binchunk.Prototype) {
+	code := proto.Code
+	keep := reachable(code)
+	for pc := range code {
+		i := Instruction(code[pc])
+		if keep[pc] && i == Instruction(OP_JMP|MAXARG_sBx<<14) &&
+			(pc == 0 || !isSkip(Instruction(code[pc-1]))) {
+			keep[pc] = false
+		}
+	}
+
+	// newPCs[pc] 是 pc 处或者之后第一条保留下来的指令的新位置
+	newPCs := make([]int, len(code)+1)
+	n := 0
+	for pc := range code {
+		newPCs[pc] = n
+		if keep[pc] {
+			n++
+		}
+	}
+	newPCs[len(code)] = n
+	if n == len(code) {
+		return
+	}
+
+	newCode := make([]uint32, 0, n)
+	var newLineInfo []uint32
+	for pc := range code {
+		if !keep[pc] {
+			continue
+		}
+		if isJump(Instruction(code[pc]).Opcode()) {
+			target := newPCs[jumpTarget(code, pc)]
+			setJumpTarget(code, pc, target-newPCs[pc]+pc)
+		}
+		newCode = append(newCode, code[pc])
+		if len(proto.LineInfo) > 0 {
+			newLineInfo = append(newLineInfo, proto.LineInfo[pc])
+		}
+	}
+	proto.Code = newCode
+	if len(proto.LineInfo) > 0 {
+		proto.LineInfo = newLineInfo
+	}
+	for i := range proto.LocVars {
+		locVar := &proto.LocVars[i]
+		locVar.StartPC = uint32(newPCs[locVar.StartPC])
+		locVar.EndPC = uint32(newPCs[locVar.EndPC])
+	}
+}
+
+// 从第一条指令开始能够执行到的指令
+func reachable(code []uint32) []bool {
+	visited := make([]bool, len(code))
+	work := []int{0}
+	for len(work) > 0 {
+		pc := work[len(work)-1]
+		work = work[:len(work)-1]
+		if pc < 0 || pc >= len(code) || visited[pc] {
+			continue
+		}
+		visited[pc] = true
+
+		i := Instruction(code[pc])
+		switch op := i.Opcode(); op {
+		case OP_RETURN:
+		case OP_JMP, OP_FORPREP:
+			work = append(work, jumpTarget(code, pc))
+		case OP_FORLOOP, OP_TFORLOOP:
+			work = append(work, pc+1, jumpTarget(code, pc))
+		default:
+			// 跳过的下一条指令也保留，否则跳过的就是另一条指令了
+			work = append(work, pc+1)
+			if isSkip(i) {
+				work = append(work, pc+2)
+			}
+		}
+	}
+	return visited
+}
@@ -0,0 +1,30 @@
+package optimizer
+
+import (
+	"fmt"
+
+	"github.com/anccy/luago/go/binchunk"
+)
+
+// Stats 记录优化前后的指令条数，包括嵌套函数的指令
+type Stats struct {
+	Before int
+	After  int
+}
+
+func (self Stats) String() string {
+	saved := 0.0
+	if self.Before > 0 {
+		saved = float64(self.Before-self.After) * 100 / float64(self.Before)
+	}
+	return fmt.Sprintf("instructions: %d -> %d (%.1f%% removed)", self.Before, self.After, saved)
+}
+
+// CountInstructions 返回函数原型及其嵌套函数的指令总数
+func CountInstructions(proto *binchunk.Prototype) int {
+	n := len(proto.Code)
+	for _, p := range proto.Protos {
+		n += CountInstructions(p)
+	}
+	return n
+}
@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"github.com/anccy/luago/go/compiler/ast"
+	. "github.com/anccy/luago/go/compiler/lexer"
+)
+
+// block ::= {stat} [retstat]
+func (self *parser) parseBlock() *ast.Block {
+	block := &ast.Block{Pos: pos(self.lexer.Peek())}
+	for !self.blockFollow(true) {
+		if self.lexer.LookAhead() == TOKEN_KW_RETURN {
+			block.RetExps = self.parseRetExps()
+			break // 'return' must be last statement
+		}
+		block.Stats = append(block.Stats, self.parseStat())
+	}
+	block.LastLine = self.lexer.Line()
+	return block
+}
+
+// retstat ::= return [explist] [';']
+func (self *parser) parseRetExps() []ast.Exp {
+	self.lexer.NextTokenOfKind(TOKEN_KW_RETURN)
+	if self.blockFollow(true) {
+		return []ast.Exp{}
+	}
+	if self.lexer.LookAhead() == TOKEN_SEP_SEMI {
+		self.lexer.NextToken()
+		return []ast.Exp{}
+	}
+
+	exps := self.parseExpList()
+	if self.lexer.LookAhead() == TOKEN_SEP_SEMI {
+		self.lexer.NextToken()
+	}
+	return exps
+}
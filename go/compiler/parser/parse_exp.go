@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"github.com/anccy/luago/go/compiler/ast"
+	. "github.com/anccy/luago/go/compiler/lexer"
+	"github.com/anccy/luago/go/number"
+)
+
+// lua: priority
+// 二元运算符的左右优先级，右优先级小于左优先级的运算符是右结合的
+var binaryPriority = map[int]struct{ left, right int }{
+	TOKEN_OP_ADD:    {10, 10},
+	TOKEN_OP_SUB:    {10, 10},
+	TOKEN_OP_MUL:    {11, 11},
+	TOKEN_OP_MOD:    {11, 11},
+	TOKEN_OP_POW:    {14, 13}, // right associative
+	TOKEN_OP_DIV:    {11, 11},
+	TOKEN_OP_IDIV:   {11, 11},
+	TOKEN_OP_BAND:   {6, 6},
+	TOKEN_OP_BOR:    {4, 4},
+	TOKEN_OP_BXOR:   {5, 5},
+	TOKEN_OP_SHL:    {7, 7},
+	TOKEN_OP_SHR:    {7, 7},
+	TOKEN_OP_CONCAT: {9, 8}, // right associative
+	TOKEN_OP_EQ:     {3, 3},
+	TOKEN_OP_LT:     {3, 3},
+	TOKEN_OP_LE:     {3, 3},
+	TOKEN_OP_NE:     {3, 3},
+	TOKEN_OP_GT:     {3, 3},
+	TOKEN_OP_GE:     {3, 3},
+	TOKEN_OP_AND:    {2, 2},
+	TOKEN_OP_OR:     {1, 1},
+}
+
+const unaryPriority = 12 // priority for unary operators
+
+// explist ::= exp {',' exp}
+func (self *parser) parseExpList() []ast.Exp {
+	exps := []ast.Exp{self.parseExp()}
+	for self.lexer.LookAhead() == TOKEN_SEP_COMMA {
+		self.lexer.NextToken()
+		exps = append(exps, self.parseExp())
+	}
+	return exps
+}
+
+func (self *parser) parseExp() ast.Exp {
+	return self.parseSubExp(0)
+}
+
+// lua: subexpr
+// subexpr -> (simpleexp | unop subexpr) { binop subexpr }
+// 只读取优先级大于 limit 的二元运算符
+func (self *parser) parseSubExp(limit int) ast.Exp {
+	var exp ast.Exp
+	switch tok := self.lexer.Peek(); tok.Kind {
+	case TOKEN_OP_NOT, TOKEN_OP_UNM, TOKEN_OP_LEN, TOKEN_OP_BNOT:
+		self.lexer.NextToken()
+		operand := self.parseSubExp(unaryPriority)
+		exp = &ast.UnopExp{Pos: pos(tok), Op: tok.Kind, Exp: operand}
+	default:
+		exp = self.parseSimpleExp()
+	}
+
+	// expand while operators have priorities higher than 'limit'
+	for {
+		op := self.lexer.Peek()
+		priority, ok := binaryPriority[op.Kind]
+		if !ok || priority.left <= limit {
+			return exp
+		}
+		self.lexer.NextToken()
+		exp2 := self.parseSubExp(priority.right)
+		exp = newBinopExp(op, exp, exp2)
+	}
+}
+
+// 把连续的 .. 合并成一个 ConcatExp
+func newBinopExp(op Token, exp1, exp2 ast.Exp) ast.Exp {
+	if op.Kind != TOKEN_OP_CONCAT {
+		return &ast.BinopExp{Pos: pos(op), Op: op.Kind, Exp1: exp1, Exp2: exp2}
+	}
+	// .. 是右结合的，exp2 可能已经是 ConcatExp
+	if concat, ok := exp2.(*ast.ConcatExp); ok {
+		concat.Pos = pos(op)
+		concat.Exps = append([]ast.Exp{exp1}, concat.Exps...)
+		return concat
+	}
+	return &ast.ConcatExp{Pos: pos(op), Exps: []ast.Exp{exp1, exp2}}
+}
+
+// lua: simpleexp
+// simpleexp -> FLT | INT | STRING | nil | true | false | ... | constructor | FUNCTION body | suffixedexp
+func (self *parser) parseSimpleExp() ast.Exp {
+	switch tok := self.lexer.Peek(); tok.Kind {
+	case TOKEN_VARARG:
+		self.lexer.NextToken()
+		if !self.varargs[len(self.varargs)-1] {
+			self.lexer.ErrorNear(tok, "cannot use '...' outside a vararg function")
+		}
+		return &ast.VarargExp{Pos: pos(tok)}
+	case TOKEN_KW_NIL:
+		self.lexer.NextToken()
+		return &ast.NilExp{Pos: pos(tok)}
+	case TOKEN_KW_TRUE:
+		self.lexer.NextToken()
+		return &ast.TrueExp{Pos: pos(tok)}
+	case TOKEN_KW_FALSE:
+		self.lexer.NextToken()
+		return &ast.FalseExp{Pos: pos(tok)}
+	case TOKEN_INTEGER:
+		self.lexer.NextToken()
+		i, _ := number.ParseInteger(tok.Value)
+		return &ast.IntegerExp{Pos: pos(tok), Val: i}
+	case TOKEN_FLOAT:
+		self.lexer.NextToken()
+		f, _ := number.ParseFloat(tok.Value)
+		return &ast.FloatExp{Pos: pos(tok), Val: f}
+	case TOKEN_STRING:
+		self.lexer.NextToken()
+		return &ast.StringExp{Pos: pos(tok), Str: tok.Value}
+	case TOKEN_SEP_LCURLY:
+		return self.parseTableConstructorExp()
+	case TOKEN_KW_FUNCTION:
+		self.lexer.NextToken()
+		return self.parseFuncBody(tok)
+	default:
+		return self.parsePrefixExp()
+	}
+}
+
+// lua: suffixedexp
+// suffixedexp -> primaryexp { '.' NAME | '[' exp ']' | ':' NAME funcargs | funcargs }
+// primaryexp -> NAME | '(' expr ')'
+func (self *parser) parsePrefixExp() ast.Exp {
+	var exp ast.Exp
+	switch tok := self.lexer.NextToken(); tok.Kind {
+	case TOKEN_IDENTIFIER:
+		exp = &ast.NameExp{Pos: pos(tok), Name: tok.Value}
+	case TOKEN_SEP_LPAREN:
+		inner := self.parseExp()
+		self.checkMatch(TOKEN_SEP_RPAREN, TOKEN_SEP_LPAREN, tok.Line)
+		exp = &ast.ParensExp{Pos: pos(tok), Exp: inner}
+	default:
+		self.lexer.ErrorNear(tok, "unexpected symbol")
+	}
+
+	for {
+		switch self.lexer.LookAhead() {
+		case TOKEN_SEP_DOT: // fieldsel
+			self.lexer.NextToken()
+			name := self.lexer.NextIdentifier()
+			exp = &ast.TableAccessExp{
+				Pos:       exp.Position(),
+				LastLine:  name.Line,
+				PrefixExp: exp,
+				KeyExp:    &ast.StringExp{Pos: pos(name), Str: name.Value},
+			}
+		case TOKEN_SEP_LBRACK: // '[' exp ']'
+			self.lexer.NextToken()
+			key := self.parseExp()
+			rb := self.lexer.NextTokenOfKind(TOKEN_SEP_RBRACK)
+			exp = &ast.TableAccessExp{
+				Pos:       exp.Position(),
+				LastLine:  rb.Line,
+				PrefixExp: exp,
+				KeyExp:    key,
+			}
+		case TOKEN_SEP_COLON: // ':' NAME funcargs
+			self.lexer.NextToken()
+			name := self.lexer.NextIdentifier()
+			args, lastLine := self.parseArgs()
+			exp = &ast.FuncCallExp{
+				Pos:       exp.Position(),
+				LastLine:  lastLine,
+				PrefixExp: exp,
+				NameExp:   &ast.StringExp{Pos: pos(name), Str: name.Value},
+				Args:      args,
+			}
+		case TOKEN_SEP_LPAREN, TOKEN_STRING, TOKEN_SEP_LCURLY: // funcargs
+			args, lastLine := self.parseArgs()
+			exp = &ast.FuncCallExp{
+				Pos:       exp.Position(),
+				LastLine:  lastLine,
+				PrefixExp: exp,
+				Args:      args,
+			}
+		default:
+			return exp
+		}
+	}
+}
+
+// args ::=  '(' [explist] ')' | tableconstructor | LiteralString
+func (self *parser) parseArgs() (args []ast.Exp, lastLine int) {
+	switch tok := self.lexer.Peek(); tok.Kind {
+	case TOKEN_SEP_LPAREN:
+		self.lexer.NextToken()
+		if self.lexer.LookAhead() != TOKEN_SEP_RPAREN {
+			args = self.parseExpList()
+		}
+		lastLine = self.checkMatch(TOKEN_SEP_RPAREN, TOKEN_SEP_LPAREN, tok.Line).Line
+	case TOKEN_SEP_LCURLY:
+		tc := self.parseTableConstructorExp()
+		args, lastLine = []ast.Exp{tc}, tc.LastLine
+	case TOKEN_STRING:
+		self.lexer.NextToken()
+		args = []ast.Exp{&ast.StringExp{Pos: pos(tok), Str: tok.Value}}
+		lastLine = tok.Line
+	default:
+		self.lexer.ErrorNear(tok, "function arguments expected")
+	}
+	return
+}
+
+// tableconstructor ::= '{' [fieldlist] '}'
+// fieldlist ::= field {fieldsep field} [fieldsep]
+// fieldsep ::= ',' | ';'
+func (self *parser) parseTableConstructorExp() *ast.TableConstructorExp {
+	tok := self.lexer.NextTokenOfKind(TOKEN_SEP_LCURLY)
+	exp := &ast.TableConstructorExp{Pos: pos(tok)}
+	for self.lexer.LookAhead() != TOKEN_SEP_RCURLY {
+		k, v := self.parseField()
+		exp.KeyExps = append(exp.KeyExps, k)
+		exp.ValExps = append(exp.ValExps, v)
+		if sep := self.lexer.LookAhead(); sep != TOKEN_SEP_COMMA && sep != TOKEN_SEP_SEMI {
+			break
+		}
+		self.lexer.NextToken()
+	}
+	exp.LastLine = self.checkMatch(TOKEN_SEP_RCURLY, TOKEN_SEP_LCURLY, tok.Line).Line
+	return exp
+}
+
+// field ::= '[' exp ']' '=' exp | Name '=' exp | exp
+func (self *parser) parseField() (k, v ast.Exp) {
+	if self.lexer.LookAhead() == TOKEN_SEP_LBRACK {
+		self.lexer.NextToken()
+		k = self.parseExp()
+		self.lexer.NextTokenOfKind(TOKEN_SEP_RBRACK)
+		self.lexer.NextTokenOfKind(TOKEN_OP_ASSIGN)
+		return k, self.parseExp()
+	}
+
+	exp := self.parseExp()
+	if name, ok := exp.(*ast.NameExp); ok && self.lexer.LookAhead() == TOKEN_OP_ASSIGN {
+		// Name '=' exp => '[' LiteralString ']' = exp
+		self.lexer.NextToken()
+		k = &ast.StringExp{Pos: name.Pos, Str: name.Name}
+		return k, self.parseExp()
+	}
+	return nil, exp
+}
+
+// funcbody ::= '(' [parlist] ')' block end
+// tok 是已经读取的 function 记号
+func (self *parser) parseFuncBody(tok Token) *ast.FuncDefExp {
+	self.lexer.NextTokenOfKind(TOKEN_SEP_LPAREN)
+	parList, isVararg := self.parseParList()
+	self.lexer.NextTokenOfKind(TOKEN_SEP_RPAREN)
+
+	self.varargs = append(self.varargs, isVararg)
+	block := self.parseBlock()
+	self.varargs = self.varargs[:len(self.varargs)-1]
+	end := self.checkMatch(TOKEN_KW_END, TOKEN_KW_FUNCTION, tok.Line)
+
+	return &ast.FuncDefExp{
+		Pos:      pos(tok),
+		LastLine: end.Line,
+		ParList:  parList,
+		IsVararg: isVararg,
+		Block:    block,
+	}
+}
+
+// parlist ::= namelist [',' '...'] | '...'
+func (self *parser) parseParList() (names []string, isVararg bool) {
+	if self.lexer.LookAhead() == TOKEN_SEP_RPAREN {
+		return nil, false
+	}
+	for {
+		switch tok := self.lexer.NextToken(); tok.Kind {
+		case TOKEN_IDENTIFIER:
+			names = append(names, tok.Value)
+		case TOKEN_VARARG:
+			return names, true
+		default:
+			self.lexer.ErrorNear(tok, "<name> expected")
+		}
+		if self.lexer.LookAhead() != TOKEN_SEP_COMMA {
+			return names, false
+		}
+		self.lexer.NextToken()
+	}
+}
@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"github.com/anccy/luago/go/compiler/ast"
+	. "github.com/anccy/luago/go/compiler/lexer"
+)
+
+// lua: statement
+func (self *parser) parseStat() ast.Stat {
+	switch self.lexer.LookAhead() {
+	case TOKEN_SEP_SEMI:
+		return &ast.EmptyStat{Pos: pos(self.lexer.NextToken())}
+	case TOKEN_KW_BREAK:
+		return &ast.BreakStat{Pos: pos(self.lexer.NextToken())}
+	case TOKEN_SEP_LABEL:
+		return self.parseLabelStat()
+	case TOKEN_KW_GOTO:
+		return self.parseGotoStat()
+	case TOKEN_KW_DO:
+		return self.parseDoStat()
+	case TOKEN_KW_WHILE:
+		return self.parseWhileStat()
+	case TOKEN_KW_REPEAT:
+		return self.parseRepeatStat()
+	case TOKEN_KW_IF:
+		return self.parseIfStat()
+	case TOKEN_KW_FOR:
+		return self.parseForStat()
+	case TOKEN_KW_FUNCTION:
+		return self.parseFuncDefStat()
+	case TOKEN_KW_LOCAL:
+		return self.parseLocalStat()
+	default:
+		return self.parseAssignOrFuncCallStat()
+	}
+}
+
+// '::' Name '::'
+func (self *parser) parseLabelStat() *ast.LabelStat {
+	tok := self.lexer.NextTokenOfKind(TOKEN_SEP_LABEL)
+	name := self.lexer.NextIdentifier()
+	self.lexer.NextTokenOfKind(TOKEN_SEP_LABEL)
+	return &ast.LabelStat{Pos: pos(tok), Name: name.Value}
+}
+
+// goto Name
+func (self *parser) parseGotoStat() *ast.GotoStat {
+	tok := self.lexer.NextTokenOfKind(TOKEN_KW_GOTO)
+	name := self.lexer.NextIdentifier()
+	return &ast.GotoStat{Pos: pos(tok), Name: name.Value}
+}
+
+// do block end
+func (self *parser) parseDoStat() *ast.DoStat {
+	tok := self.lexer.NextTokenOfKind(TOKEN_KW_DO)
+	block := self.parseBlock()
+	self.checkMatch(TOKEN_KW_END, TOKEN_KW_DO, tok.Line)
+	return &ast.DoStat{Pos: pos(tok), Block: block}
+}
+
+// while exp do block end
+func (self *parser) parseWhileStat() *ast.WhileStat {
+	tok := self.lexer.NextTokenOfKind(TOKEN_KW_WHILE)
+	exp := self.parseExp()
+	self.lexer.NextTokenOfKind(TOKEN_KW_DO)
+	block := self.parseBlock()
+	self.checkMatch(TOKEN_KW_END, TOKEN_KW_WHILE, tok.Line)
+	return &ast.WhileStat{Pos: pos(tok), Exp: exp, Block: block}
+}
+
+// repeat block until exp
+func (self *parser) parseRepeatStat() *ast.RepeatStat {
+	tok := self.lexer.NextTokenOfKind(TOKEN_KW_REPEAT)
+	block := self.parseBlock()
+	self.checkMatch(TOKEN_KW_UNTIL, TOKEN_KW_REPEAT, tok.Line)
+	exp := self.parseExp()
+	return &ast.RepeatStat{Pos: pos(tok), Block: block, Exp: exp}
+}
+
+// if exp then block {elseif exp then block} [else block] end
+func (self *parser) parseIfStat() *ast.IfStat {
+	tok := self.lexer.NextTokenOfKind(TOKEN_KW_IF)
+	stat := &ast.IfStat{Pos: pos(tok)}
+	stat.Exps = append(stat.Exps, self.parseExp())
+	self.lexer.NextTokenOfKind(TOKEN_KW_THEN)
+	stat.Blocks = append(stat.Blocks, self.parseBlock())
+
+	for self.lexer.LookAhead() == TOKEN_KW_ELSEIF {
+		self.lexer.NextToken()
+		stat.Exps = append(stat.Exps, self.parseExp())
+		self.lexer.NextTokenOfKind(TOKEN_KW_THEN)
+		stat.Blocks = append(stat.Blocks, self.parseBlock())
+	}
+
+	// else block => elseif true then block
+	if self.lexer.LookAhead() == TOKEN_KW_ELSE {
+		elseTok := self.lexer.NextToken()
+		stat.Exps = append(stat.Exps, &ast.TrueExp{Pos: pos(elseTok)})
+		stat.Blocks = append(stat.Blocks, self.parseBlock())
+	}
+
+	self.checkMatch(TOKEN_KW_END, TOKEN_KW_IF, tok.Line)
+	return stat
+}
+
+// for Name '=' exp ',' exp [',' exp] do block end
+// for namelist in explist do block end
+func (self *parser) parseForStat() ast.Stat {
+	tok := self.lexer.NextTokenOfKind(TOKEN_KW_FOR)
+	name := self.lexer.NextIdentifier()
+	switch self.lexer.LookAhead() {
+	case TOKEN_OP_ASSIGN:
+		return self.parseForNumStat(tok, name.Value)
+	case TOKEN_SEP_COMMA, TOKEN_KW_IN:
+		return self.parseForInStat(tok, name.Value)
+	default:
+		self.lexer.ErrorNear(self.lexer.Peek(), "'=' or 'in' expected")
+		return nil
+	}
+}
+
+func (self *parser) parseForNumStat(forTok Token, varName string) *ast.ForNumStat {
+	stat := &ast.ForNumStat{Pos: pos(forTok), VarName: varName}
+	self.lexer.NextTokenOfKind(TOKEN_OP_ASSIGN)
+	stat.InitExp = self.parseExp()
+	self.lexer.NextTokenOfKind(TOKEN_SEP_COMMA)
+	stat.LimitExp = self.parseExp()
+	if self.lexer.LookAhead() == TOKEN_SEP_COMMA {
+		self.lexer.NextToken()
+		stat.StepExp = self.parseExp()
+	}
+	stat.LineOfDo = self.lexer.NextTokenOfKind(TOKEN_KW_DO).Line
+	stat.Block = self.parseBlock()
+	self.checkMatch(TOKEN_KW_END, TOKEN_KW_FOR, forTok.Line)
+	return stat
+}
+
+func (self *parser) parseForInStat(forTok Token, name0 string) *ast.ForInStat {
+	stat := &ast.ForInStat{Pos: pos(forTok)}
+	stat.NameList = self.parseNameList(name0)
+	self.lexer.NextTokenOfKind(TOKEN_KW_IN)
+	stat.ExpList = self.parseExpList()
+	stat.LineOfDo = self.lexer.NextTokenOfKind(TOKEN_KW_DO).Line
+	stat.Block = self.parseBlock()
+	self.checkMatch(TOKEN_KW_END, TOKEN_KW_FOR, forTok.Line)
+	return stat
+}
+
+// namelist ::= Name {',' Name}，第一个名字已经读取
+func (self *parser) parseNameList(name0 string) []string {
+	names := []string{name0}
+	for self.lexer.LookAhead() == TOKEN_SEP_COMMA {
+		self.lexer.NextToken()
+		names = append(names, self.lexer.NextIdentifier().Value)
+	}
+	return names
+}
+
+// function funcname funcbody
+// funcname ::= Name {'.' Name} [':' Name]
+//
+// function t.a.b.c:f(params) body end
+// =>
+// t.a.b.c.f = function(self, params) body end
+func (self *parser) parseFuncDefStat() *ast.AssignStat {
+	tok := self.lexer.NextTokenOfKind(TOKEN_KW_FUNCTION)
+	fnExp, hasColon := self.parseFuncName()
+	fdExp := self.parseFuncBody(tok)
+	if hasColon { // insert self
+		fdExp.ParList = append([]string{"self"}, fdExp.ParList...)
+	}
+	return &ast.AssignStat{
+		Pos:      pos(tok),
+		LastLine: tok.Line,
+		VarList:  []ast.Exp{fnExp},
+		ExpList:  []ast.Exp{fdExp},
+	}
+}
+
+func (self *parser) parseFuncName() (exp ast.Exp, hasColon bool) {
+	name := self.lexer.NextIdentifier()
+	exp = &ast.NameExp{Pos: pos(name), Name: name.Value}
+
+	for self.lexer.LookAhead() == TOKEN_SEP_DOT || self.lexer.LookAhead() == TOKEN_SEP_COLON {
+		sep := self.lexer.NextToken()
+		name := self.lexer.NextIdentifier()
+		exp = &ast.TableAccessExp{
+			Pos:       exp.Position(),
+			LastLine:  name.Line,
+			PrefixExp: exp,
+			KeyExp:    &ast.StringExp{Pos: pos(name), Str: name.Value},
+		}
+		if sep.Kind == TOKEN_SEP_COLON {
+			return exp, true
+		}
+	}
+	return exp, false
+}
+
+// local function Name funcbody
+// local namelist ['=' explist]
+func (self *parser) parseLocalStat() ast.Stat {
+	tok := self.lexer.NextTokenOfKind(TOKEN_KW_LOCAL)
+	if self.lexer.LookAhead() == TOKEN_KW_FUNCTION {
+		fnTok := self.lexer.NextToken()
+		name := self.lexer.NextIdentifier()
+		fdExp := self.parseFuncBody(fnTok)
+		return &ast.LocalFuncDefStat{Pos: pos(tok), Name: name.Value, Exp: fdExp}
+	}
+
+	stat := &ast.LocalVarDeclStat{Pos: pos(tok)}
+	stat.NameList = self.parseNameList(self.lexer.NextIdentifier().Value)
+	if self.lexer.LookAhead() == TOKEN_OP_ASSIGN {
+		self.lexer.NextToken()
+		stat.ExpList = self.parseExpList()
+	}
+	stat.LastLine = self.lexer.Line()
+	return stat
+}
+
+// varlist '=' explist
+// functioncall
+func (self *parser) parseAssignOrFuncCallStat() ast.Stat {
+	exp := self.parsePrefixExp()
+	next := self.lexer.Peek()
+	if next.Kind != TOKEN_OP_ASSIGN && next.Kind != TOKEN_SEP_COMMA {
+		if fc, ok := exp.(*ast.FuncCallExp); ok {
+			return fc
+		}
+		self.lexer.ErrorNear(next, "syntax error")
+	}
+
+	stat := &ast.AssignStat{Pos: exp.Position()}
+	stat.VarList = []ast.Exp{self.checkVar(exp)}
+	for self.lexer.LookAhead() == TOKEN_SEP_COMMA {
+		self.lexer.NextToken()
+		stat.VarList = append(stat.VarList, self.checkVar(self.parsePrefixExp()))
+	}
+	self.lexer.NextTokenOfKind(TOKEN_OP_ASSIGN)
+	stat.ExpList = self.parseExpList()
+	stat.LastLine = self.lexer.Line()
+	return stat
+}
+
+// 只有变量可以被赋值
+func (self *parser) checkVar(exp ast.Exp) ast.Exp {
+	switch exp.(type) {
+	case *ast.NameExp, *ast.TableAccessExp:
+		return exp
+	}
+	self.lexer.ErrorNear(self.lexer.Peek(), "syntax error")
+	return nil
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"github.com/anccy/luago/go/compiler/ast"
+	. "github.com/anccy/luago/go/compiler/lexer"
+)
+
+type parser struct {
+	lexer   *Lexer
+	varargs []bool // 正在解析的各层函数是否有变长参数，最后一个是当前函数
+}
+
+// Parse 把源代码解析成 AST，chunkName 的格式和 Prototype.Source 相同，例如 "@hello.lua"。
+// 源代码有语法错误时返回 *lexer.SyntaxError
+func Parse(chunk, chunkName string) (block *ast.Block, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = syntaxErr
+		}
+	}()
+
+	p := &parser{
+		lexer:   NewLexer(chunk, chunkName),
+		varargs: []bool{true}, // main function is always vararg
+	}
+	block = p.parseBlock()
+	p.lexer.NextTokenOfKind(TOKEN_EOF)
+	return block, nil
+}
+
+func pos(tok Token) ast.Pos {
+	return ast.Pos{Line: tok.Line, Column: tok.Column}
+}
+
+// lua: block_follow
+func (self *parser) blockFollow(withUntil bool) bool {
+	switch self.lexer.LookAhead() {
+	case TOKEN_KW_ELSE, TOKEN_KW_ELSEIF, TOKEN_KW_END, TOKEN_EOF:
+		return true
+	case TOKEN_KW_UNTIL:
+		return withUntil
+	default:
+		return false
+	}
+}
+
+// lua: check_match
+// 读取 what 记号，它用于结束从 where 行的 who 记号开始的结构
+func (self *parser) checkMatch(what, who, where int) Token {
+	tok := self.lexer.NextToken()
+	if tok.Kind != what {
+		if where == tok.Line {
+			self.lexer.ErrorNear(tok, "%s expected", TokenName(what))
+		} else {
+			self.lexer.ErrorNear(tok, "%s expected (to close %s at line %d)",
+				TokenName(what), TokenName(who), where)
+		}
+	}
+	return tok
+}
@@ -0,0 +1,214 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/anccy/luago/go/compiler/ast"
+	"github.com/anccy/luago/go/compiler/lexer"
+)
+
+// 把表达式转换成便于比较的前缀形式
+func sexp(exp ast.Exp) string {
+	switch x := exp.(type) {
+	case *ast.NilExp:
+		return "nil"
+	case *ast.TrueExp:
+		return "true"
+	case *ast.FalseExp:
+		return "false"
+	case *ast.VarargExp:
+		return "..."
+	case *ast.IntegerExp:
+		return fmt.Sprint(x.Val)
+	case *ast.FloatExp:
+		return fmt.Sprintf("%g", x.Val)
+	case *ast.StringExp:
+		return fmt.Sprintf("%q", x.Str)
+	case *ast.NameExp:
+		return x.Name
+	case *ast.UnopExp:
+		return fmt.Sprintf("(%s %s)", opName(x.Op), sexp(x.Exp))
+	case *ast.BinopExp:
+		return fmt.Sprintf("(%s %s %s)", opName(x.Op), sexp(x.Exp1), sexp(x.Exp2))
+	case *ast.ConcatExp:
+		return "(.. " + sexps(x.Exps) + ")"
+	case *ast.ParensExp:
+		return "[" + sexp(x.Exp) + "]"
+	case *ast.TableAccessExp:
+		return fmt.Sprintf("%s[%s]", sexp(x.PrefixExp), sexp(x.KeyExp))
+	case *ast.FuncCallExp:
+		if x.NameExp != nil {
+			return fmt.Sprintf("%s:%s(%s)", sexp(x.PrefixExp), x.NameExp.Str, sexps(x.Args))
+		}
+		return fmt.Sprintf("%s(%s)", sexp(x.PrefixExp), sexps(x.Args))
+	case *ast.TableConstructorExp:
+		fields := make([]string, len(x.ValExps))
+		for i, v := range x.ValExps {
+			if k := x.KeyExps[i]; k != nil {
+				fields[i] = sexp(k) + "=" + sexp(v)
+			} else {
+				fields[i] = sexp(v)
+			}
+		}
+		return "{" + strings.Join(fields, " ") + "}"
+	case *ast.FuncDefExp:
+		params := strings.Join(x.ParList, " ")
+		if x.IsVararg {
+			params += " ..."
+		}
+		return fmt.Sprintf("(function (%s) %d)", strings.TrimSpace(params), len(x.Block.Stats))
+	default:
+		return fmt.Sprintf("%T", exp)
+	}
+}
+
+func opName(op int) string {
+	return strings.Trim(lexer.TokenName(op), "'")
+}
+
+func sexps(exps []ast.Exp) string {
+	strs := make([]string, len(exps))
+	for i, exp := range exps {
+		strs[i] = sexp(exp)
+	}
+	return strings.Join(strs, " ")
+}
+
+func mustParse(t *testing.T, chunk string) *ast.Block {
+	block, err := Parse(chunk, "@test.lua")
+	if err != nil {
+		t.Fatalf("parse %q err: %v", chunk, err)
+	}
+	return block
+}
+
+func TestParseExp(t *testing.T) {
+	tests := map[string]string{
+		"1 + 2 * 3":               `(+ 1 (* 2 3))`,
+		"2 ^ 3 ^ 2":               `(^ 2 (^ 3 2))`,
+		"-x ^ 2":                  `(- (^ x 2))`,
+		"not a == b":              `(== (not a) b)`,
+		"a .. b .. c":             `(.. a b c)`,
+		"(a .. b) .. c":           `(.. [(.. a b)] c)`,
+		"a + b .. c + d":          `(.. (+ a b) (+ c d))`,
+		"a or b and c":            `(or a (and b c))`,
+		"a < b == c":              `(== (< a b) c)`,
+		"a | b ~ c & d << 1":      `(| a (~ b (& c (<< d 1))))`,
+		"~a // b % -c":            `(% (// (~ a) b) (- c))`,
+		"#t.x[1]":                 `(# t["x"][1])`,
+		"0x10, 1e2, 'a', ...":     `16`,
+		"f 'x' {1} (2)":           `f("x")({1})(2)`,
+		"obj:m(1, 2).f":           `obj:m(1 2)["f"]`,
+		"{1, x = 2, [3] = 4; 5,}": `{1 "x"=2 3=4 5}`,
+		"function(a, b, ...) end": `(function (a b ...) 0)`,
+		"nil, true, false":        `nil`,
+		"(f())":                   `[f()]`,
+	}
+	for src, want := range tests {
+		block := mustParse(t, "return "+src)
+		if ret := sexp(block.RetExps[0]); ret != want {
+			t.Errorf("parse %q err, want %s, ret %s", src, want, ret)
+		}
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	chunk := `
+local a, b = 1
+local function f(...) return ... end
+function t.a.b:m(x) end
+a, t[1] = f()
+print("hello")
+do ; end
+while a do break end
+repeat local x until x
+if a then elseif b then else end
+for i = 1, 10, 2 do end
+for k, v in pairs(t) do end
+::top:: goto top
+return
+`
+	want := []string{
+		"*ast.LocalVarDeclStat", "*ast.LocalFuncDefStat", "*ast.AssignStat", "*ast.AssignStat",
+		"*ast.FuncCallExp", "*ast.DoStat", "*ast.WhileStat", "*ast.RepeatStat", "*ast.IfStat",
+		"*ast.ForNumStat", "*ast.ForInStat", "*ast.LabelStat", "*ast.GotoStat",
+	}
+	block := mustParse(t, chunk)
+	if len(block.Stats) != len(want) {
+		t.Fatalf("stat count err, want %d, ret %d", len(want), len(block.Stats))
+	}
+	for i, stat := range block.Stats {
+		if ret := fmt.Sprintf("%T", stat); ret != want[i] {
+			t.Errorf("stat %d err, want %s, ret %s", i, want[i], ret)
+		}
+	}
+	if block.RetExps == nil || len(block.RetExps) != 0 {
+		t.Errorf("empty return err, ret %v", block.RetExps)
+	}
+
+	// function t.a.b:m(x) end => t.a.b.m = function(self, x) end
+	assign := block.Stats[2].(*ast.AssignStat)
+	if ret := sexp(assign.VarList[0]) + " = " + sexp(assign.ExpList[0]); ret != `t["a"]["b"]["m"] = (function (self x) 0)` {
+		t.Errorf("method def err, ret %s", ret)
+	}
+	ifStat := block.Stats[8].(*ast.IfStat)
+	if len(ifStat.Exps) != 3 || sexp(ifStat.Exps[2]) != "true" {
+		t.Errorf("if stat err, ret %s", sexps(ifStat.Exps))
+	}
+	forNum := block.Stats[9].(*ast.ForNumStat)
+	if forNum.VarName != "i" || sexp(forNum.StepExp) != "2" || forNum.Line != 11 || forNum.LineOfDo != 11 {
+		t.Errorf("for num stat err, ret %+v", forNum)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	block := mustParse(t, "local x = 1\n  print(x +\n y)\n")
+	call := block.Stats[1].(*ast.FuncCallExp)
+	if call.Position() != (ast.Pos{Line: 2, Column: 3}) || call.LastLine != 3 {
+		t.Errorf("call position err, ret %+v %d", call.Position(), call.LastLine)
+	}
+	add := call.Args[0].(*ast.BinopExp)
+	if add.Position() != (ast.Pos{Line: 2, Column: 11}) {
+		t.Errorf("binop position err, ret %+v", add.Position())
+	}
+	if y := add.Exp2.Position(); y != (ast.Pos{Line: 3, Column: 2}) {
+		t.Errorf("name position err, ret %+v", y)
+	}
+	if block.LastLine != 4 {
+		t.Errorf("block last line err, ret %d", block.LastLine)
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	tests := map[string]string{
+		"x = = 1":                     "test.lua:1: unexpected symbol near '='",
+		"x":                           "test.lua:1: syntax error near <eof>",
+		"f() = 1":                     "test.lua:1: syntax error near '='",
+		"(a) = 1":                     "test.lua:1: syntax error near '='",
+		"local 1":                     "test.lua:1: <name> expected near '1'",
+		"if x then":                   "test.lua:1: 'end' expected near <eof>",
+		"while x do\n\nlocal y = 1":   "test.lua:3: 'end' expected (to close 'while' at line 1) near <eof>",
+		"f(1,\n2":                     "test.lua:2: ')' expected (to close '(' at line 1) near <eof>",
+		"repeat x = 1 end":            "test.lua:1: 'until' expected near 'end'",
+		"for i do end":                "test.lua:1: '=' or 'in' expected near 'do'",
+		"for i = 1 do end":            "test.lua:1: ',' expected near 'do'",
+		"return 1 x = 2":              "test.lua:1: <eof> expected near 'x'",
+		"end":                         "test.lua:1: <eof> expected near 'end'",
+		"function f() return ... end": "test.lua:1: cannot use '...' outside a vararg function near '...'",
+		"function f(a,) end":          "test.lua:1: <name> expected near ')'",
+		"x = {a = }":                  "test.lua:1: unexpected symbol near '}'",
+		"x = 'unfinished":             "test.lua:1: unfinished string near <eof>",
+		"obj:m + 1":                   "test.lua:1: function arguments expected near '+'",
+	}
+	for chunk, want := range tests {
+		_, err := Parse(chunk, "@test.lua")
+		if err == nil || err.Error() != want {
+			t.Errorf("parse %q err, want %q, ret %v", chunk, want, err)
+		}
+		if _, ok := err.(*lexer.SyntaxError); err != nil && !ok {
+			t.Errorf("want *lexer.SyntaxError, ret %T", err)
+		}
+	}
+}
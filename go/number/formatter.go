@@ -0,0 +1,37 @@
+package number
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// lua: LUAI_NUMFFORMAT
+const floatFormat = "%.14g"
+
+func FormatInteger(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+// lua: lua_Number2str
+// 看起来像整数的浮点数加上 ".0" 后缀，和整数区分开；inf 和 nan 的写法和 C 的 printf 一致
+func FormatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		if math.Signbit(f) {
+			return "-nan"
+		}
+		return "nan"
+	}
+
+	s := fmt.Sprintf(floatFormat, f)
+	if strings.Trim(s, "-0123456789") == "" { // looks like an int?
+		s += ".0" // adds '.0' to result
+	}
+	return s
+}
@@ -0,0 +1,31 @@
+package number
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		f   float64
+		str string
+	}{
+		{3, "3.0"},
+		{-3, "-3.0"},
+		{1e6, "1000000.0"},
+		{2.5, "2.5"},
+		{0.1, "0.1"},
+		{1 / 3.0, "0.33333333333333"},
+		{1e15, "1e+15"},
+		{1e-5, "1e-05"},
+		{math.Inf(1), "inf"},
+		{math.Inf(-1), "-inf"},
+		{math.NaN(), "nan"},
+		{math.Copysign(0, -1), "-0.0"},
+	}
+	for _, test := range tests {
+		if ret := FormatFloat(test.f); ret != test.str {
+			t.Errorf("FormatFloat(%v) err, want %q, ret %q", test.f, test.str, ret)
+		}
+	}
+}
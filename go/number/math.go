@@ -18,27 +18,45 @@ func IMod(a, b int64) int64 {
 	return a - IFloorDiv(a, b)*b
 }
 
+// FMod 取模结果和除数同号，a % ±inf 在 a 为有限值时结果为 a（或 ±inf）
 func FMod(a, b float64) float64 {
-	return a - math.Floor(a/b)*b
+	m := math.Mod(a, b)
+	if m > 0 && b < 0 || m < 0 && b > 0 {
+		m += b
+	}
+	return m
 }
 
+// lua: luaV_shiftl
+// 移位数的绝对值不小于 64 时结果为 0；n 为负数时反向移位，不能对 n 取反，-MinInt64 仍然是负数
 func ShiftLeft(a, n int64) int64 {
-	if n >= 0 {
+	switch {
+	case n <= -64 || n >= 64:
+		return 0
+	case n >= 0:
 		return a << uint64(n)
-	} else {
-		return ShiftRight(a, -n)
+	default:
+		return int64(uint64(a) >> uint64(-n))
 	}
 }
 
+// 逻辑右移，高位补 0
 func ShiftRight(a, n int64) int64 {
-	if n >= 0 {
+	switch {
+	case n <= -64 || n >= 64:
+		return 0
+	case n >= 0:
 		return int64(uint64(a) >> uint64(n))
-	} else {
-		return ShiftLeft(a, -n)
+	default:
+		return a << uint64(-n)
 	}
 }
 
 func FloatToInteger(f float64) (int64, bool) {
-	i := int64(f)
-	return i, float64(i) == f
+	// 超出 int64 范围的浮点数（包括 inf 和 nan）直接转换的结果是未定义的
+	if f >= math.MinInt64 && f < -math.MinInt64 {
+		i := int64(f)
+		return i, float64(i) == f
+	}
+	return 0, false
 }
@@ -1,6 +1,7 @@
 package number
 
 import (
+	"math"
 	"testing"
 )
 
@@ -17,3 +18,64 @@ func TestFFloorDiv(t *testing.T) {
 		t.Errorf("floor div err, want -2, ret %v", ret)
 	}
 }
+
+func TestIMod(t *testing.T) {
+	if ret := IMod(5, -3); ret != -1 {
+		t.Errorf("mod err, want -1, ret %v", ret)
+	}
+	if ret := IMod(-5, 3); ret != 1 {
+		t.Errorf("mod err, want 1, ret %v", ret)
+	}
+}
+
+func TestFMod(t *testing.T) {
+	if ret := FMod(5.5, -2); ret != -0.5 {
+		t.Errorf("mod err, want -0.5, ret %v", ret)
+	}
+	if ret := FMod(5, math.Inf(1)); ret != 5 {
+		t.Errorf("mod err, want 5, ret %v", ret)
+	}
+	if ret := FMod(-5, math.Inf(1)); !math.IsInf(ret, 1) {
+		t.Errorf("mod err, want +Inf, ret %v", ret)
+	}
+}
+
+func TestShift(t *testing.T) {
+	tests := []struct {
+		a, n     int64
+		shl, shr int64
+	}{
+		{1, 3, 8, 0},
+		{-1, 63, math.MinInt64, 1},
+		{8, -2, 2, 32},
+		{-1, -1, math.MaxInt64, -2},
+		{1, 63, math.MinInt64, 0},
+		{1, 64, 0, 0},
+		{1, -64, 0, 0},
+		{1, math.MaxInt64, 0, 0},
+		{1, math.MinInt64, 0, 0},
+		{-1, math.MinInt64, 0, 0},
+	}
+	for _, test := range tests {
+		if ret := ShiftLeft(test.a, test.n); ret != test.shl {
+			t.Errorf("%d << %d err, want %v, ret %v", test.a, test.n, test.shl, ret)
+		}
+		if ret := ShiftRight(test.a, test.n); ret != test.shr {
+			t.Errorf("%d >> %d err, want %v, ret %v", test.a, test.n, test.shr, ret)
+		}
+	}
+}
+
+func TestFloatToInteger(t *testing.T) {
+	if i, ok := FloatToInteger(3.0); !ok || i != 3 {
+		t.Errorf("FloatToInteger(3.0) err, ret %v %v", i, ok)
+	}
+	if i, ok := FloatToInteger(-9223372036854775808.0); !ok || i != math.MinInt64 {
+		t.Errorf("FloatToInteger(minint) err, ret %v %v", i, ok)
+	}
+	for _, f := range []float64{3.5, 9223372036854775808.0, 1e100, math.Inf(-1), math.NaN()} {
+		if i, ok := FloatToInteger(f); ok {
+			t.Errorf("FloatToInteger(%v) should fail, ret %v", f, i)
+		}
+	}
+}
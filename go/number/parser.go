@@ -1,13 +1,188 @@
 package number
 
-import "strconv"
+import (
+	"math"
+	"strconv"
+	"strings"
+)
 
+// 和 C 的 isspace 一致
+const spaces = " \t\n\v\f\r"
+
+// 十六进制浮点数最多读取的有效数字个数，多出的数字只影响指数
+const maxSigDig = 30
+
+// lua: l_str2int
+// 十进制整数溢出时解析失败，由调用者按浮点数重新解析；十六进制整数溢出时回绕
 func ParseInteger(str string) (int64, bool) {
-	i, err := strconv.ParseInt(str, 10, 64)
-	return i, err == nil
+	s := strings.Trim(str, spaces)
+	neg := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, false
+	}
+
+	var a uint64
+	if isHexPrefix(s) {
+		s = s[2:]
+		if s == "" {
+			return 0, false
+		}
+		for i := 0; i < len(s); i++ {
+			d, ok := hexDigit(s[i])
+			if !ok {
+				return 0, false
+			}
+			a = a*16 + uint64(d)
+		}
+	} else {
+		const maxBy10 = uint64(math.MaxInt64 / 10)
+		const maxLastD = uint64(math.MaxInt64 % 10)
+		negD := uint64(0)
+		if neg {
+			negD = 1
+		}
+		for i := 0; i < len(s); i++ {
+			if !isDigit(s[i]) {
+				return 0, false
+			}
+			d := uint64(s[i] - '0')
+			if a >= maxBy10 && (a > maxBy10 || d > maxLastD+negD) {
+				return 0, false // overflow
+			}
+			a = a*10 + d
+		}
+	}
+
+	if neg {
+		return int64(0 - a), true
+	}
+	return int64(a), true
 }
 
+// lua: l_str2d
+// 不接受 inf 和 nan，十六进制浮点数的指数部分可以省略
 func ParseFloat(str string) (float64, bool) {
-	f, err := strconv.ParseFloat(str, 64)
-	return f, err == nil
+	s := strings.Trim(str, spaces)
+	if strings.ContainsAny(s, "nN") { // reject 'inf' and 'nan'
+		return 0, false
+	}
+	if strings.ContainsAny(s, "xX") {
+		return parseHexFloat(s)
+	}
+
+	// strconv 还接受数字间的下划线，strtod 不接受
+	if strings.Trim(s, "0123456789.eE+-") != "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		// 和 strtod 一样，溢出时返回 ±HUGE_VAL
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return f, true
+		}
+		return 0, false
+	}
+	return f, true
+}
+
+// lua: lua_strx2number
+func parseHexFloat(s string) (float64, bool) {
+	neg := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if !isHexPrefix(s) {
+		return 0, false
+	}
+	s = s[2:]
+
+	r := 0.0
+	e := 0        // exponent correction
+	sigDig := 0   // number of significant digits
+	noSigDig := 0 // number of non-significant digits
+	hasDot := false
+	i := 0
+	for ; i < len(s); i++ {
+		if s[i] == '.' {
+			if hasDot {
+				break // second dot? stop loop
+			}
+			hasDot = true
+		} else if d, ok := hexDigit(s[i]); ok {
+			if sigDig == 0 && d == 0 { // non-significant digit (zero)?
+				noSigDig++
+			} else if sigDig++; sigDig <= maxSigDig { // can read it without overflow?
+				r = r*16 + float64(d)
+			} else {
+				e++ // too many digits; ignore, but still count for exponent
+			}
+			if hasDot {
+				e-- // decimal digit? correct exponent
+			}
+		} else {
+			break
+		}
+	}
+	if noSigDig+sigDig == 0 { // no digits?
+		return 0, false
+	}
+	e *= 4 // each digit multiplies/divides value by 2^4
+
+	s = s[i:]
+	if len(s) > 0 && (s[0] == 'p' || s[0] == 'P') { // exponent part?
+		s = s[1:]
+		expNeg := false
+		if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+			expNeg = s[0] == '-'
+			s = s[1:]
+		}
+		if s == "" || !isDigit(s[0]) {
+			return 0, false // invalid; must have at least one digit
+		}
+		exp1 := 0
+		for len(s) > 0 && isDigit(s[0]) {
+			if exp1 < 1<<20 { // 再大的指数结果也只能是 0 或 inf
+				exp1 = exp1*10 + int(s[0]-'0')
+			}
+			s = s[1:]
+		}
+		if expNeg {
+			exp1 = -exp1
+		}
+		e += exp1
+	}
+	if s != "" {
+		return 0, false
+	}
+
+	if neg {
+		r = -r
+	}
+	return math.Ldexp(r, e), true
+}
+
+func isHexPrefix(s string) bool {
+	return len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func hexDigit(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10, true
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10, true
+	default:
+		return 0, false
+	}
 }
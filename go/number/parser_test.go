@@ -0,0 +1,75 @@
+package number
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseInteger(t *testing.T) {
+	tests := []struct {
+		str string
+		i   int64
+		ok  bool
+	}{
+		{"12", 12, true},
+		{"  12  ", 12, true},
+		{"\t-12\n", -12, true},
+		{"+12", 12, true},
+		{"0x10", 16, true},
+		{"0XfF", 255, true},
+		{"-0x10", -16, true},
+		{"0xffffffffffffffff", -1, true}, // 十六进制溢出时回绕
+		{"0x10000000000000001", 1, true}, // 十六进制溢出时回绕
+		{"9223372036854775807", math.MaxInt64, true},
+		{"-9223372036854775808", math.MinInt64, true},
+		{"9223372036854775808", 0, false}, // 十进制溢出时解析失败
+		{"1e2", 0, false},
+		{"1.0", 0, false},
+		{"0x", 0, false},
+		{"", 0, false},
+		{"-", 0, false},
+		{"1 2", 0, false},
+	}
+	for _, test := range tests {
+		i, ok := ParseInteger(test.str)
+		if ok != test.ok || ok && i != test.i {
+			t.Errorf("ParseInteger(%q) err, want %v %v, ret %v %v", test.str, test.i, test.ok, i, ok)
+		}
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		str string
+		f   float64
+		ok  bool
+	}{
+		{"1e2", 100, true},
+		{" 1.5 ", 1.5, true},
+		{".5", 0.5, true},
+		{"5.", 5, true},
+		{"-2E-1", -0.2, true},
+		{"0x1p4", 16, true},
+		{"0xA.8p0", 10.5, true},
+		{"0xA.8", 10.5, true},
+		{"0x.1", 0.0625, true},
+		{"-0x1P-1", -0.5, true},
+		{"0x10", 16, true},
+		{"9223372036854775808", 9223372036854775808, true},
+		{"1e400", math.Inf(1), true},
+		{"inf", 0, false},
+		{"nan", 0, false},
+		{"-Infinity", 0, false},
+		{"0x1p", 0, false},
+		{"0x", 0, false},
+		{"1e", 0, false},
+		{"1_000", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		f, ok := ParseFloat(test.str)
+		if ok != test.ok || ok && f != test.f {
+			t.Errorf("ParseFloat(%q) err, want %v %v, ret %v %v", test.str, test.f, test.ok, f, ok)
+		}
+	}
+}
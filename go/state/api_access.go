@@ -1,11 +1,12 @@
 package state
 
-import (
-	"fmt"
-	. "github.com/anccy/luago/go/api"
-)
+import . "github.com/anccy/luago/go/api"
 
 func (self *LuaState) TypeName(tp LuaType) string {
+	return typeName(tp)
+}
+
+func typeName(tp LuaType) string {
 	switch tp {
 	case LUA_TNONE:
 		return "no value"
@@ -60,6 +61,20 @@ func (self *LuaState) IsFunction(idx int) bool {
 	return self.Type(idx) == LUA_TFUNCTION
 }
 
+func (self *LuaState) IsGoFunction(idx int) bool {
+	val := self.stack.get(idx)
+	if c, ok := val.(*closure); ok {
+		return c.goFunc != nil
+	}
+	return false
+}
+
+// full userdata 或 light userdata
+func (self *LuaState) IsUserdata(idx int) bool {
+	t := self.Type(idx)
+	return t == LUA_TUSERDATA || t == LUA_TLIGHTUSERDATA
+}
+
 func (self *LuaState) IsThread(idx int) bool {
 	return self.Type(idx) == LUA_TTHREAD
 }
@@ -90,10 +105,10 @@ func (self *LuaState) ToInteger(idx int) int64 {
 	return i
 }
 
+// 值为整数、能精确转换成整数的浮点数或者这样的数字字符串时返回 true
 func (self *LuaState) ToIntegerX(idx int) (int64, bool) {
 	val := self.stack.get(idx)
-	i, ok := val.(int64)
-	return i, ok
+	return convertToInteger(val)
 }
 
 func (self *LuaState) ToNumber(idx int) float64 {
@@ -118,10 +133,30 @@ func (self *LuaState) ToStringX(idx int) (string, bool) {
 	case string:
 		return x, true
 	case int64, float64:
-		s := fmt.Sprintf("%v", x)
-		self.stack.set(idx, s)
+		s := numberToString(x)
+		self.stack.set(idx, s) // 和 Lua 一样，把栈上的数字替换成字符串
 		return s, true
 	default:
 		return "", false
 	}
 }
+
+func (self *LuaState) ToGoFunction(idx int) GoFunction {
+	val := self.stack.get(idx)
+	if c, ok := val.(*closure); ok {
+		return c.goFunc
+	}
+	return nil
+}
+
+// 返回 userdata 包装的 Go 值，不是 userdata 时返回 nil
+func (self *LuaState) ToUserdata(idx int) interface{} {
+	switch x := self.stack.get(idx).(type) {
+	case *userdata:
+		return x.value
+	case lightUserdata:
+		return x.p
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package state
+
+import (
+	"math"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/number"
+)
+
+var (
+	iadd  = func(a, b int64) int64 { return a + b }
+	fadd  = func(a, b float64) float64 { return a + b }
+	isub  = func(a, b int64) int64 { return a - b }
+	fsub  = func(a, b float64) float64 { return a - b }
+	imul  = func(a, b int64) int64 { return a * b }
+	fmul  = func(a, b float64) float64 { return a * b }
+	imod  = number.IMod
+	fmod  = number.FMod
+	pow   = math.Pow
+	div   = func(a, b float64) float64 { return a / b }
+	iidiv = number.IFloorDiv
+	fidiv = number.FFloorDiv
+	band  = func(a, b int64) int64 { return a & b }
+	bor   = func(a, b int64) int64 { return a | b }
+	bxor  = func(a, b int64) int64 { return a ^ b }
+	shl   = number.ShiftLeft
+	shr   = number.ShiftRight
+	iunm  = func(a, _ int64) int64 { return -a }
+	funm  = func(a, _ float64) float64 { return -a }
+	bnot  = func(a, _ int64) int64 { return ^a }
+)
+
+// operator 只有 integerFunc 的是位运算，只有 floatFunc 的是 / 和 ^
+type operator struct {
+	metamethod  string
+	integerFunc func(int64, int64) int64
+	floatFunc   func(float64, float64) float64
+}
+
+// 下标与 LUA_OPADD ... LUA_OPBNOT 对应
+var operators = []operator{
+	{"__add", iadd, fadd},
+	{"__sub", isub, fsub},
+	{"__mul", imul, fmul},
+	{"__mod", imod, fmod},
+	{"__pow", nil, pow},
+	{"__div", nil, div},
+	{"__idiv", iidiv, fidiv},
+	{"__band", band, nil},
+	{"__bor", bor, nil},
+	{"__bxor", bxor, nil},
+	{"__shl", shl, nil},
+	{"__shr", shr, nil},
+	{"__unm", iunm, funm},
+	{"__bnot", bnot, nil},
+}
+
+// lua: lua_arith
+func (self *LuaState) Arith(op ArithOp) {
+	var a, b luaValue
+	b = self.stack.pop()
+	if op != LUA_OPUNM && op != LUA_OPBNOT {
+		a = self.stack.pop()
+	} else {
+		a = b
+	}
+
+	if result := _arith(a, b, op, self); result != nil {
+		self.stack.push(result)
+		return
+	}
+
+	mm := operators[op].metamethod
+	if result, ok := callMetamethod(a, b, mm, self); ok {
+		self.stack.push(result)
+		return
+	}
+
+	if operators[op].floatFunc == nil {
+		if _, ok := convertToFloat(a); ok {
+			if _, ok := convertToFloat(b); ok {
+				panic(self.runtimeError("number has no integer representation"))
+			}
+		}
+		panic(self.runtimeError("attempt to perform bitwise operation on a %s value", _arithErrorType(a, b, self)))
+	}
+	panic(self.runtimeError("attempt to perform arithmetic on a %s value", _arithErrorType(a, b, self)))
+}
+
+// 返回两个操作数中不能转换成数字的那个的类型名
+func _arithErrorType(a, b luaValue, ls *LuaState) string {
+	if _, ok := convertToFloat(a); !ok {
+		return objTypeName(a, ls)
+	}
+	return objTypeName(b, ls)
+}
+
+func _arith(a, b luaValue, op ArithOp, ls *LuaState) luaValue {
+	operator := operators[op]
+	if operator.floatFunc == nil { // bitwise
+		if x, ok := convertToInteger(a); ok {
+			if y, ok := convertToInteger(b); ok {
+				return operator.integerFunc(x, y)
+			}
+		}
+		return nil
+	}
+
+	if operator.integerFunc != nil { // add, sub, mul, mod, idiv, unm
+		if x, ok := a.(int64); ok {
+			if y, ok := b.(int64); ok {
+				if y == 0 && (op == LUA_OPMOD || op == LUA_OPIDIV) {
+					if op == LUA_OPMOD {
+						panic(ls.runtimeError("attempt to perform 'n%%0'"))
+					}
+					panic(ls.runtimeError("attempt to perform 'n//0'"))
+				}
+				return operator.integerFunc(x, y)
+			}
+		}
+	}
+	if x, ok := convertToFloat(a); ok {
+		if y, ok := convertToFloat(b); ok {
+			return operator.floatFunc(x, y)
+		}
+	}
+	return nil
+}
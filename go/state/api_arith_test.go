@@ -0,0 +1,168 @@
+package state
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	. "github.com/anccy/luago/go/api"
+)
+
+var _ LuaStateI = (*LuaState)(nil)
+
+func TestArith(t *testing.T) {
+	ls := New()
+	ls.PushInteger(7)
+	ls.PushInteger(-2)
+	ls.Arith(LUA_OPIDIV)
+	if ret := ls.ToInteger(-1); !ls.IsInteger(-1) || ret != -4 {
+		t.Errorf("idiv err, want -4, ret %v", ret)
+	}
+
+	ls.PushString("2.5")
+	ls.Arith(LUA_OPMUL)
+	if ret := ls.ToNumber(-1); ls.IsInteger(-1) || ret != -10 {
+		t.Errorf("mul err, want -10.0, ret %v", ret)
+	}
+
+	ls.PushNumber(3.0)
+	ls.Arith(LUA_OPSHL)
+	if ret := ls.ToInteger(-1); ret != -80 {
+		t.Errorf("shl err, want -80, ret %v", ret)
+	}
+
+	ls.Arith(LUA_OPBNOT)
+	if ret := ls.ToInteger(-1); ret != 79 {
+		t.Errorf("bnot err, want 79, ret %v", ret)
+	}
+
+	// 移位数为 math.mininteger 时结果为 0
+	for _, op := range []ArithOp{LUA_OPSHL, LUA_OPSHR} {
+		ls.PushInteger(1)
+		ls.PushInteger(math.MinInt64)
+		ls.Arith(op)
+		if ret := ls.ToInteger(-1); ret != 0 {
+			t.Errorf("shift %v err, want 0, ret %v", op, ret)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ls := New()
+	ls.PushInteger(1)
+	ls.PushNumber(1.0)
+	ls.PushString("a")
+	ls.PushString("b")
+	if !ls.Compare(1, 2, LUA_OPEQ) {
+		t.Error("1 == 1.0 should be true")
+	}
+	if !ls.Compare(3, 4, LUA_OPLT) || ls.Compare(4, 3, LUA_OPLE) {
+		t.Error("string compare err")
+	}
+	if ls.Compare(1, 3, LUA_OPEQ) {
+		t.Error("1 == \"a\" should be false")
+	}
+}
+
+// 整数和浮点数按数学上的值比较，不受 2^53 以上浮点数精度的影响
+func TestCompareIntFloat(t *testing.T) {
+	tests := []struct {
+		i      int64
+		f      float64
+		eq     bool
+		lt, le bool // i < f, i <= f
+		gt, ge bool // f < i, f <= i
+	}{
+		{1, 1.0, true, false, true, false, true},
+		{1, 1.5, false, true, true, false, false},
+		{-1, -1.5, false, false, false, true, true},
+		{1<<53 + 1, 1 << 53, false, false, false, true, true},
+		{math.MaxInt64, 1 << 63, false, true, true, false, false},
+		{math.MinInt64, -(1 << 63), true, false, true, false, true},
+		{math.MinInt64, -1e19, false, false, false, true, true},
+		{0, math.Inf(1), false, true, true, false, false},
+		{0, math.Inf(-1), false, false, false, true, true},
+		{0, math.NaN(), false, false, false, false, false},
+	}
+	ls := New()
+	for _, test := range tests {
+		ls.PushInteger(test.i)
+		ls.PushNumber(test.f)
+		ret := []bool{
+			ls.Compare(1, 2, LUA_OPEQ), ls.Compare(1, 2, LUA_OPLT), ls.Compare(1, 2, LUA_OPLE),
+			ls.Compare(2, 1, LUA_OPLT), ls.Compare(2, 1, LUA_OPLE),
+		}
+		want := []bool{test.eq, test.lt, test.le, test.gt, test.ge}
+		if !reflect.DeepEqual(ret, want) {
+			t.Errorf("compare %d with %v err, want %v, ret %v", test.i, test.f, want, ret)
+		}
+		ls.SetTop(0)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	ls := New()
+	ls.PushString("a")
+	ls.PushInteger(1)
+	ls.PushNumber(2.5)
+	ls.Concat(3)
+	if ret := ls.ToString(-1); ls.GetTop() != 1 || ret != "a12.5" {
+		t.Errorf("concat err, want \"a12.5\", ret %q", ret)
+	}
+	ls.Len(-1)
+	if ret := ls.ToInteger(-1); ret != 5 {
+		t.Errorf("len err, want 5, ret %v", ret)
+	}
+}
+
+func TestStringToNumber(t *testing.T) {
+	ls := New()
+	if n := ls.StringToNumber(" 0x10 "); n != 7 || !ls.IsInteger(-1) || ls.ToInteger(-1) != 16 {
+		t.Errorf("StringToNumber err, ret %d %v", n, ls.ToString2(-1))
+	}
+	if n := ls.StringToNumber("0xA.8p0"); n != 8 || ls.IsInteger(-1) || ls.ToNumber(-1) != 10.5 {
+		t.Errorf("StringToNumber err, ret %d %v", n, ls.ToString2(-1))
+	}
+	if n := ls.StringToNumber("nan"); n != 0 || ls.GetTop() != 2 {
+		t.Errorf("StringToNumber err, ret %d", n)
+	}
+
+	// 字符串参与运算时按同样的规则转换
+	ls.SetTop(0)
+	ls.PushString("0x10")
+	ls.PushString(" 1e1 ")
+	ls.Arith(LUA_OPADD)
+	if ret := ls.ToNumber(-1); ret != 26 {
+		t.Errorf("string arith err, want 26, ret %v", ret)
+	}
+	ls.PushString("3.0")
+	ls.PushInteger(1)
+	ls.Arith(LUA_OPSHL)
+	if ret := ls.ToInteger(-1); ret != 6 {
+		t.Errorf("string bitwise err, want 6, ret %v", ret)
+	}
+}
+
+func TestNumberCoercion(t *testing.T) {
+	ls := New()
+	ls.PushNumber(1e6)
+	ls.PushNumber(3.0)
+	ls.PushString("42")
+	ls.PushNumber(3.5)
+	ls.PushString("0x10")
+	if ret := ls.ToString(1); ret != "1000000.0" {
+		t.Errorf("ToString err, want \"1000000.0\", ret %q", ret)
+	}
+	if i, ok := ls.ToIntegerX(2); !ok || i != 3 {
+		t.Errorf("ToIntegerX(3.0) err, ret %v %v", i, ok)
+	}
+	if i, ok := ls.ToIntegerX(3); !ok || i != 42 {
+		t.Errorf("ToIntegerX(\"42\") err, ret %v %v", i, ok)
+	}
+	if _, ok := ls.ToIntegerX(4); ok {
+		t.Errorf("ToIntegerX(3.5) should fail")
+	}
+	if i, ok := ls.ToIntegerX(5); !ok || i != 16 {
+		t.Errorf("ToIntegerX(\"0x10\") err, ret %v %v", i, ok)
+	}
+}
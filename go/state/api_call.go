@@ -0,0 +1,229 @@
+package state
+
+import (
+	"runtime"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/vm"
+)
+
+// PushLuaClosure 把函数原型包装成 Lua 闭包压入栈顶，
+// 和 lua_load 一样，第一个 upvalue（_ENV）设置为全局表
+func (self *LuaState) PushLuaClosure(proto *binchunk.Prototype) {
+	c := newLuaClosure(proto)
+	if len(c.upvals) > 0 {
+		c.upvals[0].set(self.registry.get(LUA_RIDX_GLOBALS))
+	}
+	self.stack.push(c)
+}
+
+// lua: lua_call
+// 被调函数和 nArgs 个参数依次在栈顶，调用后它们全部出栈，
+// 压入 nResults 个返回值，nResults 为 LUA_MULTRET 时压入全部返回值
+func (self *LuaState) Call(nArgs, nResults int) {
+	c, nArgs := self.callable(nArgs)
+	if c.proto != nil {
+		self.callLuaClosure(nArgs, nResults, c)
+	} else {
+		self.callGoClosure(nArgs, nResults, c)
+	}
+}
+
+// TailCall 执行 TAILCALL：被调函数和 nArgs 个参数在栈顶。
+// 被调函数是 Lua 函数时复用当前的调用帧，关闭当前函数的 upvalue，
+// 用被调函数的栈帧替换当前栈帧并返回 true，runLuaClosure 接着执行被调函数，
+// 这样尾递归不会让调用栈增长；Go 函数按普通调用处理，返回值全部留在栈顶，返回 false
+func (self *LuaState) TailCall(nArgs int) bool {
+	c, nArgs := self.callable(nArgs)
+	if c.proto == nil {
+		self.callGoClosure(nArgs, LUA_MULTRET, c)
+		return false
+	}
+
+	funcAndArgs := self.stack.popN(nArgs + 1)
+	self.stack.closeUpvalues(0)
+	self.popLuaStack()
+	self.pushLuaStack(newLuaFrame(c, funcAndArgs, self))
+	return true
+}
+
+// 返回栈顶 nArgs 个参数之下的被调函数和参数个数，
+// 被调用的值不是函数时用 __call 元方法代替，原来的值作为第一个参数
+func (self *LuaState) callable(nArgs int) (*closure, int) {
+	val := self.stack.get(-(nArgs + 1))
+
+	c, ok := val.(*closure)
+	if !ok {
+		if mf := getMetafield(val, "__call", self); mf != nil {
+			if c, ok = mf.(*closure); ok {
+				self.stack.check(1)
+				self.stack.push(val)
+				self.Insert(-(nArgs + 1))
+				self.stack.set(-(nArgs + 2), c)
+				nArgs += 1
+			}
+		}
+	}
+
+	if !ok {
+		panic(self.runtimeError("attempt to call a %s value", objTypeName(val, self)))
+	}
+	return c, nArgs
+}
+
+func (self *LuaState) callGoClosure(nArgs, nResults int, c *closure) {
+	newStack := NewLuaStack(nArgs+LUA_MINSTACK, self)
+	newStack.closure = c
+
+	if nArgs > 0 {
+		args := self.stack.popN(nArgs)
+		newStack.pushN(args, nArgs)
+	}
+	self.stack.pop() // 弹出函数
+
+	self.pushLuaStack(newStack)
+	r := c.goFunc(self)
+	self.popLuaStack()
+
+	if nResults != 0 {
+		results := newStack.popN(r)
+		self.stack.check(len(results))
+		self.stack.pushN(results, nResults)
+	}
+}
+
+func (self *LuaState) callLuaClosure(nArgs, nResults int, c *closure) {
+	funcAndArgs := self.stack.popN(nArgs + 1)
+	self.pushLuaStack(newLuaFrame(c, funcAndArgs, self))
+	self.runLuaClosure()
+
+	// 尾调用会替换栈帧，返回值在最后执行的函数的栈帧上
+	stack := self.stack
+	stack.closeUpvalues(0)
+	self.popLuaStack()
+
+	// RETURN 把返回值留在了寄存器之上
+	if nResults != 0 {
+		results := stack.popN(stack.top - int(stack.closure.proto.MaxStackSize))
+		self.stack.check(len(results))
+		self.stack.pushN(results, nResults)
+	}
+}
+
+// 创建 Lua 函数的栈帧，固定参数放入寄存器，多出来的参数作为变长参数。
+// 和 luaD_precall 一样按 MaxStackSize 分配，LUA_MINSTACK 的余量只留给 Go 函数
+func newLuaFrame(c *closure, funcAndArgs []luaValue, state *LuaState) *luaStack {
+	nRegs := int(c.proto.MaxStackSize)
+	nParams := int(c.proto.NumParams)
+	nArgs := len(funcAndArgs) - 1
+	isVararg := c.proto.IsVararg != 0
+
+	newStack := NewLuaStack(nRegs, state)
+	newStack.closure = c
+
+	newStack.pushN(funcAndArgs[1:], nParams)
+	newStack.top = nRegs
+	if nArgs > nParams && isVararg {
+		newStack.varargs = funcAndArgs[nParams+1:]
+	}
+	return newStack
+}
+
+func (self *LuaState) runLuaClosure() {
+	for {
+		inst := vm.Instruction(self.Fetch())
+		inst.Execute(self)
+		if inst.Opcode() == vm.OP_RETURN {
+			break
+		}
+	}
+}
+
+// lua: lua_error
+// 以栈顶的值作为错误对象抛出错误
+func (self *LuaState) Error() int {
+	err := self.stack.pop()
+	panic(self.newLuaError(LUA_ERRRUN, err))
+}
+
+// lua: lua_pcall
+// 以保护模式调用函数，出错时函数和参数出栈，错误对象入栈并返回错误码。
+// msgh 不为 0 时，是消息处理函数在栈上的索引，它在调用栈展开之前以错误对象为参数被调用
+func (self *LuaState) PCall(nArgs, nResults, msgh int) int {
+	var handler luaValue
+	if msgh != 0 {
+		handler = self.stack.get(msgh)
+	}
+
+	if err := self.pcall(nArgs, nResults, handler); err != nil {
+		self.stack.push(err.Value)
+		return err.Status
+	}
+	return LUA_OK
+}
+
+// ProtectedCall 和 PCall 一样以保护模式调用函数，出错时函数和参数出栈，
+// 返回 *LuaError 而不是把错误对象留在栈上
+func (self *LuaState) ProtectedCall(nArgs, nResults int) error {
+	if err := self.pcall(nArgs, nResults, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (self *LuaState) pcall(nArgs, nResults int, handler luaValue) (err *LuaError) {
+	caller := self.stack
+	base := caller.top - nArgs - 1
+
+	defer func() {
+		if r := recover(); r != nil {
+			if r == errThreadClosed { // 协程被关闭，展开调用栈后继续向外传递
+				self.unwind(caller, base)
+				panic(r)
+			}
+			err = self.toLuaError(r)
+			if handler != nil {
+				self.callMsgHandler(handler, err)
+			}
+			self.unwind(caller, base)
+		}
+	}()
+
+	self.Call(nArgs, nResults)
+	return nil
+}
+
+// 展开调用栈，回到 caller 栈帧，并删除 base 及以上的值
+func (self *LuaState) unwind(caller *luaStack, base int) {
+	for self.stack != caller {
+		self.stack.closeUpvalues(0)
+		self.popLuaStack()
+	}
+	caller.closeUpvalues(base)
+	for caller.top > base {
+		caller.pop()
+	}
+}
+
+// 在出错的栈帧上调用消息处理函数，用它的返回值替换错误对象
+func (self *LuaState) callMsgHandler(handler luaValue, err *LuaError) {
+	// 出错的原因可能就是 stack overflow，给消息处理函数留出额外的空间
+	self.maxStack += errorStackExtra
+	defer func() {
+		self.maxStack -= errorStackExtra
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok { // 和 toLuaError 一样，bug 不当作 Lua 错误
+				panic(r)
+			}
+			err.Status = LUA_ERRERR
+			err.Value = "error in error handling"
+		}
+	}()
+
+	self.stack.check(2)
+	self.stack.push(handler)
+	self.stack.push(err.Value)
+	self.Call(1, 1)
+	err.Value = self.stack.pop()
+}
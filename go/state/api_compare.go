@@ -0,0 +1,199 @@
+package state
+
+import (
+	"math"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/number"
+)
+
+// lua: lua_compare
+func (self *LuaState) Compare(idx1, idx2 int, op CompareOp) bool {
+	if !self.stack.isValid(idx1) || !self.stack.isValid(idx2) {
+		return false
+	}
+
+	a := self.stack.get(idx1)
+	b := self.stack.get(idx2)
+	switch op {
+	case LUA_OPEQ:
+		return _eq(a, b, self)
+	case LUA_OPLT:
+		return _lt(a, b, self)
+	case LUA_OPLE:
+		return _le(a, b, self)
+	default:
+		panic(self.runtimeError("invalid compare op %d", op))
+	}
+}
+
+// lua: lua_rawequal
+func (self *LuaState) RawEqual(idx1, idx2 int) bool {
+	if !self.stack.isValid(idx1) || !self.stack.isValid(idx2) {
+		return false
+	}
+
+	a := self.stack.get(idx1)
+	b := self.stack.get(idx2)
+	return _eq(a, b, nil)
+}
+
+// ls 为 nil 时不触发 __eq 元方法
+func _eq(a, b luaValue, ls *LuaState) bool {
+	switch x := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	case string:
+		y, ok := b.(string)
+		return ok && x == y
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return x == y
+		case float64:
+			return eqIntFloat(x, y)
+		default:
+			return false
+		}
+	case float64:
+		switch y := b.(type) {
+		case float64:
+			return x == y
+		case int64:
+			return eqIntFloat(y, x)
+		default:
+			return false
+		}
+	case *luaTable:
+		if y, ok := b.(*luaTable); ok && x != y && ls != nil {
+			if result, ok := callMetamethod(x, y, "__eq", ls); ok {
+				return convertToBoolean(result)
+			}
+		}
+		return a == b
+	case *userdata:
+		if y, ok := b.(*userdata); ok && x != y && ls != nil {
+			if result, ok := callMetamethod(x, y, "__eq", ls); ok {
+				return convertToBoolean(result)
+			}
+		}
+		return a == b
+	default:
+		return a == b
+	}
+}
+
+func _lt(a, b luaValue, ls *LuaState) bool {
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return x < y
+		}
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return x < y
+		case float64:
+			return ltIntFloat(x, y)
+		}
+	case float64:
+		switch y := b.(type) {
+		case float64:
+			return x < y
+		case int64:
+			return ltFloatInt(x, y)
+		}
+	}
+
+	if result, ok := callMetamethod(a, b, "__lt", ls); ok {
+		return convertToBoolean(result)
+	}
+	panic(ls.runtimeError("%s", _compareError(a, b, ls)))
+}
+
+func _le(a, b luaValue, ls *LuaState) bool {
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return x <= y
+		}
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return x <= y
+		case float64:
+			return leIntFloat(x, y)
+		}
+	case float64:
+		switch y := b.(type) {
+		case float64:
+			return x <= y
+		case int64:
+			return leFloatInt(x, y)
+		}
+	}
+
+	if result, ok := callMetamethod(a, b, "__le", ls); ok {
+		return convertToBoolean(result)
+	}
+	// 没有 __le 时用 not (b < a) 代替
+	if result, ok := callMetamethod(b, a, "__lt", ls); ok {
+		return !convertToBoolean(result)
+	}
+	panic(ls.runtimeError("%s", _compareError(a, b, ls)))
+}
+
+func _compareError(a, b luaValue, ls *LuaState) string {
+	t1, t2 := objTypeName(a, ls), objTypeName(b, ls)
+	if t1 == t2 {
+		return "attempt to compare two " + t1 + " values"
+	}
+	return "attempt to compare " + t1 + " with " + t2
+}
+
+// 整数和浮点数比较时不能把整数转换成浮点数，超过 2^53 的整数会丢失精度。
+// 和 lvm.c 一样，把浮点数向上或者向下取整后按整数比较；
+// 取整后超出整数范围时，浮点数比所有整数都大（正数）或者都小（负数），NaN 和任何数比较都为 false
+
+// lua: luaV_equalobj
+func eqIntFloat(i int64, f float64) bool {
+	fi, ok := number.FloatToInteger(f)
+	return ok && fi == i
+}
+
+// lua: LTintfloat
+// i < f 等价于 i < ceil(f)
+func ltIntFloat(i int64, f float64) bool {
+	if fi, ok := number.FloatToInteger(math.Ceil(f)); ok {
+		return i < fi
+	}
+	return f > 0
+}
+
+// lua: LEintfloat
+// i <= f 等价于 i <= floor(f)
+func leIntFloat(i int64, f float64) bool {
+	if fi, ok := number.FloatToInteger(math.Floor(f)); ok {
+		return i <= fi
+	}
+	return f > 0
+}
+
+// f < i 等价于 floor(f) < i
+func ltFloatInt(f float64, i int64) bool {
+	if fi, ok := number.FloatToInteger(math.Floor(f)); ok {
+		return fi < i
+	}
+	return f < 0
+}
+
+// f <= i 等价于 ceil(f) <= i
+func leFloatInt(f float64, i int64) bool {
+	if fi, ok := number.FloatToInteger(math.Ceil(f)); ok {
+		return fi <= i
+	}
+	return f < 0
+}
@@ -0,0 +1,154 @@
+package state
+
+import . "github.com/anccy/luago/go/api"
+
+// lua: lua_newthread
+// 创建新线程并压入栈顶，新线程和当前线程共享注册表和类型元表
+func (self *LuaState) NewThread() LuaStateI {
+	t := &LuaState{registry: self.registry, mts: self.mts, maxStack: self.maxStack}
+	t.stack = NewLuaStack(LUA_MINSTACK, t)
+	t.nSlots = LUA_MINSTACK
+	self.stack.push(t)
+	return t
+}
+
+// lua: lua_resume
+// 启动或继续执行协程。第一次调用时，栈上是主函数和 nArgs 个参数；之后是传给 yield 的返回值。
+// 协程让出或结束时，让出的值或返回值留在协程的栈上；出错时栈顶是错误对象。
+// 每个协程在自己的 goroutine 中运行，协程结束时 goroutine 随之结束；
+// 让出后不再恢复的协程会一直占用它的 goroutine 和调用栈，需要用 CloseThread 关闭
+func (self *LuaState) Resume(from LuaStateI, nArgs int) int {
+	lsFrom := from.(*LuaState)
+	if lsFrom.coChan == nil {
+		lsFrom.coChan = make(chan int)
+	}
+
+	if self.coStatus == LUA_YIELD { // resume coroutine
+		self.coStatus = LUA_OK
+		self.coCaller = lsFrom
+		self.coChan <- 1
+	} else if self.coStatus != LUA_OK || self.coDone ||
+		!self.coStarted && self.GetTop() < nArgs+1 {
+		return self.resumeError("cannot resume dead coroutine", nArgs)
+	} else if self.coStarted {
+		return self.resumeError("cannot resume non-suspended coroutine", nArgs)
+	} else { // start coroutine
+		if self.coChan == nil { // 作为 from 恢复过其他协程时已经创建
+			self.coChan = make(chan int)
+		}
+		self.coCaller = lsFrom
+		self.coStarted = true
+		go func() {
+			defer func() {
+				// 被 CloseThread 关闭时，调用栈已经展开，goroutine 正常结束
+				if r := recover(); r != nil && r != errThreadClosed {
+					panic(r)
+				}
+				self.coDone = true
+				self.coCaller.coChan <- 1
+			}()
+			if self.coStatus = self.PCall(nArgs, LUA_MULTRET, 0); self.coStatus != LUA_OK {
+				self.coError = self.stack.get(-1) // resume 的调用者可能会取走栈上的错误对象
+			}
+		}()
+	}
+
+	<-lsFrom.coChan // 等待协程让出或结束
+	return self.coStatus
+}
+
+// lua: resume_error
+// 不能恢复协程时，弹出传给它的参数，压入错误消息
+func (self *LuaState) resumeError(msg string, nArgs int) int {
+	if nArgs > self.GetTop() {
+		nArgs = self.GetTop()
+	}
+	self.Pop(nArgs)
+	self.stack.check(1)
+	self.stack.push(msg)
+	return LUA_ERRRUN
+}
+
+// lua: lua_yield
+// 让出栈顶的 nResults 个值，再次被恢复时返回传入的参数个数，参数留在栈上
+func (self *LuaState) Yield(nResults int) int {
+	if self.coCaller == nil {
+		panic(self.runtimeError("attempt to yield from outside a coroutine"))
+	}
+
+	results := self.stack.popN(nResults)
+	self.SetTop(0)
+	self.stack.pushN(results, nResults)
+
+	self.coStatus = LUA_YIELD
+	self.coCaller.coChan <- 1
+	<-self.coChan // 等待被恢复
+	if self.coClosing {
+		panic(errThreadClosed)
+	}
+	return self.GetTop()
+}
+
+// CloseThread 关闭 Status 不是 LUA_OK 且没有在运行的协程（挂起或者已经结束），
+// 正在运行的协程不能关闭。挂起的协程的调用栈被展开，upvalue 被关闭，它的 goroutine 结束。
+// 关闭后协程是死亡的，栈被清空；协程因为出错而结束时返回错误码，错误对象留在它的栈顶，否则返回 LUA_OK
+func (self *LuaState) CloseThread(from LuaStateI) int {
+	if self.coStatus == LUA_YIELD {
+		lsFrom := from.(*LuaState)
+		if lsFrom.coChan == nil {
+			lsFrom.coChan = make(chan int)
+		}
+		self.coStatus = LUA_OK
+		self.coCaller = lsFrom
+		self.coClosing = true
+		self.coChan <- 1
+		<-lsFrom.coChan // 等待 goroutine 结束
+		self.coClosing = false
+	}
+
+	status := self.coStatus
+	self.SetTop(0)
+	if status != LUA_OK {
+		self.stack.push(self.coError)
+	}
+	return status
+}
+
+// lua: lua_status
+func (self *LuaState) Status() int {
+	return self.coStatus
+}
+
+// lua: lua_isyieldable
+func (self *LuaState) IsYieldable() bool {
+	return self.coCaller != nil
+}
+
+// GetStack 返回线程中是否有正在执行的函数
+func (self *LuaState) GetStack() bool {
+	return self.stack.prev != nil
+}
+
+// lua: lua_xmove
+// 从当前线程弹出 n 个值，按顺序压入线程 to
+func (self *LuaState) XMove(to LuaStateI, n int) {
+	vals := self.stack.popN(n)
+	lsTo := to.(*LuaState)
+	lsTo.stack.check(n)
+	lsTo.stack.pushN(vals, n)
+}
+
+// lua: lua_pushthread
+// 把当前线程压入栈顶，返回它是否是主线程
+func (self *LuaState) PushThread() bool {
+	self.stack.push(self)
+	return self.coCaller == nil
+}
+
+// lua: lua_tothread
+func (self *LuaState) ToThread(idx int) LuaStateI {
+	if t, ok := self.stack.get(idx).(*LuaState); ok {
+		return t
+	}
+	return nil
+}
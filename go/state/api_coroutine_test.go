@@ -0,0 +1,189 @@
+package state
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/vm"
+)
+
+func TestResumeYield(t *testing.T) {
+	ls := New()
+	co := ls.NewThread()
+	co.PushGoFunction(func(ls LuaStateI) int {
+		for i := int64(1); i <= 2; i++ {
+			ls.PushInteger(i * ls.ToInteger(-1))
+			ls.Yield(1)
+		}
+		ls.PushString("done")
+		return 1
+	})
+	co.PushInteger(10)
+
+	if status := co.Resume(ls, 1); status != LUA_YIELD || co.ToInteger(-1) != 10 {
+		t.Fatalf("resume err, status %v, ret %v", status, co.ToInteger(-1))
+	}
+	co.Pop(1)
+	co.PushInteger(100)
+	if status := co.Resume(ls, 1); status != LUA_YIELD || co.ToInteger(-1) != 200 {
+		t.Fatalf("resume err, status %v, ret %v", status, co.ToInteger(-1))
+	}
+	co.Pop(1)
+	if status := co.Resume(ls, 0); status != LUA_OK || co.ToString(-1) != "done" {
+		t.Fatalf("resume err, status %v, ret %v", status, co.ToString(-1))
+	}
+	co.Pop(1)
+
+	if status := co.Resume(ls, 0); status != LUA_ERRRUN || co.ToString(-1) != "cannot resume dead coroutine" {
+		t.Errorf("resume dead coroutine err, status %v, ret %q", status, co.ToString(-1))
+	}
+}
+
+// local f = ...; f(); return "back"
+func TestYieldAcrossLuaFrame(t *testing.T) {
+	proto := &binchunk.Prototype{
+		NumParams:    1,
+		MaxStackSize: 2,
+		Constants:    []interface{}{"back"},
+		Code: []uint32{
+			iABC(vm.OP_MOVE, 1, 0, 0),
+			iABC(vm.OP_CALL, 1, 1, 1),
+			iABx(vm.OP_LOADK, 1, 0),
+			iABC(vm.OP_RETURN, 1, 2, 0),
+		},
+	}
+
+	ls := New()
+	co := ls.NewThread().(*LuaState)
+	co.PushLuaClosure(proto)
+	co.PushGoFunction(func(ls LuaStateI) int {
+		return ls.Yield(0)
+	})
+
+	if status := co.Resume(ls, 1); status != LUA_YIELD || !co.GetStack() {
+		t.Fatalf("yield err, status %v", status)
+	}
+	if status := co.Resume(ls, 0); status != LUA_OK || co.ToString(-1) != "back" {
+		t.Errorf("resume err, status %v, ret %q", status, co.ToString(-1))
+	}
+}
+
+func TestCoroutineError(t *testing.T) {
+	ls := New()
+	co := ls.NewThread()
+	co.PushGoFunction(func(ls LuaStateI) int {
+		ls.PushString("failed")
+		return ls.Error()
+	})
+	if status := co.Resume(ls, 0); status != LUA_ERRRUN || co.ToString(-1) != "failed" {
+		t.Errorf("coroutine error err, status %v, ret %q", status, co.ToString(-1))
+	}
+
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		return ls.Yield(0)
+	})
+	if err := ls.ProtectedCall(0, 0); err == nil || ls.IsYieldable() {
+		t.Error("main thread should not yield")
+	}
+}
+
+// 等待 goroutine 的数量回落到 n 以下
+func waitGoroutines(n int) int {
+	for i := 0; i < 100 && runtime.NumGoroutine() > n; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	return runtime.NumGoroutine()
+}
+
+// 结束的协程和被关闭的协程都不再占用 goroutine
+func TestCloseThread(t *testing.T) {
+	n := runtime.NumGoroutine()
+	ls := New()
+
+	for i := 0; i < 50; i++ {
+		co := ls.NewThread()
+		co.PushGoFunction(func(ls LuaStateI) int { return 0 })
+		if status := co.Resume(ls, 0); status != LUA_OK {
+			t.Fatalf("resume err, status %v", status)
+		}
+		ls.Pop(1)
+	}
+	if ret := waitGoroutines(n); ret > n {
+		t.Errorf("finished coroutines err, want %d goroutines, ret %d", n, ret)
+	}
+
+	caught := false
+	for i := 0; i < 50; i++ {
+		co := ls.NewThread()
+		co.PushGoFunction(func(ls LuaStateI) int {
+			// 协程里的 pcall 不能拦住关闭
+			ls.PushGoFunction(func(ls LuaStateI) int { return ls.Yield(0) })
+			ls.PCall(0, 0, 0)
+			caught = true
+			return 0
+		})
+		if status := co.Resume(ls, 0); status != LUA_YIELD {
+			t.Fatalf("resume err, status %v", status)
+		}
+		if status := co.CloseThread(ls); status != LUA_OK || co.GetTop() != 0 || co.GetStack() {
+			t.Fatalf("close err, status %v, top %v", status, co.GetTop())
+		}
+		if status := co.Resume(ls, 0); status != LUA_ERRRUN || co.ToString(-1) != "cannot resume dead coroutine" {
+			t.Errorf("resume closed coroutine err, status %v, ret %q", status, co.ToString(-1))
+		}
+		ls.Pop(1)
+	}
+	if caught {
+		t.Errorf("close err, pcall in the coroutine caught it")
+	}
+	if ret := waitGoroutines(n); ret > n {
+		t.Errorf("closed coroutines err, want %d goroutines, ret %d", n, ret)
+	}
+
+	// 出错结束的协程返回错误码和错误对象
+	co := ls.NewThread()
+	co.PushGoFunction(func(ls LuaStateI) int {
+		ls.PushString("failed")
+		return ls.Error()
+	})
+	co.Resume(ls, 0)
+	if status := co.CloseThread(ls); status != LUA_ERRRUN || co.GetTop() != 1 || co.ToString(-1) != "failed" {
+		t.Errorf("close error coroutine err, status %v, ret %q", status, co.ToString(-1))
+	}
+}
+
+// 协程是否开始和结束由它自己的状态决定，与它是否恢复过其他协程无关
+func TestResumeState(t *testing.T) {
+	ls := New()
+	a := ls.NewThread()
+	b := ls.NewThread()
+	b.PushGoFunction(func(ls LuaStateI) int { return 0 })
+	if status := b.Resume(a, 0); status != LUA_OK {
+		t.Fatalf("resume b err, status %v", status)
+	}
+
+	a.PushGoFunction(func(ls LuaStateI) int {
+		ls.PushString("a")
+		return 1
+	})
+	if status := a.Resume(ls, 0); status != LUA_OK || a.ToString(-1) != "a" {
+		t.Errorf("resume a err, status %v, ret %q", status, a.ToString(-1))
+	}
+
+	// 恢复死亡的协程失败时，传给它的参数出栈
+	b.SetTop(0)
+	for i := 0; i < 3; i++ {
+		b.PushInteger(1)
+		b.PushInteger(2)
+		if status := b.Resume(ls, 2); status != LUA_ERRRUN || b.ToString(-1) != "cannot resume dead coroutine" {
+			t.Errorf("resume dead err, status %v, ret %q", status, b.ToString(-1))
+		}
+		if b.GetTop() != 1 {
+			t.Errorf("resume dead err, want top 1, ret %v", b.GetTop())
+		}
+		b.Pop(1)
+	}
+}
@@ -0,0 +1,118 @@
+package state
+
+import (
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/vm"
+)
+
+// __index/__newindex 链的最大长度，超过后认为出现了循环
+const MAXTAGLOOP = 2000
+
+// lua: lua_createtable
+// nArr 和 nRec 是预先分配的大小，超过 vm.MAXTABLESIZEHINT 时作为过大的请求拒绝，抛出内存错误，
+// 否则 Go 会按请求的大小分配内存，直到进程被杀掉
+func (self *LuaState) CreateTable(nArr, nRec int) {
+	if nArr > vm.MAXTABLESIZEHINT || nRec > vm.MAXTABLESIZEHINT {
+		panic(self.memoryError())
+	}
+	t := newLuaTable(nArr, nRec)
+	self.stack.push(t)
+}
+
+// lua: lua_newtable
+func (self *LuaState) NewTable() {
+	self.CreateTable(0, 0)
+}
+
+// lua: lua_gettable
+// 键从栈顶弹出，t[k] 入栈
+func (self *LuaState) GetTable(idx int) LuaType {
+	t := self.stack.get(idx)
+	k := self.stack.pop()
+	return self.getTable(t, k, false)
+}
+
+// lua: lua_getfield
+func (self *LuaState) GetField(idx int, k string) LuaType {
+	t := self.stack.get(idx)
+	return self.getTable(t, k, false)
+}
+
+// lua: lua_geti
+func (self *LuaState) GetI(idx int, i int64) LuaType {
+	t := self.stack.get(idx)
+	return self.getTable(t, i, false)
+}
+
+// lua: lua_rawget
+func (self *LuaState) RawGet(idx int) LuaType {
+	t := self.stack.get(idx)
+	k := self.stack.pop()
+	return self.getTable(t, k, true)
+}
+
+// lua: lua_rawgeti
+func (self *LuaState) RawGetI(idx int, i int64) LuaType {
+	t := self.stack.get(idx)
+	return self.getTable(t, i, true)
+}
+
+// lua: lua_getmetatable
+// 值有元表时把元表入栈并返回 true，否则不入栈并返回 false
+func (self *LuaState) GetMetatable(idx int) bool {
+	val := self.stack.get(idx)
+	if mt := getMetatable(val, self); mt != nil {
+		self.stack.push(mt)
+		return true
+	}
+	return false
+}
+
+// lua: lua_getglobal
+// 把全局变量 name 的值压入栈顶，返回它的类型
+func (self *LuaState) GetGlobal(name string) LuaType {
+	t := self.registry.get(LUA_RIDX_GLOBALS)
+	return self.getTable(t, name, false)
+}
+
+// lua: lua_getuservalue
+// 把 userdata 的关联值压入栈顶，返回它的类型
+func (self *LuaState) GetUserValue(idx int) LuaType {
+	val := self.stack.get(idx)
+	ud, ok := val.(*userdata)
+	if !ok {
+		panic(self.runtimeError("full userdata expected"))
+	}
+	self.stack.push(ud.userValue)
+	return typeOf(ud.userValue)
+}
+
+// raw 为 true 时忽略 __index 元方法
+func (self *LuaState) getTable(t, k luaValue, raw bool) LuaType {
+	for loop := 0; loop < MAXTAGLOOP; loop++ {
+		if tbl, ok := t.(*luaTable); ok {
+			v := tbl.get(k)
+			if raw || v != nil || !tbl.hasMetafield("__index") {
+				self.stack.push(v)
+				return typeOf(v)
+			}
+		} else if raw {
+			panic(self.runtimeError("table expected"))
+		}
+
+		mf := getMetafield(t, "__index", self)
+		if mf == nil {
+			panic(self.runtimeError("attempt to index a %s value", objTypeName(t, self)))
+		}
+		if _, ok := mf.(*closure); ok {
+			self.stack.check(3)
+			self.stack.push(mf)
+			self.stack.push(t)
+			self.stack.push(k)
+			self.Call(2, 1)
+			return typeOf(self.stack.get(-1))
+		}
+		t = mf // 在 __index 上继续查找
+	}
+	panic(self.runtimeError("'__index' chain too long; possible loop"))
+}
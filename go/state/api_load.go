@@ -0,0 +1,153 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/compiler"
+	"github.com/anccy/luago/go/vm"
+)
+
+// lua: lua_load
+// 加载一段二进制 chunk 或者 Lua 源代码，成功时把得到的函数压入栈顶，
+// 它的第一个 upvalue（_ENV）是全局表；失败时压入错误消息并返回错误码。
+// mode 为 "b"、"t" 或 "bt"（空串相同），表示允许二进制 chunk、源代码或者两者都允许
+func (self *LuaState) Load(chunk []byte, chunkName, mode string) int {
+	if chunkName == "" {
+		chunkName = "?"
+	}
+	if mode == "" {
+		mode = "bt"
+	}
+
+	var proto *binchunk.Prototype
+	if bytes.HasPrefix(chunk, []byte(binchunk.LUA_SIGNATURE[:1])) {
+		if !checkMode(mode, "binary", self) {
+			return LUA_ERRSYNTAX
+		}
+		var err error
+		if proto, err = binchunk.ParseChunk(chunk); err != nil {
+			self.stack.check(1)
+			self.stack.push(fmt.Sprintf("%s: bad binary chunk: %v", binchunk.ChunkID(chunkName), err))
+			return LUA_ERRSYNTAX
+		}
+		// 其他版本的 chunk 只能反汇编，不能执行
+		if proto.Version != binchunk.LUAC_VERSION {
+			self.stack.check(1)
+			self.stack.push(fmt.Sprintf("%s: version mismatch in precompiled chunk", binchunk.ChunkID(chunkName)))
+			return LUA_ERRSYNTAX
+		}
+		// 二进制 chunk 不可信，执行前检查指令中的下标
+		if err = vm.Verify(proto); err != nil {
+			self.stack.check(1)
+			self.stack.push(fmt.Sprintf("%s: bad binary chunk: %v", binchunk.ChunkID(chunkName), err))
+			return LUA_ERRSYNTAX
+		}
+	} else {
+		if !checkMode(mode, "text", self) {
+			return LUA_ERRSYNTAX
+		}
+		var err error
+		if proto, err = compiler.Compile(string(chunk), chunkName); err != nil {
+			self.stack.check(1)
+			self.stack.push(err.Error())
+			return LUA_ERRSYNTAX
+		}
+	}
+
+	self.stack.check(1)
+	self.PushLuaClosure(proto)
+	return LUA_OK
+}
+
+// lua: lua_dump
+// 把栈顶的 Lua 函数写成二进制 chunk，函数不出栈，strip 为 true 时不写调试信息；
+// 栈顶不是 Lua 函数时返回错误
+func (self *LuaState) Dump(w io.Writer, strip bool) error {
+	if c, ok := self.stack.get(-1).(*closure); ok && c.proto != nil {
+		return binchunk.Dump(c.proto, w, strip)
+	}
+	return errors.New("unable to dump given function")
+}
+
+// lua: checkmode
+func checkMode(mode, kind string, ls *LuaState) bool {
+	if !strings.Contains(mode, kind[:1]) {
+		ls.stack.check(1)
+		ls.stack.push(fmt.Sprintf("attempt to load a %s chunk (mode is '%s')", kind, mode))
+		return false
+	}
+	return true
+}
+
+// lua: luaL_loadstring
+// 加载一段 Lua 代码，代码本身也作为 chunk 的名字，成功时把得到的函数压入栈顶，
+// 失败时栈不变，返回 Status 为 LUA_ERRSYNTAX 的 *LuaError
+func (self *LuaState) LoadString(s string) error {
+	return self.loadError(self.Load([]byte(s), s, "bt"))
+}
+
+// lua: luaL_loadfilex
+// 加载文件中的二进制 chunk 或者 Lua 源代码，chunk 的名字为 "@filename"，
+// 源代码第一行以 # 开头时（例如 #!/usr/bin/lua）忽略这一行。
+// 成功时把得到的函数压入栈顶，失败时栈不变，返回 *LuaError，打不开文件时 Status 为 LUA_ERRFILE
+func (self *LuaState) LoadFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		msg := fmt.Sprintf("cannot open %s", filename)
+		if pathErr, ok := err.(*os.PathError); ok {
+			msg += ": " + pathErr.Err.Error()
+		}
+		return &LuaError{Status: LUA_ERRFILE, Value: msg}
+	}
+	return self.loadError(self.Load(skipComment(data), "@"+filename, "bt"))
+}
+
+// lua: skipcomment
+// 跳过 UTF-8 BOM 和以 # 开头的第一行，源代码保留换行符让行号保持不变
+func skipComment(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if len(data) == 0 || data[0] != '#' {
+		return data
+	}
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return nil
+	}
+	if bytes.HasPrefix(data[i+1:], []byte(binchunk.LUA_SIGNATURE[:1])) {
+		return data[i+1:]
+	}
+	return data[i:]
+}
+
+// 把 Load 留在栈顶的错误消息出栈，转换成 *LuaError
+func (self *LuaState) loadError(status int) error {
+	if status == LUA_OK {
+		return nil
+	}
+	return &LuaError{Status: status, Value: self.stack.pop()}
+}
+
+// lua: luaL_dostring
+// 加载并以保护模式调用一段 Lua 代码，返回值全部留在栈上
+func (self *LuaState) DoString(s string) error {
+	if err := self.LoadString(s); err != nil {
+		return err
+	}
+	return self.ProtectedCall(0, LUA_MULTRET)
+}
+
+// lua: luaL_dofile
+// 加载并以保护模式调用文件中的代码，返回值全部留在栈上
+func (self *LuaState) DoFile(filename string) error {
+	if err := self.LoadFile(filename); err != nil {
+		return err
+	}
+	return self.ProtectedCall(0, LUA_MULTRET)
+}
@@ -0,0 +1,143 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+)
+
+func TestLoad(t *testing.T) {
+	ls := New()
+	if status := ls.Load([]byte("x = 1 return x + 1"), "=test", "t"); status != LUA_OK {
+		t.Fatalf("load err, ret %v: %v", status, ls.ToString(-1))
+	}
+	ls.Call(0, 1)
+	if ls.ToInteger(-1) != 2 {
+		t.Errorf("call err, want 2, ret %v", ls.ToString(-1))
+	}
+	if ls.GetGlobal("x"); ls.ToInteger(-1) != 1 {
+		t.Errorf("_ENV err, want x = 1, ret %v", ls.ToString(-1))
+	}
+	ls.SetTop(0)
+
+	// 二进制 chunk
+	data, err := os.ReadFile("../../lua/luac.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := ls.Load(data, "=luac.out", "b"); status != LUA_OK || !ls.IsFunction(-1) {
+		t.Errorf("load binary err, ret %v", status)
+	}
+	ls.SetTop(0)
+
+	// 寄存器越界的二进制 chunk 不能通过检查
+	proto, err := binchunk.ParseChunk(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proto.MaxStackSize = 1
+	bad := &bytes.Buffer{}
+	if err := binchunk.Dump(proto, bad, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		chunk string
+		mode  string
+		want  string
+	}{
+		{"x = ", "bt", "test:1: unexpected symbol near <eof>"},
+		{"return 1", "b", "attempt to load a text chunk (mode is 'b')"},
+		{string(data), "t", "attempt to load a binary chunk (mode is 't')"},
+		{string(data[:20]), "b", "test: bad binary chunk: truncated chunk at offset 17 (reading LUAC_INT)"},
+		{bad.String(), "b", "test: bad binary chunk: invalid function <./hello_world.lua:0> at instruction 2: register 1 out of range"},
+	}
+	for _, test := range tests {
+		status := ls.Load([]byte(test.chunk), "=test", test.mode)
+		if status != LUA_ERRSYNTAX || ls.GetTop() != 1 || ls.ToString(-1) != test.want {
+			t.Errorf("load %q err, want %q, ret %v %q", test.mode, test.want, status, ls.ToString(-1))
+		}
+		ls.SetTop(0)
+	}
+}
+
+func TestDoString(t *testing.T) {
+	ls := New()
+	if err := ls.DoString("local a, b = 1, 2 return a + b, 'x'"); err != nil {
+		t.Fatal(err)
+	}
+	if ls.GetTop() != 2 || ls.ToInteger(1) != 3 || ls.ToString(2) != "x" {
+		t.Errorf("dostring err, want 3 x, top %v", ls.GetTop())
+	}
+	ls.SetTop(0)
+
+	var luaErr *LuaError
+	err := ls.DoString("local t = nil; t.x = 1")
+	if !errors.As(err, &luaErr) || luaErr.Status != LUA_ERRRUN || ls.GetTop() != 0 {
+		t.Errorf("dostring err, want runtime error, ret %v", err)
+	}
+	err = ls.DoString("for")
+	if !errors.As(err, &luaErr) || luaErr.Status != LUA_ERRSYNTAX || ls.GetTop() != 0 {
+		t.Errorf("dostring err, want syntax error, ret %v", err)
+	}
+	if want := `[string "for"]:1: <name> expected near <eof>`; err.Error() != want {
+		t.Errorf("dostring err, want %q, ret %q", want, err.Error())
+	}
+}
+
+func TestDoFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.lua")
+	if err := os.WriteFile(path, []byte("#!/usr/bin/env lua\nx = 1\nreturn 42"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ls := New()
+	if err := ls.DoFile(path); err != nil || ls.ToInteger(-1) != 42 {
+		t.Errorf("dofile err, want 42, ret %v", err)
+	}
+	ls.SetTop(0)
+
+	// 去掉第一行之后行号不变
+	os.WriteFile(path, []byte("#!/usr/bin/env lua\n\nx ="), 0644)
+	if err := ls.DoFile(path); err == nil || !strings.HasSuffix(err.Error(), ":3: unexpected symbol near <eof>") {
+		t.Errorf("dofile err, want line 3, ret %v", err)
+	}
+
+	var luaErr *LuaError
+	err := ls.LoadFile(filepath.Join(dir, "missing.lua"))
+	if !errors.As(err, &luaErr) || luaErr.Status != LUA_ERRFILE {
+		t.Errorf("loadfile err, want LUA_ERRFILE, ret %v", err)
+	}
+}
+
+func TestDump(t *testing.T) {
+	ls := New()
+	if err := ls.DoString("return function(a, b) return a * b end"); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := ls.Dump(buf, true); err != nil || ls.GetTop() != 1 {
+		t.Fatalf("dump err, ret %v", err)
+	}
+	if status := ls.Load(buf.Bytes(), "=dump", "b"); status != LUA_OK {
+		t.Fatalf("load err, ret %v", ls.ToString(-1))
+	}
+	ls.PushInteger(6)
+	ls.PushInteger(7)
+	ls.Call(2, 1)
+	if ls.ToInteger(-1) != 42 {
+		t.Errorf("call err, want 42, ret %v", ls.ToString(-1))
+	}
+
+	ls.PushGoFunction(func(ls LuaStateI) int { return 0 })
+	if err := ls.Dump(buf, false); err == nil {
+		t.Errorf("dump err, want error for Go function")
+	}
+}
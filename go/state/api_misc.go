@@ -0,0 +1,99 @@
+package state
+
+import . "github.com/anccy/luago/go/api"
+
+// lua: lua_len
+func (self *LuaState) Len(idx int) {
+	val := self.stack.get(idx)
+	if s, ok := val.(string); ok {
+		self.stack.push(int64(len(s)))
+	} else if result, ok := callMetamethod(val, val, "__len", self); ok {
+		self.stack.push(result)
+	} else if t, ok := val.(*luaTable); ok {
+		self.stack.push(int64(t.len()))
+	} else {
+		panic(self.runtimeError("attempt to get length of a %s value", objTypeName(val, self)))
+	}
+}
+
+// lua: lua_rawlen
+func (self *LuaState) RawLen(idx int) uint {
+	val := self.stack.get(idx)
+	switch x := val.(type) {
+	case string:
+		return uint(len(x))
+	case *luaTable:
+		return uint(x.len())
+	default:
+		return 0
+	}
+}
+
+// lua: lua_concat
+// 把栈顶 n 个值拼接后出栈，结果入栈；n 为 0 时压入空串
+func (self *LuaState) Concat(n int) {
+	if n == 0 {
+		self.stack.push("")
+	} else if n >= 2 {
+		for i := 1; i < n; i++ {
+			if self.IsString(-1) && self.IsString(-2) {
+				s2 := self.ToString(-1)
+				s1 := self.ToString(-2)
+				self.stack.pop()
+				self.stack.pop()
+				self.stack.push(s1 + s2)
+				continue
+			}
+
+			b := self.stack.pop()
+			a := self.stack.pop()
+			if result, ok := callMetamethod(a, b, "__concat", self); ok {
+				self.stack.push(result)
+				continue
+			}
+
+			if _, ok := a.(string); ok || typeOf(a) == LUA_TNUMBER {
+				a = b
+			}
+			panic(self.runtimeError("attempt to concatenate a %s value", objTypeName(a, self)))
+		}
+	}
+	// n == 1, do nothing
+}
+
+// lua: lua_next
+// 从栈顶弹出键，把下一个键值对入栈并返回 true；遍历结束时不入栈并返回 false
+func (self *LuaState) Next(idx int) bool {
+	val := self.stack.get(idx)
+	t, ok := val.(*luaTable)
+	if !ok {
+		panic(self.runtimeError("table expected"))
+	}
+
+	key := self.stack.pop()
+	for {
+		var ok bool
+		if key, ok = t.nextKey(key); !ok {
+			panic(self.runtimeError("invalid key to 'next'"))
+		}
+		if key == nil {
+			return false
+		}
+		// 遍历过程中被赋值为 nil 的字段直接跳过
+		if v := t.get(key); v != nil {
+			self.stack.push(key)
+			self.stack.push(v)
+			return true
+		}
+	}
+}
+
+// lua: lua_stringtonumber
+// 按 Lua 的语法把字符串转换成数字压入栈顶，返回 len(s)+1；转换失败时不压栈并返回 0
+func (self *LuaState) StringToNumber(s string) int {
+	if n, ok := stringToNumber(s); ok {
+		self.stack.push(n)
+		return len(s) + 1
+	}
+	return 0
+}
@@ -1,5 +1,11 @@
 package state
 
+import (
+	"reflect"
+
+	. "github.com/anccy/luago/go/api"
+)
+
 func (self *LuaState) PushNil() {
 	self.stack.push(nil)
 }
@@ -19,3 +25,39 @@ func (self *LuaState) PushNumber(n float64) {
 func (self *LuaState) PushString(s string) {
 	self.stack.push(s)
 }
+
+func (self *LuaState) PushGoFunction(f GoFunction) {
+	self.stack.push(newGoClosure(f, 0))
+}
+
+// lua: lua_pushcclosure
+// 从栈顶弹出 n 个值作为 Go 闭包的 upvalue，然后把闭包压入栈顶，
+// 闭包内通过 LuaUpvalueIndex(i) 访问它们
+func (self *LuaState) PushGoClosure(f GoFunction, n int) {
+	c := newGoClosure(f, n)
+	for i := n; i > 0; i-- {
+		c.upvals[i-1] = &upvalue{val: self.stack.pop()}
+	}
+	self.stack.push(c)
+}
+
+// lua: lua_pushglobaltable
+func (self *LuaState) PushGlobalTable() {
+	global := self.registry.get(LUA_RIDX_GLOBALS)
+	self.stack.push(global)
+}
+
+// lua: lua_newuserdata
+// 把 Go 值包装成 full userdata 压入栈顶
+func (self *LuaState) NewUserdata(value interface{}) {
+	self.stack.push(&userdata{value: value})
+}
+
+// lua: lua_pushlightuserdata
+// p 必须是可比较的值，例如指针或整数句柄
+func (self *LuaState) PushLightUserdata(p interface{}) {
+	if p == nil || !reflect.TypeOf(p).Comparable() {
+		panic(self.runtimeError("light userdata must be a comparable value"))
+	}
+	self.stack.push(lightUserdata{p})
+}
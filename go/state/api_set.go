@@ -0,0 +1,126 @@
+package state
+
+import (
+	"math"
+
+	. "github.com/anccy/luago/go/api"
+)
+
+// lua: lua_settable
+// 值和键依次从栈顶弹出，t[k] = v
+func (self *LuaState) SetTable(idx int) {
+	t := self.stack.get(idx)
+	v := self.stack.pop()
+	k := self.stack.pop()
+	self.setTable(t, k, v, false)
+}
+
+// lua: lua_setfield
+func (self *LuaState) SetField(idx int, k string) {
+	t := self.stack.get(idx)
+	v := self.stack.pop()
+	self.setTable(t, k, v, false)
+}
+
+// lua: lua_seti
+func (self *LuaState) SetI(idx int, i int64) {
+	t := self.stack.get(idx)
+	v := self.stack.pop()
+	self.setTable(t, i, v, false)
+}
+
+// lua: lua_rawset
+func (self *LuaState) RawSet(idx int) {
+	t := self.stack.get(idx)
+	v := self.stack.pop()
+	k := self.stack.pop()
+	self.setTable(t, k, v, true)
+}
+
+// lua: lua_rawseti
+func (self *LuaState) RawSetI(idx int, i int64) {
+	t := self.stack.get(idx)
+	v := self.stack.pop()
+	self.setTable(t, i, v, true)
+}
+
+// lua: lua_setmetatable
+// 从栈顶弹出 table 或 nil，设置为指定值的元表
+func (self *LuaState) SetMetatable(idx int) {
+	val := self.stack.get(idx)
+	mtVal := self.stack.pop()
+
+	if mtVal == nil {
+		setMetatable(val, nil, self)
+	} else if mt, ok := mtVal.(*luaTable); ok {
+		setMetatable(val, mt, self)
+	} else {
+		panic(self.runtimeError("table expected"))
+	}
+}
+
+// lua: lua_setglobal
+// 从栈顶弹出一个值赋给全局变量 name
+func (self *LuaState) SetGlobal(name string) {
+	t := self.registry.get(LUA_RIDX_GLOBALS)
+	v := self.stack.pop()
+	self.setTable(t, name, v, false)
+}
+
+// lua: lua_register
+// 把 Go 函数注册为全局变量 name
+func (self *LuaState) Register(name string, f GoFunction) {
+	self.PushGoFunction(f)
+	self.SetGlobal(name)
+}
+
+// lua: lua_setuservalue
+// 从栈顶弹出一个值，设置为 userdata 的关联值
+func (self *LuaState) SetUserValue(idx int) {
+	val := self.stack.get(idx)
+	ud, ok := val.(*userdata)
+	if !ok {
+		panic(self.runtimeError("full userdata expected"))
+	}
+	ud.userValue = self.stack.pop()
+}
+
+// raw 为 true 时忽略 __newindex 元方法
+func (self *LuaState) setTable(t, k, v luaValue, raw bool) {
+	for loop := 0; loop < MAXTAGLOOP; loop++ {
+		if tbl, ok := t.(*luaTable); ok {
+			if raw || tbl.get(k) != nil || !tbl.hasMetafield("__newindex") {
+				self.checkTableKey(k)
+				tbl.put(k, v)
+				return
+			}
+		} else if raw {
+			panic(self.runtimeError("table expected"))
+		}
+
+		mf := getMetafield(t, "__newindex", self)
+		if mf == nil {
+			panic(self.runtimeError("attempt to index a %s value", objTypeName(t, self)))
+		}
+		if _, ok := mf.(*closure); ok {
+			self.stack.check(4)
+			self.stack.push(mf)
+			self.stack.push(t)
+			self.stack.push(k)
+			self.stack.push(v)
+			self.Call(3, 0)
+			return
+		}
+		t = mf // 在 __newindex 上继续赋值
+	}
+	panic(self.runtimeError("'__newindex' chain too long; possible loop"))
+}
+
+func (self *LuaState) checkTableKey(k luaValue) {
+	if k == nil {
+		panic(self.runtimeError("table index is nil"))
+	}
+	if f, ok := k.(float64); ok && math.IsNaN(f) {
+		panic(self.runtimeError("table index is NaN"))
+	}
+}
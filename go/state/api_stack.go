@@ -8,9 +8,10 @@ func (self *LuaState) AbsIndex(idx int) int {
 	return self.stack.absIndex(idx)
 }
 
+// lua: lua_checkstack
+// 确保栈上至少有 n 个空闲槽位，超过栈大小上限时返回 false
 func (self *LuaState) CheckStack(n int) bool {
-	self.stack.check(n)
-	return true
+	return self.stack.check(n)
 }
 
 func (self *LuaState) Pop(n int) {
@@ -60,7 +61,7 @@ func (self *LuaState) Rotate(idx, n int) {
 func (self *LuaState) SetTop(idx int) {
 	newTop := self.stack.absIndex(idx)
 	if newTop < 0 {
-		panic("stack underflow")
+		panic(self.runtimeError("stack underflow"))
 	}
 
 	n := self.stack.top - newTop
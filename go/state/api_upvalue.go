@@ -0,0 +1,61 @@
+package state
+
+// 返回函数 funcIdx 的第 n 个 upvalue，n 从 1 开始
+func (self *LuaState) upvalueAt(funcIdx, n int) (*closure, *upvalue, string, bool) {
+	c, ok := self.stack.get(funcIdx).(*closure)
+	if !ok || n < 1 || n > len(c.upvals) {
+		return nil, nil, "", false
+	}
+
+	name := "" // Go 函数的 upvalue 没有名字
+	if c.proto != nil {
+		name = "(*no name)"
+		if n <= len(c.proto.UpvalueNames) {
+			name = c.proto.UpvalueNames[n-1]
+		}
+	}
+	return c, c.upvals[n-1], name, true
+}
+
+// lua: lua_getupvalue
+// 把函数 funcIdx 的第 n 个 upvalue 压入栈顶，返回它的名字；n 无效时不压栈并返回 false
+func (self *LuaState) GetUpvalue(funcIdx, n int) (string, bool) {
+	_, uv, name, ok := self.upvalueAt(funcIdx, n)
+	if !ok {
+		return "", false
+	}
+	self.stack.push(uv.get())
+	return name, true
+}
+
+// lua: lua_setupvalue
+// 从栈顶弹出一个值赋给函数 funcIdx 的第 n 个 upvalue；n 无效时不出栈并返回 false
+func (self *LuaState) SetUpvalue(funcIdx, n int) (string, bool) {
+	_, uv, name, ok := self.upvalueAt(funcIdx, n)
+	if !ok {
+		return "", false
+	}
+	uv.set(self.stack.pop())
+	return name, true
+}
+
+// lua: lua_upvalueid
+// 返回 upvalue 的唯一标识，共享同一个 upvalue 的闭包得到相同的结果
+func (self *LuaState) UpvalueID(funcIdx, n int) interface{} {
+	_, uv, _, ok := self.upvalueAt(funcIdx, n)
+	if !ok {
+		return nil
+	}
+	return uv
+}
+
+// lua: lua_upvaluejoin
+// 让函数 funcIdx1 的第 n1 个 upvalue 引用函数 funcIdx2 的第 n2 个 upvalue
+func (self *LuaState) UpvalueJoin(funcIdx1, n1, funcIdx2, n2 int) {
+	c1, _, _, ok1 := self.upvalueAt(funcIdx1, n1)
+	_, uv2, _, ok2 := self.upvalueAt(funcIdx2, n2)
+	if !ok1 || !ok2 {
+		panic(self.runtimeError("invalid upvalue index"))
+	}
+	c1.upvals[n1-1] = uv2
+}
@@ -0,0 +1,85 @@
+package state
+
+func (self *LuaState) PC() int {
+	return self.stack.pc
+}
+
+func (self *LuaState) AddPC(n int) {
+	self.stack.pc += n
+}
+
+func (self *LuaState) Fetch() uint32 {
+	i := self.stack.closure.proto.Code[self.stack.pc]
+	self.stack.pc++
+	return i
+}
+
+func (self *LuaState) GetConst(idx int) {
+	c := self.stack.closure.proto.Constants[idx]
+	self.stack.push(c)
+}
+
+// rk 的最高位为 1 表示常量表索引，否则表示寄存器索引
+func (self *LuaState) GetRK(rk int) {
+	if rk > 0xFF { // constant
+		self.GetConst(rk & 0xFF)
+	} else { // register
+		self.PushValue(rk + 1)
+	}
+}
+
+func (self *LuaState) RegisterCount() int {
+	return int(self.stack.closure.proto.MaxStackSize)
+}
+
+// n < 0 时压入全部变长参数
+func (self *LuaState) LoadVararg(n int) {
+	if n < 0 {
+		n = len(self.stack.varargs)
+	}
+	self.stack.check(n)
+	self.stack.pushN(self.stack.varargs, n)
+}
+
+func (self *LuaState) LoadProto(idx int) {
+	stack := self.stack
+	subProto := stack.closure.proto.Protos[idx]
+	closure := newLuaClosure(subProto)
+	stack.push(closure)
+
+	for i, uvInfo := range subProto.Upvalues {
+		uvIdx := int(uvInfo.Idx)
+		if uvInfo.Instack == 1 { // 捕获当前函数的局部变量
+			if stack.openuvs == nil {
+				stack.openuvs = map[int]*upvalue{}
+			}
+			if openuv, found := stack.openuvs[uvIdx]; found {
+				closure.upvals[i] = openuv
+			} else {
+				closure.upvals[i] = &upvalue{stack: stack, idx: uvIdx}
+				stack.openuvs[uvIdx] = closure.upvals[i]
+			}
+		} else { // 捕获当前函数的 upvalue
+			closure.upvals[i] = stack.closure.upvals[uvIdx]
+		}
+	}
+}
+
+func (self *LuaState) LoadUpvalue(idx int) {
+	uv := self.stack.closure.upvals[idx]
+	self.stack.push(uv.get())
+}
+
+func (self *LuaState) StoreUpvalue(idx int) {
+	uv := self.stack.closure.upvals[idx]
+	uv.set(self.stack.pop())
+}
+
+func (self *LuaState) RunError(msg string) {
+	panic(self.runtimeError("%s", msg))
+}
+
+// 关闭 R(a-1) 及其之上的寄存器的 upvalue
+func (self *LuaState) CloseUpvalues(a int) {
+	self.stack.closeUpvalues(a - 1)
+}
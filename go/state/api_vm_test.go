@@ -0,0 +1,202 @@
+package state
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/vm"
+)
+
+func iABC(op, a, b, c int) uint32 {
+	return uint32(op | a<<6 | c<<14 | b<<23)
+}
+
+func iABx(op, a, bx int) uint32 {
+	return uint32(op | a<<6 | bx<<14)
+}
+
+func iAsBx(op, a, sbx int) uint32 {
+	return iABx(op, a, sbx+vm.MAXARG_sBx)
+}
+
+func rk(idx int) int {
+	return 0x100 | idx
+}
+
+// local sum = 0
+// for i = 1, 100 do if i % 2 == 0 then sum = sum + i end end
+func TestExecute(t *testing.T) {
+	proto := &binchunk.Prototype{
+		MaxStackSize: 6,
+		Constants:    []interface{}{int64(0), int64(1), int64(100), int64(2)},
+		Code: []uint32{
+			iABx(vm.OP_LOADK, 0, 0),
+			iABx(vm.OP_LOADK, 1, 1),
+			iABx(vm.OP_LOADK, 2, 2),
+			iABx(vm.OP_LOADK, 3, 1),
+			iAsBx(vm.OP_FORPREP, 1, 3),
+			iABC(vm.OP_MOD, 5, 4, rk(3)),
+			iABC(vm.OP_EQ, 1, 5, rk(0)),
+			iABC(vm.OP_ADD, 0, 0, 4),
+			iAsBx(vm.OP_FORLOOP, 1, -4),
+			iABC(vm.OP_RETURN, 0, 2, 0),
+		},
+	}
+
+	ls := New()
+	ls.PushLuaClosure(proto)
+	ls.Call(0, 1)
+	if ret := ls.ToInteger(-1); ret != 2550 {
+		t.Errorf("execute err, want 2550, ret %v", ret)
+	}
+}
+
+// local f = ...
+// return f(1, 2)
+func TestCallGoFunction(t *testing.T) {
+	proto := &binchunk.Prototype{
+		NumParams:    1,
+		MaxStackSize: 4,
+		Constants:    []interface{}{int64(1), int64(2)},
+		Code: []uint32{
+			iABC(vm.OP_MOVE, 1, 0, 0),
+			iABx(vm.OP_LOADK, 2, 0),
+			iABx(vm.OP_LOADK, 3, 1),
+			iABC(vm.OP_TAILCALL, 1, 3, 0),
+			iABC(vm.OP_RETURN, 1, 0, 0),
+		},
+	}
+	sum := func(ls LuaStateI) int {
+		n := ls.GetTop()
+		var s int64
+		for i := 1; i <= n; i++ {
+			s += ls.ToInteger(i)
+		}
+		ls.PushInteger(s)
+		ls.PushInteger(int64(n))
+		return 2
+	}
+
+	ls := New()
+	ls.PushLuaClosure(proto)
+	ls.PushGoFunction(sum)
+	ls.Call(1, LUA_MULTRET)
+	if ls.GetTop() != 2 || ls.ToInteger(1) != 3 || ls.ToInteger(2) != 2 {
+		t.Errorf("call err, want [3][2], ret top %v", ls.GetTop())
+	}
+}
+
+// return ...
+func TestVararg(t *testing.T) {
+	proto := &binchunk.Prototype{
+		IsVararg:     1,
+		MaxStackSize: 2,
+		Code: []uint32{
+			iABC(vm.OP_VARARG, 0, 0, 0),
+			iABC(vm.OP_RETURN, 0, 0, 0),
+		},
+	}
+
+	ls := New()
+	ls.PushLuaClosure(proto)
+	ls.PushString("a")
+	ls.PushString("b")
+	ls.PushString("c")
+	ls.Call(3, 2)
+	if ls.GetTop() != 2 || ls.ToString(1) != "a" || ls.ToString(2) != "b" {
+		t.Errorf("vararg err, want [a][b], ret top %v", ls.GetTop())
+	}
+}
+
+// 尾调用复用调用帧，尾递归的深度不受栈大小限制
+func TestTailCall(t *testing.T) {
+	ls := New()
+	ls.Register("id", func(ls LuaStateI) int { return ls.GetTop() })
+	// t 的 __call 元方法返回前两个参数
+	ls.NewTable()
+	ls.NewTable()
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.SetTop(3)
+		return 2
+	})
+	ls.SetField(-2, "__call")
+	ls.SetMetatable(-2)
+	ls.SetGlobal("t")
+	err := ls.DoString(`
+		local function loop(n, acc)
+			if n == 0 then return acc end
+			return loop(n - 1, acc + 1)
+		end
+		-- 尾调用之前关闭当前函数的 upvalue
+		local function mk(x)
+			local function get() return x end
+			return (function(f) return f end)(get)
+		end
+		local function callT() return t(1, 2) end
+		local function callGo(...) return id(...) end
+		return loop(100000, 0), mk("up")(), callT(), callGo(3, 4)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"100000", "up", "1", "3", "4"}
+	if ls.GetTop() != len(want) {
+		t.Fatalf("tail call err, want %v results, ret %v", len(want), ls.GetTop())
+	}
+	for i, w := range want {
+		if ret := ls.ToString(i + 1); ret != w {
+			t.Errorf("tail call result %d err, want %v, ret %v", i+1, w, ret)
+		}
+	}
+}
+
+// 没有经过 Verify 的 chunk 使用栈顶不存在的返回值时抛出 Lua 错误，而不是让宿主 panic
+func TestInvalidOpenResults(t *testing.T) {
+	codes := [][]uint32{
+		{iABC(vm.OP_RETURN, 2, 0, 0), iABC(vm.OP_RETURN, 0, 1, 0)},
+		{iABC(vm.OP_LOADK, 5, 0, 0), iABC(vm.OP_RETURN, 2, 0, 0), iABC(vm.OP_RETURN, 0, 1, 0)},
+		{iABC(vm.OP_TAILCALL, 3, 0, 2), iABC(vm.OP_RETURN, 3, 0, 0)},
+		{iABC(vm.OP_CALL, 2, 0, 1), iABC(vm.OP_RETURN, 0, 1, 0)},
+		{iABC(vm.OP_NEWTABLE, 0, 0, 0), iABC(vm.OP_SETLIST, 0, 0, 1), iABC(vm.OP_RETURN, 0, 1, 0)},
+	}
+	for _, code := range codes {
+		ls := New()
+		ls.PushLuaClosure(&binchunk.Prototype{Source: "@poc", MaxStackSize: 6,
+			Constants: []interface{}{int64(100)}, Code: code})
+		status := ls.PCall(0, 0, 0)
+		if status != LUA_ERRRUN || ls.ToString(-1) != "poc:?: invalid open results" {
+			t.Errorf("open results %v err, ret %v %q", code, status, ls.ToString(-1))
+		}
+	}
+}
+
+// lua 5.3 的数值 for 循环：字符串转换成数字，步长可以为 0
+func TestForPrep(t *testing.T) {
+	tests := []struct {
+		chunk string
+		want  string
+	}{
+		{`local s = "" for i = "1", 2 do s = s .. i .. " " end return s`, "1.0 2.0 "},
+		{`local s = "" for i = 1, "2" do s = s .. i .. " " end return s`, "1 2 "},
+		{`local s = "" for i = 1, 2, "1" do s = s .. i .. " " end return s`, "1.0 2.0 "},
+		{`local n = 0 for i = 1, 2, 0 do n = n + 1 if n == 3 then break end end return n`, "3"},
+		{`for i = 1, 0, 0 do return 1 end return 2`, "2"},
+		{`for i = 1, {} do end`, "'for' limit must be a number"},
+		{`for i = 1, 2, "x" do end`, "'for' step must be a number"},
+		{`for i = "x", 2 do end`, "'for' initial value must be a number"},
+	}
+	for _, test := range tests {
+		ls := New()
+		ret := ""
+		if err := ls.DoString(test.chunk); err != nil {
+			ret = err.Error()
+		} else {
+			ret = ls.ToString(-1)
+		}
+		if !strings.HasSuffix(ret, test.want) {
+			t.Errorf("for %q err, want %q, ret %q", test.chunk, test.want, ret)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package state
+
+import (
+	"fmt"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+)
+
+// lua: luaL_tolstring
+// 按 tostring 的规则把任意值转换成字符串，结果同时压入栈顶
+func (self *LuaState) ToString2(idx int) string {
+	val := self.stack.get(idx)
+	if mm := getMetafield(val, "__tostring", self); mm != nil {
+		self.stack.check(2)
+		self.stack.push(mm)
+		self.stack.push(val)
+		self.Call(1, 1)
+		if !self.IsString(-1) {
+			panic(self.runtimeError("'__tostring' must return a string"))
+		}
+		return self.ToString(-1)
+	}
+
+	self.stack.check(1)
+	switch typeOf(val) {
+	case LUA_TNUMBER, LUA_TSTRING:
+		self.stack.push(val)
+	case LUA_TBOOLEAN:
+		self.stack.push(fmt.Sprintf("%t", val))
+	case LUA_TNIL:
+		self.stack.push("nil")
+	default:
+		kind := objTypeName(val, self)
+		if lud, ok := val.(lightUserdata); ok {
+			self.stack.push(kind + ": " + pointerString(lud.p))
+		} else {
+			self.stack.push(fmt.Sprintf("%s: %p", kind, val))
+		}
+	}
+	return self.ToString(-1)
+}
+
+// lua: luaL_where
+// 把第 level 层函数当前执行到的位置 "chunkname:currentline: " 压入栈顶，
+// level 为 0 表示正在执行的函数，1 表示调用它的函数，没有位置信息时压入空串
+func (self *LuaState) Where(level int) {
+	stack := self.stack
+	for ; level > 0 && stack != nil; level-- {
+		stack = stack.prev
+	}
+
+	if stack != nil && stack.closure != nil && stack.closure.proto != nil {
+		if line := currentLine(stack); line != "?" {
+			source := binchunk.ChunkID(stack.closure.proto.Source)
+			self.stack.push(fmt.Sprintf("%s:%s: ", source, line))
+			return
+		}
+	}
+	self.stack.push("")
+}
+
+// lua: luaL_error
+// 抛出带有调用者位置信息的错误
+func (self *LuaState) Error2(format string, a ...interface{}) int {
+	self.Where(1)
+	self.stack.push(fmt.Sprintf(format, a...))
+	self.Concat(2)
+	return self.Error()
+}
+
+// lua: luaL_argerror
+func (self *LuaState) ArgError(arg int, extraMsg string) int {
+	return self.Error2("bad argument #%d (%s)", arg, extraMsg)
+}
+
+// lua: luaL_argcheck
+func (self *LuaState) ArgCheck(cond bool, arg int, extraMsg string) {
+	if !cond {
+		self.ArgError(arg, extraMsg)
+	}
+}
+
+func (self *LuaState) typeError(arg int, tname string) int {
+	val := self.stack.get(arg)
+	typeArg := objTypeName(val, self)
+	if typeArg == "userdata" && typeOf(val) == LUA_TLIGHTUSERDATA {
+		typeArg = "light userdata"
+	}
+	return self.ArgError(arg, fmt.Sprintf("%s expected, got %s", tname, typeArg))
+}
+
+// lua: luaL_newmetatable
+// 注册表中已有名为 tname 的元表时返回 false，否则创建新元表并以 tname 为键存入注册表，
+// 两种情况下都把注册表中的元表压入栈顶
+func (self *LuaState) NewMetatable(tname string) bool {
+	if mt := self.registry.get(tname); mt != nil {
+		self.stack.push(mt) // name already in use
+		return false
+	}
+
+	mt := newLuaTable(0, 2)
+	mt.put("__name", tname)
+	self.registry.put(tname, mt)
+	self.stack.push(mt)
+	return true
+}
+
+// lua: luaL_getmetatable
+// 把注册表中名为 tname 的元表压入栈顶
+func (self *LuaState) GetMetatable2(tname string) LuaType {
+	mt := self.registry.get(tname)
+	self.stack.push(mt)
+	return typeOf(mt)
+}
+
+// lua: luaL_setmetatable
+// 把注册表中名为 tname 的元表设置为栈顶对象的元表
+func (self *LuaState) SetMetatable2(tname string) {
+	self.GetMetatable2(tname)
+	self.SetMetatable(-2)
+}
+
+// lua: luaL_testudata
+// 值是元表为 tname 的 userdata 时返回它包装的 Go 值，否则返回 nil
+func (self *LuaState) TestUdata(arg int, tname string) interface{} {
+	if ud, ok := self.stack.get(arg).(*userdata); ok {
+		if mt := self.registry.get(tname); mt != nil && ud.metatable == mt {
+			return ud.value
+		}
+	}
+	return nil
+}
+
+// lua: luaL_checkudata
+// 和 TestUdata 一样，但值不符合时抛出参数错误
+func (self *LuaState) CheckUdata(arg int, tname string) interface{} {
+	if ud, ok := self.stack.get(arg).(*userdata); ok {
+		if mt := self.registry.get(tname); mt != nil && ud.metatable == mt {
+			return ud.value
+		}
+	}
+	self.typeError(arg, tname)
+	return nil
+}
+
+/* index of free-list header */
+const freelist = 0
+
+// lua: luaL_ref
+// 从栈顶弹出一个值存入表 t，返回它在 t 中的整数键；值为 nil 时返回 LUA_REFNIL
+func (self *LuaState) Ref(t int) int {
+	if self.IsNil(-1) {
+		self.Pop(1) // remove it from stack
+		return LUA_REFNIL
+	}
+	t = self.AbsIndex(t)
+	self.RawGetI(t, freelist) // get first free element
+	ref := int(self.ToInteger(-1))
+	self.Pop(1)
+	if ref != 0 { // any free element?
+		self.RawGetI(t, int64(ref)) // remove it from list
+		self.RawSetI(t, freelist)   // (t[freelist] = t[ref])
+	} else { // no free elements
+		ref = int(self.RawLen(t)) + 1 // get a new reference
+	}
+	self.RawSetI(t, int64(ref))
+	return ref
+}
+
+// lua: luaL_unref
+// 释放 Ref 返回的键 ref，它之后可以被再次使用
+func (self *LuaState) Unref(t, ref int) {
+	if ref >= 0 {
+		t = self.AbsIndex(t)
+		self.RawGetI(t, freelist)
+		self.RawSetI(t, int64(ref)) // t[ref] = t[freelist]
+		self.PushInteger(int64(ref))
+		self.RawSetI(t, freelist) // t[freelist] = ref
+	}
+}
@@ -0,0 +1,60 @@
+package state
+
+import (
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+)
+
+// closure 要么是 Lua 闭包（proto 非 nil），要么是 Go 函数（goFunc 非 nil）
+type closure struct {
+	proto  *binchunk.Prototype
+	goFunc GoFunction
+	upvals []*upvalue
+}
+
+// upvalue 在打开状态下引用所在栈帧的寄存器，关闭后自己保存值，
+// 多个闭包捕获同一个局部变量时共享同一个 upvalue
+type upvalue struct {
+	stack *luaStack // 非 nil 表示打开状态
+	idx   int       // 寄存器在 stack.slots 中的下标
+	val   luaValue  // 关闭后的值
+}
+
+func newLuaClosure(proto *binchunk.Prototype) *closure {
+	c := &closure{proto: proto}
+	if nUpvals := len(proto.Upvalues); nUpvals > 0 {
+		c.upvals = make([]*upvalue, nUpvals)
+		for i := range c.upvals {
+			c.upvals[i] = &upvalue{}
+		}
+	}
+	return c
+}
+
+func newGoClosure(f GoFunction, nUpvals int) *closure {
+	c := &closure{goFunc: f}
+	if nUpvals > 0 {
+		c.upvals = make([]*upvalue, nUpvals)
+	}
+	return c
+}
+
+func (self *upvalue) get() luaValue {
+	if self.stack != nil {
+		return self.stack.slots[self.idx]
+	}
+	return self.val
+}
+
+func (self *upvalue) set(val luaValue) {
+	if self.stack != nil {
+		self.stack.slots[self.idx] = val
+	} else {
+		self.val = val
+	}
+}
+
+func (self *upvalue) close() {
+	self.val = self.stack.slots[self.idx]
+	self.stack = nil
+}
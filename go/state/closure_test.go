@@ -0,0 +1,106 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/vm"
+)
+
+// local x = 0
+// local function inc() x = x + 1; return x end
+// local function get() return x end
+// inc(); inc()
+// return get, inc
+func upvalueProto() *binchunk.Prototype {
+	inc := &binchunk.Prototype{
+		MaxStackSize: 2,
+		Constants:    []interface{}{int64(1)},
+		Upvalues:     []binchunk.Upvalue{{Instack: 1, Idx: 0}},
+		UpvalueNames: []string{"x"},
+		Code: []uint32{
+			iABC(vm.OP_GETUPVAL, 0, 0, 0),
+			iABC(vm.OP_ADD, 0, 0, rk(0)),
+			iABC(vm.OP_SETUPVAL, 0, 0, 0),
+			iABC(vm.OP_RETURN, 0, 2, 0),
+		},
+	}
+	get := &binchunk.Prototype{
+		MaxStackSize: 2,
+		Upvalues:     []binchunk.Upvalue{{Instack: 1, Idx: 0}},
+		UpvalueNames: []string{"x"},
+		Code: []uint32{
+			iABC(vm.OP_GETUPVAL, 0, 0, 0),
+			iABC(vm.OP_RETURN, 0, 2, 0),
+		},
+	}
+	return &binchunk.Prototype{
+		MaxStackSize: 5,
+		Constants:    []interface{}{int64(0)},
+		Protos:       []*binchunk.Prototype{inc, get},
+		Code: []uint32{
+			iABx(vm.OP_LOADK, 0, 0),
+			iABx(vm.OP_CLOSURE, 1, 0),
+			iABx(vm.OP_CLOSURE, 2, 1),
+			iABC(vm.OP_MOVE, 3, 1, 0),
+			iABC(vm.OP_CALL, 3, 1, 1),
+			iABC(vm.OP_MOVE, 3, 1, 0),
+			iABC(vm.OP_CALL, 3, 1, 1),
+			iABC(vm.OP_MOVE, 3, 2, 0),
+			iABC(vm.OP_MOVE, 4, 1, 0),
+			iABC(vm.OP_RETURN, 3, 3, 0),
+		},
+	}
+}
+
+func TestUpvalue(t *testing.T) {
+	ls := New()
+	ls.PushLuaClosure(upvalueProto())
+	ls.Call(0, 2) // get, inc
+
+	// 函数返回后 upvalue 已经关闭，但仍被两个闭包共享
+	ls.PushValue(2)
+	ls.Call(0, 0)
+	ls.PushValue(1)
+	ls.Call(0, 1)
+	if ret := ls.ToInteger(-1); ret != 3 {
+		t.Errorf("upvalue err, want 3, ret %v", ret)
+	}
+	ls.Pop(1)
+
+	if ls.UpvalueID(1, 1) != ls.UpvalueID(2, 1) {
+		t.Error("upvalue should be shared")
+	}
+	if name, ok := ls.GetUpvalue(1, 1); !ok || name != "x" || ls.ToInteger(-1) != 3 {
+		t.Errorf("get upvalue err, name %q", name)
+	}
+	ls.Pop(1)
+	if _, ok := ls.GetUpvalue(1, 2); ok {
+		t.Error("upvalue 2 should be invalid")
+	}
+
+	ls.PushInteger(10)
+	ls.SetUpvalue(2, 1)
+	ls.PushValue(1)
+	ls.Call(0, 1)
+	if ret := ls.ToInteger(-1); ret != 10 {
+		t.Errorf("set upvalue err, want 10, ret %v", ret)
+	}
+	ls.Pop(1)
+}
+
+func TestUpvalueJoin(t *testing.T) {
+	ls := New()
+	ls.PushLuaClosure(upvalueProto())
+	ls.Call(0, 2)
+	ls.PushLuaClosure(upvalueProto())
+	ls.Call(0, 2)
+
+	// 第二组的 get 改为读取第一组的 x
+	ls.UpvalueJoin(3, 1, 1, 1)
+	ls.PushValue(3)
+	ls.Call(0, 1)
+	if ret := ls.ToInteger(-1); ret != 2 || ls.UpvalueID(3, 1) == ls.UpvalueID(4, 1) {
+		t.Errorf("upvalue join err, want 2, ret %v", ret)
+	}
+}
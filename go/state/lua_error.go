@@ -0,0 +1,119 @@
+package state
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+)
+
+// LuaError 是在 Go 中传递的 Lua 错误，Value 是被抛出的任意 Lua 值
+type LuaError struct {
+	Status    int         // LUA_ERRRUN, LUA_ERRMEM（拒绝过大的分配请求）, LUA_ERRERR，加载时为 LUA_ERRSYNTAX 或 LUA_ERRFILE
+	Value     interface{} // 错误对象
+	Traceback string      // 抛出错误时的调用栈
+}
+
+func (self *LuaError) Error() string {
+	switch x := self.Value.(type) {
+	case string:
+		return x
+	case int64, float64:
+		return numberToString(x)
+	default:
+		return fmt.Sprintf("(error object is a %s value)", typeNameOf(x))
+	}
+}
+
+func (self *LuaState) newLuaError(status int, val luaValue) *LuaError {
+	return &LuaError{
+		Status:    status,
+		Value:     val,
+		Traceback: self.traceback(),
+	}
+}
+
+// runtimeError 构造一个运行时错误，当前正在执行 Lua 函数时在消息前加上位置信息
+func (self *LuaState) runtimeError(format string, a ...interface{}) *LuaError {
+	msg := fmt.Sprintf(format, a...)
+	if c := self.stack.closure; c != nil && c.proto != nil {
+		msg = fmt.Sprintf("%s:%s: %s", binchunk.ChunkID(c.proto.Source), currentLine(self.stack), msg)
+	}
+	return self.newLuaError(LUA_ERRRUN, msg)
+}
+
+// lua: luaD_throw(L, LUA_ERRMEM)
+// 拒绝过大的分配请求时抛出的错误，和 Lua 一样错误对象是固定的字符串，不带位置信息。
+// Go 中真正的内存耗尽是不可恢复的 fatal error，不会变成这个错误
+func (self *LuaState) memoryError() *LuaError {
+	return self.newLuaError(LUA_ERRMEM, "not enough memory")
+}
+
+// 分配 n 个槽位。n 超出 make 能接受的长度时 make 会 panic，把这种过大的请求转换成内存错误；
+// 长度合法但是内存不够时 Go 直接终止进程，这里无法处理
+func (self *LuaState) allocSlots(n int) []luaValue {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(self.memoryError())
+			}
+			panic(r)
+		}
+	}()
+	return make([]luaValue, n)
+}
+
+// toLuaError 把 recover 得到的值转换成 *LuaError，Go 函数 panic 的其他值按运行时错误处理。
+// runtime.Error 是解释器或者 Go 函数自身的 bug（空指针、下标越界等），不能被 pcall 吞掉，继续 panic
+func (self *LuaState) toLuaError(r interface{}) *LuaError {
+	switch x := r.(type) {
+	case *LuaError:
+		return x
+	case runtime.Error:
+		panic(x)
+	case error:
+		return self.newLuaError(LUA_ERRRUN, x.Error())
+	default:
+		return self.newLuaError(LUA_ERRRUN, fmt.Sprint(x))
+	}
+}
+
+// lua: luaL_traceback
+func (self *LuaState) traceback() string {
+	sb := &strings.Builder{}
+	sb.WriteString("stack traceback:")
+	for stack := self.stack; stack != nil; stack = stack.prev {
+		c := stack.closure
+		if c == nil { // 最外层的宿主栈帧
+			continue
+		}
+		if c.proto == nil {
+			sb.WriteString("\n\t[Go]: in ?")
+			continue
+		}
+
+		p := c.proto
+		source := binchunk.ChunkID(p.Source)
+		fmt.Fprintf(sb, "\n\t%s:%s: in ", source, currentLine(stack))
+		if p.LineDefined == 0 {
+			sb.WriteString("main chunk")
+		} else {
+			fmt.Fprintf(sb, "function <%s:%d>", source, p.LineDefined)
+		}
+	}
+	return sb.String()
+}
+
+// 栈帧正在执行的指令对应的行号，没有调试信息时为 "?"
+func currentLine(stack *luaStack) string {
+	return lineAt(stack.closure.proto, stack.pc-1)
+}
+
+func lineAt(proto *binchunk.Prototype, pc int) string {
+	if pc >= 0 && pc < len(proto.LineInfo) {
+		return fmt.Sprintf("%d", proto.LineInfo[pc])
+	}
+	return "?"
+}
@@ -0,0 +1,155 @@
+package state
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/vm"
+)
+
+func TestPCall(t *testing.T) {
+	ls := New()
+	ls.PushString("keep")
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.NewTable()
+		ls.PushString("boom")
+		ls.SetField(-2, "msg")
+		return ls.Error()
+	})
+	ls.PushInteger(1)
+
+	if status := ls.PCall(1, 0, 0); status != LUA_ERRRUN {
+		t.Errorf("pcall err, want LUA_ERRRUN, ret %v", status)
+	}
+	if ls.GetTop() != 2 || !ls.IsTable(-1) || ls.ToString(1) != "keep" {
+		t.Fatalf("pcall err, stack not restored, top %v", ls.GetTop())
+	}
+	if ls.GetField(-1, "msg"); ls.ToString(-1) != "boom" {
+		t.Errorf("error object err, ret %q", ls.ToString(-1))
+	}
+}
+
+func TestPCallMsgHandler(t *testing.T) {
+	ls := New()
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.PushString("handled: " + ls.ToString(1))
+		return 1
+	})
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.PushString("oops")
+		return ls.Error()
+	})
+	if status := ls.PCall(0, 0, 1); status != LUA_ERRRUN || ls.ToString(-1) != "handled: oops" {
+		t.Errorf("msgh err, status %v, ret %q", status, ls.ToString(-1))
+	}
+	ls.SetTop(0)
+
+	// 消息处理函数本身出错
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.Len(1)
+		return 1
+	})
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.PushBoolean(true)
+		return ls.Error()
+	})
+	if status := ls.PCall(0, 0, 1); status != LUA_ERRERR {
+		t.Errorf("msgh err, want LUA_ERRERR, ret %v", status)
+	}
+}
+
+// local t = nil; return t.x
+func TestRuntimeError(t *testing.T) {
+	proto := &binchunk.Prototype{
+		Source:       "@test.lua",
+		MaxStackSize: 2,
+		Constants:    []interface{}{"x"},
+		LineInfo:     []uint32{1, 2, 2},
+		Code: []uint32{
+			iABC(vm.OP_LOADNIL, 0, 0, 0),
+			iABC(vm.OP_GETTABLE, 1, 0, rk(0)),
+			iABC(vm.OP_RETURN, 1, 2, 0),
+		},
+	}
+
+	ls := New()
+	ls.PushLuaClosure(proto)
+	err := ls.ProtectedCall(0, 1)
+
+	var luaErr *LuaError
+	if !errors.As(err, &luaErr) {
+		t.Fatalf("want *LuaError, ret %v", err)
+	}
+	if msg := luaErr.Error(); msg != "test.lua:2: attempt to index a nil value" {
+		t.Errorf("error message err, ret %q", msg)
+	}
+	if !strings.Contains(luaErr.Traceback, "test.lua:2: in main chunk") {
+		t.Errorf("traceback err, ret %q", luaErr.Traceback)
+	}
+	if ls.GetTop() != 0 {
+		t.Errorf("stack not restored, top %v", ls.GetTop())
+	}
+
+	// 没有调试信息时位置显示为 "?"
+	ls.PushLuaClosure(binchunk.Strip(proto))
+	err = ls.ProtectedCall(0, 1)
+	if !errors.As(err, &luaErr) || luaErr.Error() != "?:?: attempt to index a nil value" {
+		t.Errorf("stripped error message err, ret %v", err)
+	}
+	if !strings.Contains(luaErr.Traceback, "?:?: in main chunk") {
+		t.Errorf("stripped traceback err, ret %q", luaErr.Traceback)
+	}
+}
+
+func TestMemoryError(t *testing.T) {
+	ls := New()
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.CreateTable(1<<40, 0)
+		return 1
+	})
+	if status := ls.PCall(0, 0, 0); status != LUA_ERRMEM || ls.ToString(-1) != "not enough memory" {
+		t.Errorf("memory err, want LUA_ERRMEM, ret %v %q", status, ls.ToString(-1))
+	}
+}
+
+// Go 的 runtime.Error 是 bug，pcall 不捕获
+func TestRuntimeErrorNotCaught(t *testing.T) {
+	ls := New()
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		var m map[string]int
+		m["x"] = 1
+		return 0
+	})
+	defer func() {
+		if _, ok := recover().(runtime.Error); !ok {
+			t.Errorf("pcall err, want runtime.Error to propagate")
+		}
+	}()
+	ls.PCall(0, 0, 0)
+	t.Errorf("pcall err, runtime.Error caught")
+}
+
+// 消息处理函数中的 runtime.Error 也不被当作 error in error handling
+func TestRuntimeErrorInMsgHandler(t *testing.T) {
+	ls := New()
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		var m map[string]int
+		m["x"] = 1
+		return 1
+	})
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.PushString("oops")
+		return ls.Error()
+	})
+	defer func() {
+		if _, ok := recover().(runtime.Error); !ok {
+			t.Errorf("msgh err, want runtime.Error to propagate")
+		}
+	}()
+	ls.PCall(0, 0, 1)
+	t.Errorf("msgh err, runtime.Error caught")
+}
@@ -0,0 +1,156 @@
+package state
+
+import (
+	"math"
+
+	"github.com/anccy/luago/go/number"
+)
+
+// luaTable 分为数组部分和哈希部分，正整数键 1..n 连续存放在 arr 中，
+// 其余的键存放在 _map 中。arr 的最后一个元素总是非 nil，所以 len(arr) 就是一个边界(border)
+type luaTable struct {
+	metatable *luaTable
+	arr       []luaValue
+	_map      map[luaValue]luaValue
+	keys      map[luaValue]luaValue // 供 next 使用，key -> 下一个 key
+	lastKey   luaValue
+	changed   bool // 键集合发生了变化，需要重建 keys
+}
+
+func newLuaTable(nArr, nRec int) *luaTable {
+	t := &luaTable{}
+	if nArr > 0 {
+		t.arr = make([]luaValue, 0, nArr)
+	}
+	if nRec > 0 {
+		t._map = make(map[luaValue]luaValue, nRec)
+	}
+	return t
+}
+
+func (self *luaTable) hasMetafield(fieldName string) bool {
+	return self.metatable != nil && self.metatable.get(fieldName) != nil
+}
+
+func (self *luaTable) len() int {
+	return len(self.arr)
+}
+
+func (self *luaTable) get(key luaValue) luaValue {
+	key = _floatToInteger(key)
+	if idx, ok := key.(int64); ok {
+		if idx >= 1 && idx <= int64(len(self.arr)) {
+			return self.arr[idx-1]
+		}
+	}
+	return self._map[key]
+}
+
+// 2.0 和 2 是同一个键
+func _floatToInteger(key luaValue) luaValue {
+	if f, ok := key.(float64); ok {
+		if i, ok := number.FloatToInteger(f); ok {
+			return i
+		}
+	}
+	return key
+}
+
+func (self *luaTable) put(key, val luaValue) {
+	if key == nil {
+		panic("table index is nil")
+	}
+	if f, ok := key.(float64); ok && math.IsNaN(f) {
+		panic("table index is NaN")
+	}
+
+	key = _floatToInteger(key)
+	if idx, ok := key.(int64); ok && idx >= 1 {
+		arrLen := int64(len(self.arr))
+		if idx <= arrLen {
+			self.arr[idx-1] = val
+			if idx == arrLen && val == nil {
+				self._shrinkArray()
+				self.changed = true
+			}
+			return
+		}
+		if idx == arrLen+1 {
+			delete(self._map, key)
+			if val != nil {
+				self.arr = append(self.arr, val)
+				self._expandArray()
+				self.changed = true
+			}
+			return
+		}
+	}
+
+	if val != nil {
+		if self._map == nil {
+			self._map = make(map[luaValue]luaValue, 8)
+		}
+		if _, found := self._map[key]; !found {
+			self.changed = true
+		}
+		self._map[key] = val
+	} else {
+		delete(self._map, key)
+	}
+}
+
+// 去掉数组末尾的 nil
+func (self *luaTable) _shrinkArray() {
+	for i := len(self.arr) - 1; i >= 0; i-- {
+		if self.arr[i] != nil {
+			break
+		}
+		self.arr = self.arr[0:i]
+	}
+}
+
+// 把哈希部分中紧接着数组的整数键挪到数组部分
+func (self *luaTable) _expandArray() {
+	for idx := int64(len(self.arr)) + 1; true; idx++ {
+		if val, found := self._map[idx]; found {
+			delete(self._map, idx)
+			self.arr = append(self.arr, val)
+		} else {
+			break
+		}
+	}
+}
+
+// nextKey 返回 key 的下一个键，key 为 nil 时返回第一个键，遍历结束返回 nil；
+// key 不在表中时返回 false
+func (self *luaTable) nextKey(key luaValue) (luaValue, bool) {
+	key = _floatToInteger(key)
+	if self.keys == nil || (key == nil && self.changed) {
+		self.initKeys()
+		self.changed = false
+	}
+
+	nextKey, found := self.keys[key]
+	if !found && key != nil && key != self.lastKey {
+		return nil, false
+	}
+	return nextKey, true
+}
+
+func (self *luaTable) initKeys() {
+	self.keys = make(map[luaValue]luaValue, len(self.arr)+len(self._map))
+	var key luaValue = nil
+	for i, v := range self.arr {
+		if v != nil {
+			self.keys[key] = int64(i + 1)
+			key = int64(i + 1)
+		}
+	}
+	for k, v := range self._map {
+		if v != nil {
+			self.keys[key] = k
+			key = k
+		}
+	}
+	self.lastKey = key
+}
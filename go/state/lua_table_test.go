@@ -0,0 +1,60 @@
+package state
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTableKeys(t *testing.T) {
+	tbl := newLuaTable(0, 0)
+	tbl.put(2.0, "b")
+	tbl.put(int64(1), "a")
+	if tbl.len() != 2 {
+		t.Errorf("len err, want 2, ret %v", tbl.len())
+	}
+	if v := tbl.get(int64(2)); v != "b" {
+		t.Errorf("float key err, want \"b\", ret %v", v)
+	}
+	if v := tbl.get(1.0); v != "a" {
+		t.Errorf("float key err, want \"a\", ret %v", v)
+	}
+
+	tbl.put(int64(2), nil)
+	if tbl.len() != 1 {
+		t.Errorf("len err, want 1, ret %v", tbl.len())
+	}
+}
+
+func TestTableBadKey(t *testing.T) {
+	for _, key := range []luaValue{nil, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("put with key %v should fail", key)
+				}
+			}()
+			newLuaTable(0, 0).put(key, true)
+		}()
+	}
+}
+
+func TestNext(t *testing.T) {
+	ls := New()
+	ls.CreateTable(2, 2)
+	ls.PushString("x")
+	ls.SetI(1, 1)
+	ls.PushString("y")
+	ls.SetI(1, 2)
+	ls.PushInteger(3)
+	ls.SetField(1, "z")
+
+	n := 0
+	ls.PushNil()
+	for ls.Next(1) {
+		n++
+		ls.Pop(1)
+	}
+	if n != 3 || ls.GetTop() != 1 {
+		t.Errorf("next err, want 3 pairs, ret %v", n)
+	}
+}
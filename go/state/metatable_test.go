@@ -0,0 +1,220 @@
+package state
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/anccy/luago/go/api"
+)
+
+// 把栈顶的函数设置为栈顶下面那个表的字段 name
+func setMetamethod(ls *LuaState, name string, f GoFunction) {
+	ls.PushGoFunction(f)
+	ls.SetField(-2, name)
+}
+
+func TestArithMetamethod(t *testing.T) {
+	ls := New()
+	ls.NewTable() // 1: obj
+	ls.NewTable() // mt
+	setMetamethod(ls, "__add", func(ls LuaStateI) int {
+		ls.PushString("added")
+		return 1
+	})
+	setMetamethod(ls, "__concat", func(ls LuaStateI) int {
+		ls.PushString("concatenated")
+		return 1
+	})
+	setMetamethod(ls, "__len", func(ls LuaStateI) int {
+		ls.PushInteger(42)
+		return 1
+	})
+	ls.SetMetatable(1)
+
+	ls.PushInteger(1)
+	ls.PushValue(1)
+	ls.Arith(LUA_OPADD)
+	if ret := ls.ToString(-1); ret != "added" {
+		t.Errorf("__add err, ret %q", ret)
+	}
+	ls.PushValue(1)
+	ls.Concat(2)
+	if ret := ls.ToString(-1); ret != "concatenated" {
+		t.Errorf("__concat err, ret %q", ret)
+	}
+	ls.Len(1)
+	if ret := ls.ToInteger(-1); ret != 42 {
+		t.Errorf("__len err, ret %v", ret)
+	}
+}
+
+func TestIndexMetamethod(t *testing.T) {
+	ls := New()
+	ls.NewTable() // 1: base
+	ls.PushString("from base")
+	ls.SetField(1, "x")
+
+	ls.NewTable() // 2: obj，__index 为 base
+	ls.NewTable()
+	ls.PushValue(1)
+	ls.SetField(-2, "__index")
+	ls.PushValue(1)
+	ls.SetField(-2, "__newindex")
+	ls.SetMetatable(2)
+
+	if ls.GetField(2, "x"); ls.ToString(-1) != "from base" {
+		t.Errorf("__index err, ret %q", ls.ToString(-1))
+	}
+	ls.Pop(1)
+	if ls.RawGetI(2, 1); !ls.IsNil(-1) {
+		t.Error("rawget should ignore __index")
+	}
+	ls.Pop(1)
+
+	ls.PushInteger(7)
+	ls.SetField(2, "y")
+	ls.PushString("y")
+	if ls.RawGet(2); !ls.IsNil(-1) {
+		t.Error("__newindex should write to base")
+	}
+	ls.Pop(1)
+	if ls.GetField(1, "y"); ls.ToInteger(-1) != 7 {
+		t.Errorf("__newindex err, ret %v", ls.ToInteger(-1))
+	}
+	ls.Pop(1)
+
+	// __index 指向自己，形成循环
+	ls.NewTable()
+	ls.NewTable()
+	ls.PushValue(-2)
+	ls.SetField(-2, "__index")
+	ls.SetMetatable(-2)
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.GetField(1, "z")
+		return 1
+	})
+	ls.Insert(-2)
+	if err := ls.ProtectedCall(1, 1); err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("__index loop err, ret %v", err)
+	}
+}
+
+func TestCompareMetamethod(t *testing.T) {
+	ls := New()
+	ls.NewTable() // mt
+	setMetamethod(ls, "__lt", func(ls LuaStateI) int {
+		ls.PushBoolean(ls.RawLen(1) < ls.RawLen(2))
+		return 1
+	})
+	setMetamethod(ls, "__eq", func(ls LuaStateI) int {
+		ls.PushBoolean(true)
+		return 1
+	})
+
+	for i := 0; i < 2; i++ {
+		ls.CreateTable(i, 0)
+		for j := 1; j <= i; j++ {
+			ls.PushBoolean(true)
+			ls.SetI(-2, int64(j))
+		}
+		ls.PushValue(1)
+		ls.SetMetatable(-2)
+	}
+
+	if !ls.Compare(2, 3, LUA_OPLT) || ls.Compare(3, 2, LUA_OPLT) {
+		t.Error("__lt err")
+	}
+	// 没有 __le 时使用 not __lt(b, a)
+	if !ls.Compare(2, 3, LUA_OPLE) || ls.Compare(3, 2, LUA_OPLE) {
+		t.Error("__le err")
+	}
+	if !ls.Compare(2, 3, LUA_OPEQ) || ls.RawEqual(2, 3) {
+		t.Error("__eq err")
+	}
+}
+
+func TestCallAndToString(t *testing.T) {
+	ls := New()
+	ls.NewTable()
+	ls.NewTable()
+	setMetamethod(ls, "__call", func(ls LuaStateI) int {
+		ls.PushBoolean(ls.IsTable(1))
+		ls.PushValue(2)
+		return 2
+	})
+	ls.PushString("MyType")
+	ls.SetField(-2, "__name")
+	ls.SetMetatable(-2)
+
+	ls.PushValue(1)
+	ls.PushString("arg")
+	ls.Call(1, 2)
+	if !ls.ToBoolean(-2) || ls.ToString(-1) != "arg" {
+		t.Error("__call err")
+	}
+	ls.Pop(2)
+
+	if ret := ls.ToString2(1); !strings.HasPrefix(ret, "MyType: 0x") {
+		t.Errorf("__name err, ret %q", ret)
+	}
+	ls.Pop(1)
+
+	ls.GetMetatable(1)
+	setMetamethod(ls, "__tostring", func(ls LuaStateI) int {
+		ls.PushString("my object")
+		return 1
+	})
+	if ret := ls.ToString2(1); ret != "my object" {
+		t.Errorf("__tostring err, ret %q", ret)
+	}
+}
+
+func TestTypeMetatable(t *testing.T) {
+	ls := New()
+	ls.PushString("abc")
+	ls.NewTable()
+	ls.NewTable()
+	ls.PushString("shared")
+	ls.SetField(-2, "x")
+	ls.SetField(-2, "__index")
+	ls.SetMetatable(1)
+
+	// 所有字符串共享同一个元表
+	ls.PushString("other")
+	if ls.GetField(-1, "x"); ls.ToString(-1) != "shared" {
+		t.Errorf("string metatable err, ret %q", ls.ToString(-1))
+	}
+}
+
+// 运算出错时的消息使用元表中的 __name 作为类型名
+func TestNameInErrors(t *testing.T) {
+	tests := []struct {
+		op   func(ls LuaStateI)
+		want string
+	}{
+		{func(ls LuaStateI) { ls.PushInteger(1); ls.Arith(LUA_OPADD) }, "attempt to perform arithmetic on a MyType value"},
+		{func(ls LuaStateI) { ls.PushInteger(1); ls.Arith(LUA_OPBAND) }, "attempt to perform bitwise operation on a MyType value"},
+		{func(ls LuaStateI) { ls.PushValue(1); ls.Compare(1, 2, LUA_OPLT) }, "attempt to compare two MyType values"},
+		{func(ls LuaStateI) { ls.PushInteger(1); ls.Compare(1, 2, LUA_OPLE) }, "attempt to compare MyType with number"},
+		{func(ls LuaStateI) { ls.PushString("a"); ls.Concat(2) }, "attempt to concatenate a MyType value"},
+		{func(ls LuaStateI) { ls.Len(1) }, "attempt to get length of a MyType value"},
+		{func(ls LuaStateI) { ls.GetField(1, "x") }, "attempt to index a MyType value"},
+		{func(ls LuaStateI) { ls.Call(0, 0) }, "attempt to call a MyType value"},
+	}
+	for _, test := range tests {
+		op := test.op
+		ls := New()
+		ls.PushGoFunction(func(ls LuaStateI) int {
+			ls.NewUserdata(nil)
+			ls.NewTable()
+			ls.PushString("MyType")
+			ls.SetField(-2, "__name")
+			ls.SetMetatable(-2)
+			op(ls)
+			return 0
+		})
+		if status := ls.PCall(0, 0, 0); status != LUA_ERRRUN || ls.ToString(-1) != test.want {
+			t.Errorf("error message err, want %q, ret %q", test.want, ls.ToString(-1))
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package state
+
+import (
+	"testing"
+
+	. "github.com/anccy/luago/go/api"
+)
+
+func TestRegistry(t *testing.T) {
+	ls := New()
+	if tp := ls.Type(LUA_REGISTRYINDEX); tp != LUA_TTABLE {
+		t.Fatalf("registry type err, ret %v", tp)
+	}
+	ls.RawGetI(LUA_REGISTRYINDEX, LUA_RIDX_MAINTHREAD)
+	if ls.ToThread(-1) != ls {
+		t.Errorf("LUA_RIDX_MAINTHREAD err")
+	}
+	ls.RawGetI(LUA_REGISTRYINDEX, LUA_RIDX_GLOBALS)
+	ls.PushGlobalTable()
+	if !ls.RawEqual(-1, -2) {
+		t.Errorf("LUA_RIDX_GLOBALS err")
+	}
+	ls.SetTop(0)
+
+	ls.PushInteger(42)
+	ls.SetGlobal("answer")
+	if tp := ls.GetGlobal("answer"); tp != LUA_TNUMBER || ls.ToInteger(-1) != 42 {
+		t.Errorf("GetGlobal err, ret %v", ls.ToString2(-1))
+	}
+	if tp := ls.GetGlobal("missing"); tp != LUA_TNIL {
+		t.Errorf("GetGlobal err, ret %v", tp)
+	}
+}
+
+func TestGoClosureUpvalue(t *testing.T) {
+	ls := New()
+	ls.PushInteger(0)
+	ls.PushGoClosure(func(ls LuaStateI) int {
+		n := ls.ToInteger(LuaUpvalueIndex(1)) + 1
+		ls.PushInteger(n)
+		ls.Copy(-1, LuaUpvalueIndex(1))
+		return 1
+	}, 1)
+	ls.SetGlobal("counter")
+
+	for i := int64(1); i <= 3; i++ {
+		ls.GetGlobal("counter")
+		ls.Call(0, 1)
+		if ret := ls.ToInteger(-1); ret != i {
+			t.Errorf("counter err, want %d, ret %d", i, ret)
+		}
+		ls.Pop(1)
+	}
+
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.PushBoolean(ls.IsNone(LuaUpvalueIndex(1)))
+		return 1
+	})
+	ls.Call(0, 1)
+	if !ls.ToBoolean(-1) {
+		t.Errorf("upvalue index of function without upvalues should be none")
+	}
+}
+
+func TestRef(t *testing.T) {
+	ls := New()
+	ls.PushString("a")
+	r1 := ls.Ref(LUA_REGISTRYINDEX)
+	ls.PushString("b")
+	r2 := ls.Ref(LUA_REGISTRYINDEX)
+	if r1 == r2 || r1 <= int(LUA_RIDX_LAST) || r2 <= int(LUA_RIDX_LAST) {
+		t.Fatalf("Ref err, ret %d %d", r1, r2)
+	}
+	ls.RawGetI(LUA_REGISTRYINDEX, int64(r1))
+	if ret := ls.ToString(-1); ret != "a" {
+		t.Errorf("Ref value err, ret %q", ret)
+	}
+
+	ls.Unref(LUA_REGISTRYINDEX, r1)
+	ls.PushString("c")
+	if r3 := ls.Ref(LUA_REGISTRYINDEX); r3 != r1 {
+		t.Errorf("Unref should free the reference, want %d, ret %d", r1, r3)
+	}
+	ls.PushNil()
+	if ref := ls.Ref(LUA_REGISTRYINDEX); ref != LUA_REFNIL {
+		t.Errorf("Ref nil err, ret %d", ref)
+	}
+	if top := ls.GetTop(); top != 1 {
+		t.Errorf("stack top err, ret %d", top)
+	}
+}
@@ -1,27 +1,55 @@
 package state
 
+import . "github.com/anccy/luago/go/api"
+
 type luaStack struct {
 	slots []luaValue
 	top   int
+	state *LuaState
+	/* call info */
+	prev    *luaStack        // 调用者的栈帧
+	closure *closure         // 正在执行的闭包
+	varargs []luaValue       // 变长参数
+	openuvs map[int]*upvalue // 仍然指向本栈帧寄存器的 upvalue，key 为 slots 下标
+	pc      int
 }
 
-func NewLuaStack(size int) *luaStack {
+func NewLuaStack(size int, state *LuaState) *luaStack {
 	return &luaStack{
 		slots: make([]luaValue, size),
 		top:   0,
+		state: state,
 	}
 }
 
-func (ls *luaStack) check(n int) {
-	space := len(ls.slots) - ls.top
-	for i := space; i < n; i++ {
-		ls.slots = append(ls.slots, nil)
+// check 确保栈上至少有 n 个空闲槽位，不够时按倍数扩容；
+// 扩容后线程的槽位总数会超过上限时不扩容，返回 false
+func (ls *luaStack) check(n int) bool {
+	free := len(ls.slots) - ls.top
+	if free >= n {
+		return true
+	}
+
+	state := ls.state
+	need := n - free
+	if state.nSlots+need > state.maxStack {
+		return false
+	}
+	grow := len(ls.slots)
+	if grow < need {
+		grow = need
 	}
+	if state.nSlots+grow > state.maxStack {
+		grow = state.maxStack - state.nSlots
+	}
+	ls.slots = append(ls.slots, state.allocSlots(grow)...)
+	state.nSlots += grow
+	return true
 }
 
 func (ls *luaStack) push(val luaValue) {
-	if ls.top >= len(ls.slots) {
-		panic("stack overflow")
+	if ls.top >= len(ls.slots) && !ls.check(1) {
+		panic(ls.state.runtimeError("stack overflow"))
 	}
 
 	ls.slots[ls.top] = val
@@ -30,7 +58,7 @@ func (ls *luaStack) push(val luaValue) {
 
 func (ls *luaStack) pop() luaValue {
 	if ls.top <= 0 {
-		panic("stack lowflow")
+		panic(ls.state.runtimeError("stack underflow"))
 	}
 	ls.top--
 	v := ls.slots[ls.top]
@@ -38,19 +66,69 @@ func (ls *luaStack) pop() luaValue {
 	return v
 }
 
+// pushN 压入 vals 中的前 n 个值，不够的用 nil 补齐；n < 0 时压入全部
+func (ls *luaStack) pushN(vals []luaValue, n int) {
+	nVals := len(vals)
+	if n < 0 {
+		n = nVals
+	}
+	for i := 0; i < n; i++ {
+		if i < nVals {
+			ls.push(vals[i])
+		} else {
+			ls.push(nil)
+		}
+	}
+}
+
+// popN 弹出 n 个值，按入栈顺序返回
+func (ls *luaStack) popN(n int) []luaValue {
+	vals := make([]luaValue, n)
+	for i := n - 1; i >= 0; i-- {
+		vals[i] = ls.pop()
+	}
+	return vals
+}
+
+// 伪索引原样返回
 func (ls *luaStack) absIndex(idx int) int {
-	if idx >= 0 {
+	if idx >= 0 || idx <= LUA_REGISTRYINDEX {
 		return idx
 	}
 	return ls.top + idx + 1
 }
 
+// 返回 upvalue 伪索引对应的 upvalue，不存在时返回 nil
+func (ls *luaStack) upvalueOf(idx int) *upvalue {
+	uvIdx := LUA_REGISTRYINDEX - idx - 1
+	if c := ls.closure; c != nil && uvIdx < len(c.upvals) {
+		return c.upvals[uvIdx]
+	}
+	return nil
+}
+
 func (ls *luaStack) isValid(idx int) bool {
+	if idx == LUA_REGISTRYINDEX {
+		return true
+	}
+	if idx < LUA_REGISTRYINDEX {
+		return ls.upvalueOf(idx) != nil
+	}
 	i := ls.absIndex(idx)
 	return i >= 1 && i <= ls.top
 }
 
 func (ls *luaStack) get(idx int) luaValue {
+	if idx == LUA_REGISTRYINDEX {
+		return ls.state.registry
+	}
+	if idx < LUA_REGISTRYINDEX {
+		if uv := ls.upvalueOf(idx); uv != nil {
+			return uv.get()
+		}
+		return nil
+	}
+
 	i := ls.absIndex(idx)
 	if i <= 0 || i > ls.top {
 		return nil
@@ -59,9 +137,20 @@ func (ls *luaStack) get(idx int) luaValue {
 }
 
 func (ls *luaStack) set(idx int, val luaValue) {
+	if idx == LUA_REGISTRYINDEX {
+		panic(ls.state.runtimeError("cannot replace the registry"))
+	}
+	if idx < LUA_REGISTRYINDEX {
+		if uv := ls.upvalueOf(idx); uv != nil {
+			uv.set(val)
+			return
+		}
+		panic(ls.state.runtimeError("invalid upvalue index %d", LUA_REGISTRYINDEX-idx))
+	}
+
 	i := ls.absIndex(idx)
 	if i <= 0 || i > ls.top {
-		panic("invalid index")
+		panic(ls.state.runtimeError("invalid index %d", idx))
 	}
 	ls.slots[i-1] = val
 }
@@ -74,3 +163,13 @@ func (ls *luaStack) reverse(i, j int) {
 		j--
 	}
 }
+
+// closeUpvalues 关闭所有指向 slots[idx] 及其之上的寄存器的 upvalue
+func (ls *luaStack) closeUpvalues(idx int) {
+	for i, openuv := range ls.openuvs {
+		if i >= idx {
+			openuv.close()
+			delete(ls.openuvs, i)
+		}
+	}
+}
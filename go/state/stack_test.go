@@ -0,0 +1,101 @@
+package state
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
+	"github.com/anccy/luago/go/vm"
+)
+
+func TestStackGrow(t *testing.T) {
+	ls := New()
+	for i := 1; i <= 1000; i++ {
+		ls.PushInteger(int64(i))
+	}
+	if top := ls.GetTop(); top != 1000 {
+		t.Fatalf("GetTop err, ret %d", top)
+	}
+	if ret := ls.ToInteger(1); ret != 1 {
+		t.Errorf("ToInteger err, ret %d", ret)
+	}
+}
+
+func TestCheckStack(t *testing.T) {
+	ls := New(WithStackSize(10), WithMaxStack(100))
+	if !ls.CheckStack(100) {
+		t.Errorf("CheckStack(100) err, want true")
+	}
+	if ls.CheckStack(101) {
+		t.Errorf("CheckStack(101) err, want false")
+	}
+
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		for {
+			ls.PushNil()
+		}
+	})
+	err := ls.ProtectedCall(0, 0)
+	if err == nil || err.Error() != "stack overflow" {
+		t.Errorf("want stack overflow, ret %v", err)
+	}
+	if top := ls.GetTop(); top != 0 {
+		t.Errorf("stack top err, ret %d", top)
+	}
+}
+
+// local function f() return 1 + f() end
+func TestStackOverflow(t *testing.T) {
+	proto := &binchunk.Prototype{
+		Source:       "@test.lua",
+		LineDefined:  1,
+		MaxStackSize: 2,
+		Constants:    []interface{}{"f", int64(1)},
+		Upvalues:     []binchunk.Upvalue{{Instack: 1, Idx: 0}},
+		Code: []uint32{
+			iABC(vm.OP_GETTABUP, 0, 0, rk(0)),
+			iABC(vm.OP_CALL, 0, 1, 2),
+			iABC(vm.OP_ADD, 0, rk(1), 0),
+			iABC(vm.OP_RETURN, 0, 2, 0),
+		},
+		LineInfo: []uint32{1, 1, 1, 1},
+	}
+
+	ls := New()
+	ls.PushLuaClosure(proto)
+	ls.SetGlobal("f")
+	ls.GetGlobal("f")
+	err := ls.ProtectedCall(0, 1)
+	if err == nil || !strings.HasSuffix(err.Error(), "stack overflow") {
+		t.Fatalf("want stack overflow, ret %v", err)
+	}
+	if top := ls.GetTop(); top != 0 {
+		t.Errorf("stack top err, ret %d", top)
+	}
+
+	// 栈展开后可以继续正常调用
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.PushString("ok")
+		return 1
+	})
+	ls.Call(0, 1)
+	if ret := ls.ToString(-1); ret != "ok" {
+		t.Errorf("call after overflow err, ret %q", ret)
+	}
+}
+
+// Lua 函数的栈帧只按 MaxStackSize 分配，不额外保留 LUA_MINSTACK，递归深度和 Lua 相当
+func TestCallDepth(t *testing.T) {
+	ls := New()
+	err := ls.DoString(`
+		local function f(n)
+			if n == 0 then return 0 end
+			return 1 + f(n - 1)
+		end
+		return f(100000)
+	`)
+	if err != nil || ls.ToInteger(-1) != 100000 {
+		t.Errorf("call depth err, want 100000, ret %v", err)
+	}
+}
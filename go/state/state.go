@@ -1,11 +1,89 @@
 package state
 
+import (
+	"errors"
+
+	. "github.com/anccy/luago/go/api"
+)
+
 type LuaState struct {
-	stack *luaStack
+	registry *luaTable             // 所有线程共享的注册表
+	stack    *luaStack             // 当前栈帧，通过 prev 串成调用栈
+	mts      map[LuaType]*luaTable // 除 table 和 userdata 外，同一类型的值共享一个元表
+	nSlots   int                   // 调用栈上所有栈帧的槽位总数
+	maxStack int                   // nSlots 的上限
+	/* coroutine */
+	coStatus  int       // LUA_OK, LUA_YIELD 或者错误码
+	coCaller  *LuaState // 最近一次恢复本协程的线程，主线程为 nil
+	coChan    chan int  // 协程之间交换控制权
+	coStarted bool      // 协程的 goroutine 已经启动
+	coDone    bool      // 协程的 goroutine 已经结束，协程是死亡的
+	coClosing bool      // 正在被 CloseThread 关闭，Yield 被唤醒后展开调用栈
+	coError   luaValue  // 协程出错结束时的错误对象
+}
+
+// 关闭挂起的协程时，Yield 用它 panic 以展开协程的调用栈，协程里的 pcall 不捕获它
+var errThreadClosed = errors.New("coroutine closed")
+
+// 调用消息处理函数时额外允许使用的槽位数，用于处理 stack overflow 错误
+const errorStackExtra = 200
+
+type options struct {
+	stackSize int
+	maxStack  int
 }
 
-func New() *LuaState {
-	return &LuaState{
-		stack: NewLuaStack(20),
+// Option 用于配置 New 创建的 LuaState
+type Option func(*options)
+
+// WithStackSize 设置主线程栈的初始大小，默认为 LUA_MINSTACK
+func WithStackSize(n int) Option {
+	return func(o *options) {
+		o.stackSize = n
 	}
 }
+
+// WithMaxStack 设置每个线程调用栈上槽位总数的上限，默认为 LUAI_MAXSTACK，
+// 超出上限时抛出 stack overflow 错误
+func WithMaxStack(n int) Option {
+	return func(o *options) {
+		o.maxStack = n
+	}
+}
+
+func New(opts ...Option) *LuaState {
+	o := options{stackSize: LUA_MINSTACK, maxStack: LUAI_MAXSTACK}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.stackSize > o.maxStack {
+		o.stackSize = o.maxStack
+	}
+
+	ls := &LuaState{
+		registry: newLuaTable(0, 0),
+		mts:      map[LuaType]*luaTable{},
+		maxStack: o.maxStack,
+	}
+	ls.registry.put(LUA_RIDX_MAINTHREAD, ls)
+	ls.registry.put(LUA_RIDX_GLOBALS, newLuaTable(0, 0))
+	ls.stack = NewLuaStack(o.stackSize, ls)
+	ls.nSlots = o.stackSize
+	return ls
+}
+
+func (self *LuaState) pushLuaStack(stack *luaStack) {
+	if self.nSlots+len(stack.slots) > self.maxStack {
+		panic(self.runtimeError("stack overflow"))
+	}
+	self.nSlots += len(stack.slots)
+	stack.prev = self.stack
+	self.stack = stack
+}
+
+func (self *LuaState) popLuaStack() {
+	stack := self.stack
+	self.stack = stack.prev
+	stack.prev = nil
+	self.nSlots -= len(stack.slots)
+}
@@ -0,0 +1,27 @@
+package state
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// userdata 包装任意 Go 值，每个 userdata 有自己的元表和关联值
+type userdata struct {
+	value     interface{}
+	metatable *luaTable
+	userValue luaValue
+}
+
+// lightUserdata 只是一个可比较的 Go 值（通常是指针或句柄），按值相等，共享类型元表
+type lightUserdata struct {
+	p interface{}
+}
+
+func pointerString(p interface{}) string {
+	switch reflect.ValueOf(p).Kind() {
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return fmt.Sprintf("%p", p)
+	default:
+		return fmt.Sprintf("%v", p)
+	}
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"testing"
+
+	. "github.com/anccy/luago/go/api"
+)
+
+type point struct{ x, y int }
+
+func TestUdata(t *testing.T) {
+	ls := New()
+	if !ls.NewMetatable("Point") {
+		t.Fatalf("NewMetatable err, want true")
+	}
+	if ls.NewMetatable("Point") {
+		t.Errorf("NewMetatable err, want false for existing name")
+	}
+	ls.SetTop(0)
+
+	p := &point{1, 2}
+	ls.NewUserdata(p)
+	ls.SetMetatable2("Point")
+	if tp := ls.Type(1); tp != LUA_TUSERDATA {
+		t.Errorf("Type err, ret %v", tp)
+	}
+	if ret := ls.CheckUdata(1, "Point"); ret != p {
+		t.Errorf("CheckUdata err, ret %v", ret)
+	}
+	if ret := ls.TestUdata(1, "Other"); ret != nil {
+		t.Errorf("TestUdata err, ret %v", ret)
+	}
+	if ret := ls.ToString2(1); ret[:7] != "Point: " {
+		t.Errorf("ToString2 err, ret %q", ret)
+	}
+
+	ls.PushString("user value")
+	ls.SetUserValue(1)
+	if tp := ls.GetUserValue(1); tp != LUA_TSTRING || ls.ToString(-1) != "user value" {
+		t.Errorf("GetUserValue err, ret %v", ls.ToString(-1))
+	}
+}
+
+func TestLightUserdata(t *testing.T) {
+	ls := New()
+	p := &point{}
+	ls.PushLightUserdata(p)
+	ls.PushLightUserdata(p)
+	if tp := ls.Type(1); tp != LUA_TLIGHTUSERDATA {
+		t.Errorf("Type err, ret %v", tp)
+	}
+	if !ls.RawEqual(1, 2) {
+		t.Errorf("light userdata with same pointer should be equal")
+	}
+	if ret := ls.ToUserdata(1); ret != p {
+		t.Errorf("ToUserdata err, ret %v", ret)
+	}
+
+	ls.NewTable()
+	ls.PushValue(1)
+	ls.PushInteger(42)
+	ls.SetTable(-3)
+	ls.PushLightUserdata(p)
+	ls.GetTable(-2)
+	if ret := ls.ToInteger(-1); ret != 42 {
+		t.Errorf("light userdata as key err, ret %v", ret)
+	}
+}
+
+func TestCheckUdataError(t *testing.T) {
+	ls := New()
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		ls.CheckUdata(1, "Point")
+		return 0
+	})
+	ls.PushInteger(1)
+	err := ls.ProtectedCall(1, 0)
+	if err == nil {
+		t.Fatalf("want error")
+	}
+	if msg := err.Error(); msg != "bad argument #1 (Point expected, got number)" {
+		t.Errorf("error message err, ret %q", msg)
+	}
+}
@@ -1,6 +1,8 @@
 package state
 
 import (
+	"fmt"
+
 	. "github.com/anccy/luago/go/api"
 	"github.com/anccy/luago/go/number"
 )
@@ -17,11 +19,25 @@ func typeOf(val luaValue) LuaType {
 		return LUA_TNUMBER
 	case string:
 		return LUA_TSTRING
+	case *luaTable:
+		return LUA_TTABLE
+	case *closure:
+		return LUA_TFUNCTION
+	case *LuaState:
+		return LUA_TTHREAD
+	case *userdata:
+		return LUA_TUSERDATA
+	case lightUserdata:
+		return LUA_TLIGHTUSERDATA
 	default:
-		panic("todo!")
+		panic(fmt.Sprintf("invalid lua value: %T", val))
 	}
 }
 
+func typeNameOf(val luaValue) string {
+	return typeName(typeOf(val))
+}
+
 func convertToBoolean(val luaValue) bool {
 	switch x := val.(type) {
 	case nil:
@@ -40,8 +56,112 @@ func convertToFloat(val luaValue) (float64, bool) {
 	case float64:
 		return x, true
 	case string:
-		return number.ParseFloat(x)
+		if n, ok := stringToNumber(x); ok {
+			return convertToFloat(n)
+		}
+		return 0, false
+	default:
+		return 0, false
+	}
+}
+
+func convertToInteger(val luaValue) (int64, bool) {
+	switch x := val.(type) {
+	case int64:
+		return x, true
+	case float64:
+		return number.FloatToInteger(x)
+	case string:
+		return _stringToInteger(x)
 	default:
 		return 0, false
 	}
 }
+
+func _stringToInteger(s string) (int64, bool) {
+	if n, ok := stringToNumber(s); ok {
+		return convertToInteger(n)
+	}
+	return 0, false
+}
+
+// lua: luaO_tostring
+func numberToString(val luaValue) string {
+	if i, ok := val.(int64); ok {
+		return number.FormatInteger(i)
+	}
+	return number.FormatFloat(val.(float64))
+}
+
+// lua: luaO_str2num
+// 字符串表示的是整数时返回 int64，否则返回 float64
+func stringToNumber(s string) (luaValue, bool) {
+	if i, ok := number.ParseInteger(s); ok {
+		return i, true
+	}
+	if f, ok := number.ParseFloat(s); ok {
+		return f, true
+	}
+	return nil, false
+}
+
+func getMetatable(val luaValue, ls *LuaState) *luaTable {
+	switch x := val.(type) {
+	case *luaTable:
+		return x.metatable
+	case *userdata:
+		return x.metatable
+	default:
+		return ls.mts[typeOf(val)]
+	}
+}
+
+// table 和 userdata 各自拥有元表，其他类型的值按类型共享元表
+func setMetatable(val luaValue, mt *luaTable, ls *LuaState) {
+	switch x := val.(type) {
+	case *luaTable:
+		x.metatable = mt
+		return
+	case *userdata:
+		x.metatable = mt
+		return
+	}
+	if mt == nil {
+		delete(ls.mts, typeOf(val))
+	} else {
+		ls.mts[typeOf(val)] = mt
+	}
+}
+
+func getMetafield(val luaValue, fieldName string, ls *LuaState) luaValue {
+	if mt := getMetatable(val, ls); mt != nil {
+		return mt.get(fieldName)
+	}
+	return nil
+}
+
+// lua: luaT_objtypename
+// 错误消息中使用的类型名，元表中的 __name 字段是字符串时用它代替类型名
+func objTypeName(val luaValue, ls *LuaState) string {
+	if name, ok := getMetafield(val, "__name", ls).(string); ok {
+		return name
+	}
+	return typeNameOf(val)
+}
+
+// callMetamethod 依次在 a 和 b 的元表中查找元方法，找到后以 (a, b) 调用并返回第一个返回值
+func callMetamethod(a, b luaValue, mmName string, ls *LuaState) (luaValue, bool) {
+	var mm luaValue
+	if mm = getMetafield(a, mmName, ls); mm == nil {
+		if mm = getMetafield(b, mmName, ls); mm == nil {
+			return nil, false
+		}
+	}
+
+	ls.stack.check(4)
+	ls.stack.push(mm)
+	ls.stack.push(a)
+	ls.stack.push(b)
+	ls.Call(2, 1)
+	return ls.stack.pop(), true
+}
@@ -0,0 +1,13 @@
+package stdlib
+
+import . "github.com/anccy/luago/go/api"
+
+// lua: luaL_newlib
+// 创建一个新表，把 funcs 注册到表中，表留在栈顶
+func newLib(ls LuaStateI, funcs map[string]GoFunction) {
+	ls.CreateTable(0, len(funcs))
+	for name, f := range funcs {
+		ls.PushGoFunction(f)
+		ls.SetField(-2, name)
+	}
+}
@@ -0,0 +1,161 @@
+package stdlib
+
+import . "github.com/anccy/luago/go/api"
+
+var coFuncs = map[string]GoFunction{
+	"create":      coCreate,
+	"resume":      coResume,
+	"yield":       coYield,
+	"status":      coStatus,
+	"isyieldable": coYieldable,
+	"running":     coRunning,
+	"wrap":        coWrap,
+	"close":       coClose,
+}
+
+// lua: luaopen_coroutine
+// 把 coroutine 库压入栈顶
+func OpenCoroutineLib(ls LuaStateI) int {
+	newLib(ls, coFuncs)
+	return 1
+}
+
+// coroutine.create (f)
+func coCreate(ls LuaStateI) int {
+	ls.ArgCheck(ls.Type(1) == LUA_TFUNCTION, 1, "function expected")
+	co := ls.NewThread()
+	ls.PushValue(1) // move function to top
+	ls.XMove(co, 1) // move function from ls to co
+	return 1
+}
+
+// coroutine.resume (co [, val1, ···])
+func coResume(ls LuaStateI) int {
+	co := getCo(ls)
+	if r := auxResume(ls, co, ls.GetTop()-1); r < 0 {
+		ls.PushBoolean(false)
+		ls.Insert(-2)
+		return 2 // return false + error message
+	} else {
+		ls.PushBoolean(true)
+		ls.Insert(-(r + 1))
+		return r + 1 // return true + 'resume' returns
+	}
+}
+
+// 返回值个数，出错时返回 -1，错误对象在栈顶
+func auxResume(ls, co LuaStateI, narg int) int {
+	if !ls.CheckStack(narg) {
+		ls.PushString("too many arguments to resume")
+		return -1
+	}
+	if co.Status() == LUA_OK && co.GetTop() == 0 {
+		ls.PushString("cannot resume dead coroutine")
+		return -1
+	}
+
+	ls.XMove(co, narg)
+	status := co.Resume(ls, narg)
+	if status == LUA_OK || status == LUA_YIELD {
+		nres := co.GetTop()
+		if !ls.CheckStack(nres + 1) {
+			co.Pop(nres) // remove results anyway
+			ls.PushString("too many results to resume")
+			return -1
+		}
+		co.XMove(ls, nres) // move yielded values
+		return nres
+	}
+	co.XMove(ls, 1) // move error message
+	return -1
+}
+
+// coroutine.yield (···)
+func coYield(ls LuaStateI) int {
+	return ls.Yield(ls.GetTop())
+}
+
+// coroutine.status (co)
+func coStatus(ls LuaStateI) int {
+	co := getCo(ls)
+	ls.PushString(auxStatus(ls, co))
+	return 1
+}
+
+func auxStatus(ls, co LuaStateI) string {
+	if ls == co {
+		return "running"
+	}
+	switch co.Status() {
+	case LUA_YIELD:
+		return "suspended"
+	case LUA_OK:
+		if co.GetStack() { // does it have frames?
+			return "normal" // it is running
+		} else if co.GetTop() == 0 {
+			return "dead"
+		} else {
+			return "suspended" // initial state
+		}
+	default: // some error occurred
+		return "dead"
+	}
+}
+
+// coroutine.close (co)
+// lua 5.4: luaB_close
+// 关闭挂起或者已经结束的协程，结束它的 goroutine。正常关闭时返回 true，协程因为出错而结束时返回 false 和错误对象
+func coClose(ls LuaStateI) int {
+	co := getCo(ls)
+	switch status := auxStatus(ls, co); status {
+	case "dead", "suspended":
+		if co.CloseThread(ls) == LUA_OK {
+			ls.PushBoolean(true)
+			return 1
+		}
+		ls.PushBoolean(false)
+		co.XMove(ls, 1) // move error message
+		return 2
+	default:
+		ls.PushString("cannot close a " + status + " coroutine")
+		return ls.Error()
+	}
+}
+
+// coroutine.isyieldable ()
+func coYieldable(ls LuaStateI) int {
+	ls.PushBoolean(ls.IsYieldable())
+	return 1
+}
+
+// coroutine.running ()
+func coRunning(ls LuaStateI) int {
+	isMain := ls.PushThread()
+	ls.PushBoolean(isMain)
+	return 2
+}
+
+// coroutine.wrap (f)
+func coWrap(ls LuaStateI) int {
+	coCreate(ls)
+	co := ls.ToThread(-1)
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		r := auxResume(ls, co, ls.GetTop())
+		if r < 0 {
+			if ls.Type(-1) == LUA_TSTRING { // error object is a string?
+				ls.Where(1) // get extra info
+				ls.Insert(-2)
+				ls.Concat(2)
+			}
+			return ls.Error() // propagate error
+		}
+		return r
+	})
+	return 1
+}
+
+func getCo(ls LuaStateI) LuaStateI {
+	co := ls.ToThread(1)
+	ls.ArgCheck(co != nil, 1, "coroutine expected")
+	return co
+}
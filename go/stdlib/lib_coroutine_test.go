@@ -0,0 +1,107 @@
+package stdlib
+
+import (
+	"testing"
+
+	. "github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/state"
+)
+
+func TestCoroutineStatus(t *testing.T) {
+	ls := state.New()
+	OpenCoroutineLib(ls)
+	ls.GetField(1, "status")
+	status := func() string {
+		ls.PushValue(2)
+		ls.PushValue(3)
+		ls.Call(1, 1)
+		defer ls.Pop(1)
+		return ls.ToString(-1)
+	}
+
+	// 3: co
+	ls.GetField(1, "create")
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		return ls.Yield(0)
+	})
+	ls.Call(1, 1)
+
+	if s := status(); s != "suspended" {
+		t.Errorf("status err, want suspended, ret %q", s)
+	}
+	for _, want := range []string{"suspended", "dead"} {
+		ls.GetField(1, "resume")
+		ls.PushValue(3)
+		ls.Call(1, 1)
+		if !ls.ToBoolean(-1) {
+			t.Error("resume should succeed")
+		}
+		ls.Pop(1)
+		if s := status(); s != want {
+			t.Errorf("status err, want %s, ret %q", want, s)
+		}
+	}
+
+	ls.GetField(1, "resume")
+	ls.PushValue(3)
+	ls.Call(1, 2)
+	if ls.ToBoolean(-2) || ls.ToString(-1) != "cannot resume dead coroutine" {
+		t.Errorf("resume dead err, ret %q", ls.ToString(-1))
+	}
+}
+
+func TestCoroutineWrap(t *testing.T) {
+	ls := state.New()
+	OpenCoroutineLib(ls)
+	ls.GetField(1, "wrap")
+	ls.PushGoFunction(func(ls LuaStateI) int {
+		for i := int64(1); ; i++ {
+			ls.PushInteger(i)
+			ls.Yield(1)
+		}
+	})
+	ls.Call(1, 1)
+
+	for i := int64(1); i <= 3; i++ {
+		ls.PushValue(-1)
+		ls.Call(0, 1)
+		if ret := ls.ToInteger(-1); ret != i {
+			t.Errorf("wrap err, want %v, ret %v", i, ret)
+		}
+		ls.Pop(1)
+	}
+}
+
+func TestCoroutineClose(t *testing.T) {
+	ls := state.New()
+	OpenCoroutineLib(ls)
+	ls.SetGlobal("coroutine")
+	ls.Register("fail", func(ls LuaStateI) int {
+		ls.PushString("boom")
+		return ls.Error()
+	})
+	err := ls.DoString(`
+		local co = coroutine.create(function() coroutine.yield(1) end)
+		coroutine.resume(co)
+		local ok = coroutine.close(co)
+		local bad = coroutine.create(function() fail() end)
+		coroutine.resume(bad)
+		return ok, coroutine.status(co), coroutine.close(bad)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ls.ToBoolean(1) || ls.ToString(2) != "dead" || ls.ToBoolean(3) || ls.ToString(4) != "boom" {
+		t.Errorf("close err, ret %v %v %v %v", ls.ToBoolean(1), ls.ToString(2), ls.ToBoolean(3), ls.ToString(4))
+	}
+
+	// 正在运行的协程不能关闭
+	err = ls.DoString(`
+		local co
+		co = coroutine.create(function() return coroutine.close(co) end)
+		return coroutine.resume(co)
+	`)
+	if err != nil || ls.ToBoolean(-2) || ls.ToString(-1) != "cannot close a running coroutine" {
+		t.Errorf("close running err, ret %v %q", err, ls.ToString(-1))
+	}
+}
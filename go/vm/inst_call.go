@@ -0,0 +1,119 @@
+package vm
+
+import . "github.com/anccy/luago/go/api"
+
+// R(A) := closure(KPROTO[Bx])
+func closure(i Instruction, vm LuaVM) {
+	a, bx := i.ABx()
+	a += 1
+	vm.LoadProto(bx)
+	vm.Replace(a)
+}
+
+// R(A), R(A+1), ..., R(A+B-2) = vararg
+func vararg(i Instruction, vm LuaVM) {
+	a, b, _ := i.ABC()
+	a += 1
+	if b != 1 { // b==0 or b>1
+		vm.LoadVararg(b - 1)
+		_popResults(a, b, vm)
+	}
+}
+
+// R(A), ... ,R(A+C-2) := R(A)(R(A+1), ... ,R(A+B-1))
+func call(i Instruction, vm LuaVM) {
+	a, b, c := i.ABC()
+	a += 1
+	nArgs := _pushFuncAndArgs(a, b, vm)
+	vm.Call(nArgs, c-1)
+	_popResults(a, c, vm)
+}
+
+// return R(A)(R(A+1), ... ,R(A+B-1))
+func tailCall(i Instruction, vm LuaVM) {
+	a, b, _ := i.ABC()
+	a += 1
+	nArgs := _pushFuncAndArgs(a, b, vm)
+	// 被调函数是 Lua 函数时复用当前调用帧，接着执行的是被调函数，后面的 RETURN 不再执行；
+	// 否则返回值全部留在栈顶，交给紧跟着的 RETURN
+	if !vm.TailCall(nArgs) {
+		_popResults(a, 0, vm)
+	}
+}
+
+// return R(A), ... ,R(A+B-2)
+func _return(i Instruction, vm LuaVM) {
+	a, b, _ := i.ABC()
+	a += 1
+	if b == 1 {
+		// no return values
+	} else if b > 1 {
+		vm.CheckStack(b - 1)
+		for i := a; i <= a+b-2; i++ {
+			vm.PushValue(i)
+		}
+	} else {
+		_fixStack(a, vm)
+	}
+}
+
+// R(A+3), ... ,R(A+2+C) := R(A)(R(A+1), R(A+2));
+func tForCall(i Instruction, vm LuaVM) {
+	a, _, c := i.ABC()
+	a += 1
+	_pushFuncAndArgs(a, 3, vm)
+	vm.Call(2, c)
+	_popResults(a+3, c+1, vm)
+}
+
+// b 为 0 时参数个数由前一条 CALL/VARARG 留在栈顶的值决定
+func _pushFuncAndArgs(a, b int, vm LuaVM) (nArgs int) {
+	if b >= 1 {
+		vm.CheckStack(b)
+		for i := a; i < a+b; i++ {
+			vm.PushValue(i)
+		}
+		return b - 1
+	}
+	_fixStack(a, vm)
+	return vm.GetTop() - vm.RegisterCount() - 1
+}
+
+// 把 R(A) 到上一次多返回值之前的寄存器推入栈顶，并旋转到多返回值之前
+func _fixStack(a int, vm LuaVM) {
+	x := _popOpenStart(a, vm)
+
+	vm.CheckStack(x - a)
+	for i := a; i < x; i++ {
+		vm.PushValue(i)
+	}
+	vm.Rotate(vm.RegisterCount()+1, x-a)
+}
+
+// 弹出前一条 CALL/VARARG 在栈顶压入的起始寄存器。
+// 没有通过 Verify 的 chunk 里它可能不存在或者越界，这时抛出错误而不是访问越界
+func _popOpenStart(a int, vm LuaVM) int {
+	if vm.GetTop() <= vm.RegisterCount() || !vm.IsInteger(-1) {
+		vm.RunError("invalid open results")
+	}
+	x := vm.ToInteger(-1)
+	vm.Pop(1)
+	if x < int64(a) || x > int64(vm.GetTop()) {
+		vm.RunError("invalid open results")
+	}
+	return int(x)
+}
+
+// c 为 0 时返回值全部留在栈顶，并压入 a 记录它们应该从哪个寄存器开始
+func _popResults(a, c int, vm LuaVM) {
+	if c == 1 {
+		// no results
+	} else if c > 1 {
+		for i := a + c - 2; i >= a; i-- {
+			vm.Replace(i)
+		}
+	} else {
+		vm.CheckStack(1)
+		vm.PushInteger(int64(a))
+	}
+}
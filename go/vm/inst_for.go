@@ -0,0 +1,74 @@
+package vm
+
+import . "github.com/anccy/luago/go/api"
+
+// R(A) -= R(A+2); pc += sBx
+// 和 lua 5.3 一样，字符串按 tonumber 转换成数字；初值和步长都是整数时做整数循环，
+// 否则三个值都转换成浮点数。步长为 0 不是错误
+func forPrep(i Instruction, vm LuaVM) {
+	a, sBx := i.AsBx()
+	a += 1
+
+	if vm.IsInteger(a) && vm.IsInteger(a+2) {
+		_forNumber(a+1, "limit", vm)
+	} else {
+		_forFloat(a+1, "limit", vm)
+		_forFloat(a+2, "step", vm)
+		_forFloat(a, "initial value", vm)
+	}
+
+	vm.PushValue(a)
+	vm.PushValue(a + 2)
+	vm.Arith(LUA_OPSUB)
+	vm.Replace(a)
+	vm.AddPC(sBx)
+}
+
+// 把 R(idx) 中的字符串转换成数字，不能转换时报错
+func _forNumber(idx int, what string, vm LuaVM) {
+	switch vm.Type(idx) {
+	case LUA_TNUMBER:
+	case LUA_TSTRING:
+		if vm.StringToNumber(vm.ToString(idx)) != 0 {
+			vm.Replace(idx)
+			return
+		}
+		fallthrough
+	default:
+		vm.RunError("'for' " + what + " must be a number")
+	}
+}
+
+func _forFloat(idx int, what string, vm LuaVM) {
+	_forNumber(idx, what, vm)
+	vm.PushNumber(vm.ToNumber(idx))
+	vm.Replace(idx)
+}
+
+// R(A) += R(A+2); if R(A) <?= R(A+1) then { pc += sBx; R(A+3) = R(A) }
+func forLoop(i Instruction, vm LuaVM) {
+	a, sBx := i.AsBx()
+	a += 1
+
+	vm.PushValue(a + 2)
+	vm.PushValue(a)
+	vm.Arith(LUA_OPADD)
+	vm.Replace(a)
+
+	isPositiveStep := vm.ToNumber(a+2) >= 0
+	if isPositiveStep && vm.Compare(a, a+1, LUA_OPLE) ||
+		!isPositiveStep && vm.Compare(a+1, a, LUA_OPLE) {
+		vm.AddPC(sBx)
+		vm.Copy(a, a+3)
+	}
+}
+
+// if R(A+1) ~= nil then { R(A) = R(A+1); pc += sBx }
+func tForLoop(i Instruction, vm LuaVM) {
+	a, sBx := i.AsBx()
+	a += 1
+	if !vm.IsNil(a + 1) {
+		vm.Copy(a+1, a)
+		vm.AddPC(sBx)
+	}
+}
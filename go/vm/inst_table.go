@@ -0,0 +1,79 @@
+package vm
+
+import . "github.com/anccy/luago/go/api"
+
+// number of list items to accumulate before a SETLIST instruction
+const LFIELDS_PER_FLUSH = 50
+
+// R(A) := {} (size = B,C)
+func newTable(i Instruction, vm LuaVM) {
+	a, b, c := i.ABC()
+	a += 1
+	vm.CreateTable(Fb2int(b), Fb2int(c))
+	vm.Replace(a)
+}
+
+// R(A) := R(B)[RK(C)]
+func getTable(i Instruction, vm LuaVM) {
+	a, b, c := i.ABC()
+	a += 1
+	b += 1
+	vm.GetRK(c)
+	vm.GetTable(b)
+	vm.Replace(a)
+}
+
+// R(A)[RK(B)] := RK(C)
+func setTable(i Instruction, vm LuaVM) {
+	a, b, c := i.ABC()
+	a += 1
+	vm.GetRK(b)
+	vm.GetRK(c)
+	vm.SetTable(a)
+}
+
+// R(A)[(C-1)*FPF+i] := R(A+i), 1 <= i <= B
+func setList(i Instruction, vm LuaVM) {
+	a, b, c := i.ABC()
+	a += 1
+
+	if c > 0 {
+		c = c - 1
+	} else {
+		c = Instruction(vm.Fetch()).Ax()
+	}
+
+	// b 为 0 时表示一直到栈顶，前面的 CALL/VARARG 在栈顶留下了起始寄存器
+	bIsZero := b == 0
+	if bIsZero {
+		b = _popOpenStart(a, vm) - a - 1
+	}
+
+	vm.CheckStack(1)
+	idx := int64(c * LFIELDS_PER_FLUSH)
+	for j := 1; j <= b; j++ {
+		idx++
+		vm.PushValue(a + j)
+		vm.SetI(a, idx)
+	}
+
+	if bIsZero {
+		for j := vm.RegisterCount() + 1; j <= vm.GetTop(); j++ {
+			idx++
+			vm.PushValue(j)
+			vm.SetI(a, idx)
+		}
+		vm.SetTop(vm.RegisterCount())
+	}
+}
+
+// R(A+1) := R(B); R(A) := R(B)[RK(C)]
+func self(i Instruction, vm LuaVM) {
+	a, b, c := i.ABC()
+	a += 1
+	b += 1
+	vm.Copy(b, a+1)
+	vm.GetRK(c)
+	vm.GetTable(b)
+	vm.Replace(a)
+}
@@ -0,0 +1,40 @@
+package vm
+
+import . "github.com/anccy/luago/go/api"
+
+// R(A) := UpValue[B]
+func getUpval(i Instruction, vm LuaVM) {
+	a, b, _ := i.ABC()
+	a += 1
+	vm.LoadUpvalue(b)
+	vm.Replace(a)
+}
+
+// UpValue[B] := R(A)
+func setUpval(i Instruction, vm LuaVM) {
+	a, b, _ := i.ABC()
+	a += 1
+	vm.PushValue(a)
+	vm.StoreUpvalue(b)
+}
+
+// R(A) := UpValue[B][RK(C)]
+func getTabUp(i Instruction, vm LuaVM) {
+	a, b, c := i.ABC()
+	a += 1
+	vm.LoadUpvalue(b)
+	vm.GetRK(c)
+	vm.GetTable(-2)
+	vm.Replace(a)
+	vm.Pop(1)
+}
+
+// UpValue[A][RK(B)] := RK(C)
+func setTabUp(i Instruction, vm LuaVM) {
+	a, b, c := i.ABC()
+	vm.LoadUpvalue(a)
+	vm.GetRK(b)
+	vm.GetRK(c)
+	vm.SetTable(-3)
+	vm.Pop(1)
+}
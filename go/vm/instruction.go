@@ -3,6 +3,8 @@ package vm
 import (
 	"fmt"
 	"strings"
+
+	"github.com/anccy/luago/go/api"
 )
 
 const MAXARG_Bx = 1<<18 - 1
@@ -53,6 +55,14 @@ func (self Instruction) CMode() byte {
 	return opcodes[self.Opcode()].argCMode
 }
 
+func (self Instruction) Execute(vm api.LuaVM) {
+	action := opcodes[self.Opcode()].action
+	if action == nil {
+		panic("unsupported opcode: " + self.OpName())
+	}
+	action(self, vm)
+}
+
 func (self *Instruction) String() string {
 	ret := &strings.Builder{}
 	_, _ = fmt.Fprintf(ret, "%s\t", self.OpName())
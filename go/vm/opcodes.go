@@ -1,5 +1,7 @@
 package vm
 
+import "github.com/anccy/luago/go/api"
+
 const (
 	IABC = iota
 	IABx
@@ -46,7 +48,7 @@ const (
 	OP_TESTSET
 	OP_CALL
 	OP_TAILCALL
-	OPO_RETURN
+	OP_RETURN
 	OP_FORLOOP
 	OP_FORPREP
 	OP_TFORCALL
@@ -71,54 +73,55 @@ type opcode struct {
 	argCMode byte
 	opMode   byte
 	name     string
+	action   func(i Instruction, vm api.LuaVM)
 }
 
 var opcodes = []opcode{
-	{0, 1, OpArgR, OpArgN, IABC, "MOVE"},
-	{0, 1, OpArgK, OpArgN, IABx, "LOADK"},
-	{0, 1, OpArgN, OpArgN, IABx, "LOADKX"},
-	{0, 1, OpArgU, OpArgU, IABC, "LOADBOOL"},
-	{0, 1, OpArgU, OpArgN, IABx, "LOADNIL"},
-	{0, 1, OpArgU, OpArgN, IABC, "GETUPVAL"},
-	{0, 1, OpArgU, OpArgK, IABC, "GETTABUP"},
-	{0, 1, OpArgR, OpArgK, IABC, "GETTABLE"},
-	{0, 0, OpArgK, OpArgK, IABC, "SETTABUP"},
-	{0, 0, OpArgU, OpArgN, IABC, "SETUPVAL"},
-	{0, 0, OpArgK, OpArgK, IABC, "SETTABLE"},
-	{0, 1, OpArgU, OpArgU, IABC, "NEWTABLE"},
-	{0, 1, OpArgR, OpArgK, IABC, "SELF"},
-	{0, 1, OpArgK, OpArgK, IABC, "ADD"},
-	{0, 1, OpArgK, OpArgK, IABC, "SUB "},
-	{0, 1, OpArgK, OpArgK, IABC, "MUL"},
-	{0, 1, OpArgK, OpArgK, IABC, "MOD"},
-	{0, 1, OpArgK, OpArgK, IABC, "POW"},
-	{0, 1, OpArgK, OpArgK, IABC, "DIV"},
-	{0, 1, OpArgK, OpArgK, IABC, "IDIV"},
-	{0, 1, OpArgK, OpArgK, IABC, "BAND"},
-	{0, 1, OpArgK, OpArgK, IABC, "BOR"},
-	{0, 1, OpArgK, OpArgK, IABC, "BXOR"},
-	{0, 1, OpArgK, OpArgK, IABC, "SHL"},
-	{0, 1, OpArgK, OpArgK, IABC, "SHR"},
-	{0, 1, OpArgR, OpArgN, IABC, "UNM"},
-	{0, 1, OpArgR, OpArgN, IABC, "BNOT"},
-	{0, 1, OpArgR, OpArgN, IABC, "NOT"},
-	{0, 1, OpArgR, OpArgN, IABC, "LEN"},
-	{0, 1, OpArgR, OpArgR, IABC, "CONCAT"},
-	{0, 0, OpArgR, OpArgN, IAsBx, "JMP"},
-	{1, 0, OpArgK, OpArgK, IABC, "EQ"},
-	{1, 0, OpArgK, OpArgK, IABC, "LT"},
-	{1, 0, OpArgK, OpArgK, IABC, "LE"},
-	{1, 0, OpArgN, OpArgU, IABC, "TEST"},
-	{1, 1, OpArgR, OpArgU, IABC, "TESTSET"},
-	{0, 1, OpArgU, OpArgU, IABC, "CALL"},
-	{0, 1, OpArgU, OpArgU, IABC, "TAILCALL"},
-	{0, 0, OpArgU, OpArgN, IABC, "RETURN"},
-	{0, 1, OpArgR, OpArgN, IAsBx, "FORLOOP"},
-	{0, 1, OpArgR, OpArgN, IAsBx, "FORPREP"},
-	{0, 0, OpArgN, OpArgU, IABC, "TFORCALL"},
-	{0, 1, OpArgR, OpArgN, IAsBx, "TFORLOOP"},
-	{0, 0, OpArgU, OpArgU, IABC, "SETLIST"},
-	{0, 1, OpArgU, OpArgN, IABx, "CLOSURE"},
-	{0, 1, OpArgU, OpArgN, IABC, "VARARG"},
-	{0, 0, OpArgU, OpArgU, IAx, "EXTRAARG"},
+	{0, 1, OpArgR, OpArgN, IABC, "MOVE", move},
+	{0, 1, OpArgK, OpArgN, IABx, "LOADK", loadK},
+	{0, 1, OpArgN, OpArgN, IABx, "LOADKX", loadKx},
+	{0, 1, OpArgU, OpArgU, IABC, "LOADBOOL", loadBool},
+	{0, 1, OpArgU, OpArgN, IABC, "LOADNIL", loadNil},
+	{0, 1, OpArgU, OpArgN, IABC, "GETUPVAL", getUpval},
+	{0, 1, OpArgU, OpArgK, IABC, "GETTABUP", getTabUp},
+	{0, 1, OpArgR, OpArgK, IABC, "GETTABLE", getTable},
+	{0, 0, OpArgK, OpArgK, IABC, "SETTABUP", setTabUp},
+	{0, 0, OpArgU, OpArgN, IABC, "SETUPVAL", setUpval},
+	{0, 0, OpArgK, OpArgK, IABC, "SETTABLE", setTable},
+	{0, 1, OpArgU, OpArgU, IABC, "NEWTABLE", newTable},
+	{0, 1, OpArgR, OpArgK, IABC, "SELF", self},
+	{0, 1, OpArgK, OpArgK, IABC, "ADD", add},
+	{0, 1, OpArgK, OpArgK, IABC, "SUB", sub},
+	{0, 1, OpArgK, OpArgK, IABC, "MUL", mul},
+	{0, 1, OpArgK, OpArgK, IABC, "MOD", mod},
+	{0, 1, OpArgK, OpArgK, IABC, "POW", pow},
+	{0, 1, OpArgK, OpArgK, IABC, "DIV", div},
+	{0, 1, OpArgK, OpArgK, IABC, "IDIV", idiv},
+	{0, 1, OpArgK, OpArgK, IABC, "BAND", band},
+	{0, 1, OpArgK, OpArgK, IABC, "BOR", bor},
+	{0, 1, OpArgK, OpArgK, IABC, "BXOR", bxor},
+	{0, 1, OpArgK, OpArgK, IABC, "SHL", shl},
+	{0, 1, OpArgK, OpArgK, IABC, "SHR", shr},
+	{0, 1, OpArgR, OpArgN, IABC, "UNM", unm},
+	{0, 1, OpArgR, OpArgN, IABC, "BNOT", bnot},
+	{0, 1, OpArgR, OpArgN, IABC, "NOT", not},
+	{0, 1, OpArgR, OpArgN, IABC, "LEN", length},
+	{0, 1, OpArgR, OpArgR, IABC, "CONCAT", concat},
+	{0, 0, OpArgR, OpArgN, IAsBx, "JMP", jmp},
+	{1, 0, OpArgK, OpArgK, IABC, "EQ", eq},
+	{1, 0, OpArgK, OpArgK, IABC, "LT", lt},
+	{1, 0, OpArgK, OpArgK, IABC, "LE", le},
+	{1, 0, OpArgN, OpArgU, IABC, "TEST", test},
+	{1, 1, OpArgR, OpArgU, IABC, "TESTSET", testSet},
+	{0, 1, OpArgU, OpArgU, IABC, "CALL", call},
+	{0, 1, OpArgU, OpArgU, IABC, "TAILCALL", tailCall},
+	{0, 0, OpArgU, OpArgN, IABC, "RETURN", _return},
+	{0, 1, OpArgR, OpArgN, IAsBx, "FORLOOP", forLoop},
+	{0, 1, OpArgR, OpArgN, IAsBx, "FORPREP", forPrep},
+	{0, 0, OpArgN, OpArgU, IABC, "TFORCALL", tForCall},
+	{0, 1, OpArgR, OpArgN, IAsBx, "TFORLOOP", tForLoop},
+	{0, 0, OpArgU, OpArgU, IABC, "SETLIST", setList},
+	{0, 1, OpArgU, OpArgN, IABx, "CLOSURE", closure},
+	{0, 1, OpArgU, OpArgN, IABC, "VARARG", vararg},
+	{0, 0, OpArgU, OpArgU, IAx, "EXTRAARG", nil},
 }
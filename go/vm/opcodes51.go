@@ -0,0 +1,95 @@
+package vm
+
+import "fmt"
+
+// lua 5.1: lopcodes.c
+// 5.1 的指令格式和 5.3 相同，操作码不同。这里只用来反汇编
+type opcode51 struct {
+	argBMode byte
+	argCMode byte
+	opMode   byte
+	name     string
+}
+
+var opcodes51 = []opcode51{
+	{OpArgR, OpArgN, IABC, "MOVE"},
+	{OpArgK, OpArgN, IABx, "LOADK"},
+	{OpArgU, OpArgU, IABC, "LOADBOOL"},
+	{OpArgR, OpArgN, IABC, "LOADNIL"},
+	{OpArgU, OpArgN, IABC, "GETUPVAL"},
+	{OpArgK, OpArgN, IABx, "GETGLOBAL"},
+	{OpArgR, OpArgK, IABC, "GETTABLE"},
+	{OpArgK, OpArgN, IABx, "SETGLOBAL"},
+	{OpArgU, OpArgN, IABC, "SETUPVAL"},
+	{OpArgK, OpArgK, IABC, "SETTABLE"},
+	{OpArgU, OpArgU, IABC, "NEWTABLE"},
+	{OpArgR, OpArgK, IABC, "SELF"},
+	{OpArgK, OpArgK, IABC, "ADD"},
+	{OpArgK, OpArgK, IABC, "SUB"},
+	{OpArgK, OpArgK, IABC, "MUL"},
+	{OpArgK, OpArgK, IABC, "DIV"},
+	{OpArgK, OpArgK, IABC, "MOD"},
+	{OpArgK, OpArgK, IABC, "POW"},
+	{OpArgR, OpArgN, IABC, "UNM"},
+	{OpArgR, OpArgN, IABC, "NOT"},
+	{OpArgR, OpArgN, IABC, "LEN"},
+	{OpArgR, OpArgR, IABC, "CONCAT"},
+	{OpArgR, OpArgN, IAsBx, "JMP"},
+	{OpArgK, OpArgK, IABC, "EQ"},
+	{OpArgK, OpArgK, IABC, "LT"},
+	{OpArgK, OpArgK, IABC, "LE"},
+	{OpArgR, OpArgU, IABC, "TEST"},
+	{OpArgR, OpArgU, IABC, "TESTSET"},
+	{OpArgU, OpArgU, IABC, "CALL"},
+	{OpArgU, OpArgU, IABC, "TAILCALL"},
+	{OpArgU, OpArgN, IABC, "RETURN"},
+	{OpArgR, OpArgN, IAsBx, "FORLOOP"},
+	{OpArgR, OpArgN, IAsBx, "FORPREP"},
+	{OpArgN, OpArgU, IABC, "TFORLOOP"},
+	{OpArgU, OpArgU, IABC, "SETLIST"},
+	{OpArgN, OpArgN, IABC, "CLOSE"},
+	{OpArgU, OpArgN, IABx, "CLOSURE"},
+	{OpArgU, OpArgN, IABC, "VARARG"},
+}
+
+// lua 5.1: PrintCode
+// 和 Instruction 的 String 格式相同，常量操作数显示为 -1-索引，JMP 只显示跳转偏移
+func instructionString51(c uint32) string {
+	i := Instruction(c)
+	op := i.Opcode()
+	if op >= len(opcodes51) {
+		return fmt.Sprintf("OP_%d\t", op)
+	}
+	rk := func(x int) int {
+		if x > 0xff {
+			return -1 - x&0xff
+		}
+		return x
+	}
+
+	opcode := opcodes51[op]
+	switch opcode.opMode {
+	case IABC:
+		a, b, c := i.ABC()
+		s := fmt.Sprintf("%s\t%d", opcode.name, a)
+		if opcode.argBMode != OpArgN {
+			s += fmt.Sprintf(" %d", rk(b))
+		}
+		if opcode.argCMode != OpArgN {
+			s += fmt.Sprintf(" %d", rk(c))
+		}
+		return s
+	case IABx:
+		a, bx := i.ABx()
+		if opcode.argBMode == OpArgK {
+			bx = -1 - bx
+		}
+		return fmt.Sprintf("%s\t%d %d", opcode.name, a, bx)
+	default:
+		a, sbx := i.AsBx()
+		if opcode.name == "JMP" {
+			return fmt.Sprintf("%s\t%d", opcode.name, sbx)
+		}
+		return fmt.Sprintf("%s\t%d %d", opcode.name, a, sbx)
+	}
+}
@@ -0,0 +1,22 @@
+package vm
+
+import "testing"
+
+func TestInstructionString51(t *testing.T) {
+	tests := map[uint32]string{
+		5:                           "GETGLOBAL\t0 -1",
+		1 | 1<<6 | 1<<14:            "LOADK\t1 -2",
+		28 | 2<<23 | 1<<14:          "CALL\t0 2 1",
+		30 | 1<<23:                  "RETURN\t0 1",
+		6 | 1<<6 | 0x101<<14:        "GETTABLE\t1 0 -2",
+		22 | (0x1ffff-2)<<14:        "JMP\t-2",
+		31 | 3<<6 | (0x1ffff+4)<<14: "FORLOOP\t3 4",
+		35 | 2<<6:                   "CLOSE\t2",
+		63:                          "OP_63\t",
+	}
+	for i, want := range tests {
+		if ret := instructionString51(i); ret != want {
+			t.Errorf("instruction 0x%08x err, want %q, ret %q", i, want, ret)
+		}
+	}
+}
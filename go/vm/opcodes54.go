@@ -0,0 +1,155 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lua 5.4: lopcodes.h
+// 5.4 的指令格式：操作码 7 位，A 8 位，k 1 位，B 8 位，C 8 位；
+// Bx 17 位，Ax 和 sJ 25 位。这里只用来反汇编，虚拟机执行的仍然是 5.3 的指令
+const (
+	maxArgBx54  = 1<<17 - 1
+	offsetSBx54 = maxArgBx54 >> 1
+	offsetSJ54  = 1<<24 - 1
+	offsetSC54  = 1<<7 - 1
+)
+
+// 每种操作码的操作数，按 luac -l 的顺序列出：
+// A B C 是原样的操作数，sB sC sBx sJ 是有符号数，Bx Ax 是无符号数，
+// k 是 k 标志位，Ck 表示 k 为 1 时在 C 后面加上 "k"
+type opcode54 struct {
+	name string
+	args string
+}
+
+var opcodes54 = []opcode54{
+	{"MOVE", "A B"},
+	{"LOADI", "A sBx"},
+	{"LOADF", "A sBx"},
+	{"LOADK", "A Bx"},
+	{"LOADKX", "A"},
+	{"LOADFALSE", "A"},
+	{"LFALSESKIP", "A"},
+	{"LOADTRUE", "A"},
+	{"LOADNIL", "A B"},
+	{"GETUPVAL", "A B"},
+	{"SETUPVAL", "A B"},
+	{"GETTABUP", "A B C"},
+	{"GETTABLE", "A B C"},
+	{"GETI", "A B C"},
+	{"GETFIELD", "A B C"},
+	{"SETTABUP", "A B Ck"},
+	{"SETTABLE", "A B Ck"},
+	{"SETI", "A B Ck"},
+	{"SETFIELD", "A B Ck"},
+	{"NEWTABLE", "A B C k"},
+	{"SELF", "A B Ck"},
+	{"ADDI", "A B sC"},
+	{"ADDK", "A B C"},
+	{"SUBK", "A B C"},
+	{"MULK", "A B C"},
+	{"MODK", "A B C"},
+	{"POWK", "A B C"},
+	{"DIVK", "A B C"},
+	{"IDIVK", "A B C"},
+	{"BANDK", "A B C"},
+	{"BORK", "A B C"},
+	{"BXORK", "A B C"},
+	{"SHRI", "A B sC"},
+	{"SHLI", "A B sC"},
+	{"ADD", "A B C"},
+	{"SUB", "A B C"},
+	{"MUL", "A B C"},
+	{"MOD", "A B C"},
+	{"POW", "A B C"},
+	{"DIV", "A B C"},
+	{"IDIV", "A B C"},
+	{"BAND", "A B C"},
+	{"BOR", "A B C"},
+	{"BXOR", "A B C"},
+	{"SHL", "A B C"},
+	{"SHR", "A B C"},
+	{"MMBIN", "A B C"},
+	{"MMBINI", "A sB C k"},
+	{"MMBINK", "A B C k"},
+	{"UNM", "A B"},
+	{"BNOT", "A B"},
+	{"NOT", "A B"},
+	{"LEN", "A B"},
+	{"CONCAT", "A B"},
+	{"CLOSE", "A"},
+	{"TBC", "A"},
+	{"JMP", "sJ"},
+	{"EQ", "A B k"},
+	{"LT", "A B k"},
+	{"LE", "A B k"},
+	{"EQK", "A B k"},
+	{"EQI", "A sB k"},
+	{"LTI", "A sB k"},
+	{"LEI", "A sB k"},
+	{"GTI", "A sB k"},
+	{"GEI", "A sB k"},
+	{"TEST", "A k"},
+	{"TESTSET", "A B k"},
+	{"CALL", "A B C"},
+	{"TAILCALL", "A B Ck"},
+	{"RETURN", "A B Ck"},
+	{"RETURN0", ""},
+	{"RETURN1", "A"},
+	{"FORLOOP", "A Bx"},
+	{"FORPREP", "A Bx"},
+	{"TFORPREP", "A Bx"},
+	{"TFORCALL", "A C"},
+	{"TFORLOOP", "A Bx"},
+	{"SETLIST", "A B Ck"},
+	{"CLOSURE", "A Bx"},
+	{"VARARG", "A C"},
+	{"VARARGPREP", "A"},
+	{"EXTRAARG", "Ax"},
+}
+
+// 和 Instruction 的 String 格式相同："操作码名\t操作数"
+func instructionString54(i uint32) string {
+	op := int(i & 0x7f)
+	if op >= len(opcodes54) {
+		return fmt.Sprintf("OP_%d\t", op)
+	}
+	a := int(i >> 7 & 0xff)
+	k := int(i >> 15 & 1)
+	b := int(i >> 16 & 0xff)
+	c := int(i >> 24 & 0xff)
+	bx := int(i >> 15)
+
+	args := strings.Fields(opcodes54[op].args)
+	for n, arg := range args {
+		switch arg {
+		case "A":
+			args[n] = fmt.Sprint(a)
+		case "B":
+			args[n] = fmt.Sprint(b)
+		case "C":
+			args[n] = fmt.Sprint(c)
+		case "Ck":
+			args[n] = fmt.Sprint(c)
+			if k != 0 {
+				args[n] += "k"
+			}
+		case "k":
+			args[n] = fmt.Sprint(k)
+		case "sB":
+			args[n] = fmt.Sprint(b - offsetSC54)
+		case "sC":
+			args[n] = fmt.Sprint(c - offsetSC54)
+		case "Bx":
+			args[n] = fmt.Sprint(bx)
+		case "sBx":
+			args[n] = fmt.Sprint(bx - offsetSBx54)
+		case "Ax":
+			args[n] = fmt.Sprint(int(i >> 7))
+		case "sJ":
+			args[n] = fmt.Sprint(int(i>>7) - offsetSJ54)
+		}
+	}
+	return opcodes54[op].name + "\t" + strings.Join(args, " ")
+}
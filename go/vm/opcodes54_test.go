@@ -0,0 +1,29 @@
+package vm
+
+import "testing"
+
+func iABC54(op, a, b, c, k int) uint32 {
+	return uint32(op | a<<7 | k<<15 | b<<16 | c<<24)
+}
+
+func TestInstructionString54(t *testing.T) {
+	tests := map[uint32]string{
+		iABC54(81, 0, 0, 0, 0):            "VARARGPREP\t0",
+		iABC54(11, 0, 0, 0, 0):            "GETTABUP\t0 0 0",
+		uint32(3 | 1<<7 | 1<<15):          "LOADK\t1 1",
+		iABC54(68, 0, 2, 1, 0):            "CALL\t0 2 1",
+		iABC54(71, 0, 0, 0, 0):            "RETURN0\t",
+		uint32(56 | (offsetSJ54-3)<<7):    "JMP\t-3",
+		iABC54(21, 1, 0, offsetSC54-1, 0): "ADDI\t1 0 -1",
+		iABC54(18, 0, 1, 2, 1):            "SETFIELD\t0 1 2k",
+		uint32(1 | (offsetSBx54-5)<<15):   "LOADI\t0 -5",
+		iABC54(61, 0, offsetSC54+5, 0, 1): "EQI\t0 5 1",
+		uint32(82 | 300<<7):               "EXTRAARG\t300",
+		uint32(0x7f):                      "OP_127\t",
+	}
+	for i, want := range tests {
+		if ret := instructionString54(i); ret != want {
+			t.Errorf("instruction 0x%08x err, want %q, ret %q", i, want, ret)
+		}
+	}
+}
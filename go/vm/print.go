@@ -1 +1,106 @@
 package vm
+
+import (
+	"fmt"
+
+	. "github.com/anccy/luago/go/binchunk"
+)
+
+func printHeader(f *Prototype) {
+	funcType := "main"
+	if f.LineDefined > 0 {
+		funcType = "function"
+	}
+	varargFlag := ""
+	if f.IsVararg > 0 {
+		varargFlag = "+"
+	}
+
+	// 去掉调试信息后没有源文件名，显示为 "?"
+	fmt.Printf("\n%s <%s:%d, %d> (%d instructions)\n", funcType, ChunkID(f.Source), f.LineDefined, f.LastLineDefined, len(f.Code))
+	fmt.Printf("%d%s params, %d slots, %d upvalues, ", f.NumParams, varargFlag, f.MaxStackSize, len(f.Upvalues))
+	fmt.Printf("%d locals, %d constants, %d functions\n", len(f.LocVars), len(f.Constants), len(f.Protos))
+}
+
+func printCode(f *Prototype) {
+	for pc, c := range f.Code {
+		line := "?" // 去掉调试信息后没有行号
+		if pc < len(f.LineInfo) {
+			line = fmt.Sprintf("%d", f.LineInfo[pc])
+		}
+		fmt.Printf("\t%d\t[%s]\t %v \n", pc+1, line, instructionString(f, c))
+	}
+}
+
+// 按函数原型的版本反汇编一条指令
+func instructionString(f *Prototype, c uint32) string {
+	switch f.Version {
+	case LUAC_VERSION54:
+		return instructionString54(c)
+	case LUAC_VERSION51:
+		return instructionString51(c)
+	}
+	i := Instruction(c)
+	return i.String()
+}
+
+func printDetail(f *Prototype) {
+	fmt.Printf("constants (%d):\n", len(f.Constants))
+	for i, k := range f.Constants {
+		fmt.Printf("\t%d\t%s\n", i+1, constantToString(k))
+	}
+
+	fmt.Printf("locals (%d):\n", len(f.LocVars))
+	for i, locVar := range f.LocVars {
+		fmt.Printf("\t%d\t%s\t%d\t%d\n",
+			i, locVar.VarName, locVar.StartPC+1, locVar.EndPC+1)
+	}
+
+	fmt.Printf("upvalues (%d):\n", len(f.Upvalues))
+	for i, upval := range f.Upvalues {
+		if f.Version == LUAC_VERSION51 { // 没有 upvalue 描述
+			fmt.Printf("\t%d\t%s\n", i, upvalName(f, i))
+			continue
+		}
+		if f.Version == LUAC_VERSION54 {
+			fmt.Printf("\t%d\t%s\t%d\t%d\t%d\n",
+				i, upvalName(f, i), upval.Instack, upval.Idx, upval.Kind)
+			continue
+		}
+		fmt.Printf("\t%d\t%s\t%d\t%d\n",
+			i, upvalName(f, i), upval.Instack, upval.Idx)
+	}
+}
+
+func constantToString(k interface{}) string {
+	switch k.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return fmt.Sprintf("%t", k)
+	case float64:
+		return fmt.Sprintf("%g", k)
+	case int64:
+		return fmt.Sprintf("%d", k)
+	case string:
+		return fmt.Sprintf("%q", k)
+	default:
+		return "?"
+	}
+}
+
+func upvalName(f *Prototype, idx int) string {
+	if idx < len(f.UpvalueNames) {
+		return f.UpvalueNames[idx]
+	}
+	return "-"
+}
+
+func List(f *Prototype) {
+	printHeader(f)
+	printCode(f)
+	printDetail(f)
+	for _, p := range f.Protos {
+		List(p)
+	}
+}
@@ -0,0 +1,47 @@
+package vm
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/anccy/luago/go/binchunk"
+)
+
+// 执行 f，返回它写到标准输出的内容
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	os.Stdout = stdout
+	w.Close()
+	return <-done
+}
+
+// 去掉调试信息后源文件名和行号显示为 "?"，局部变量名和 upvalue 名显示为 "-"
+func TestListStripped(t *testing.T) {
+	proto, err := ParseChunkFile("../../lua/luac.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := captureStdout(t, func() { List(Strip(proto)) })
+
+	for _, want := range []string{"main <?:0,", "\t1\t[?]\t ", "\t0\t-\t1\t0\n"} {
+		if !strings.Contains(ret, want) {
+			t.Errorf("list stripped err, want %q in\n%s", want, ret)
+		}
+	}
+	if strings.Contains(ret, "[-]") {
+		t.Errorf("list stripped err, want [?] for missing lines, ret\n%s", ret)
+	}
+}
@@ -0,0 +1,352 @@
+package vm
+
+import (
+	"fmt"
+
+	. "github.com/anccy/luago/go/binchunk"
+)
+
+// NEWTABLE 的数组和哈希部分的大小提示不能超过这个值，
+// 避免不可信的 chunk 用一条指令分配几十 GB 的内存
+const MAXTABLESIZEHINT = 1 << 24
+
+// VerifyError 是 Verify 发现的第一处错误
+type VerifyError struct {
+	Source      string
+	LineDefined uint32
+	PC          int // 出错的指令下标，-1 表示和具体指令无关
+	Msg         string
+}
+
+func (self *VerifyError) Error() string {
+	if self.PC < 0 {
+		return fmt.Sprintf("invalid function <%s:%d>: %s", ChunkID(self.Source), self.LineDefined, self.Msg)
+	}
+	return fmt.Sprintf("invalid function <%s:%d> at instruction %d: %s",
+		ChunkID(self.Source), self.LineDefined, self.PC+1, self.Msg)
+}
+
+type verifier struct {
+	proto *Prototype
+	pc    int
+}
+
+func (self *verifier) error(format string, a ...interface{}) {
+	panic(&VerifyError{
+		Source:      self.proto.Source,
+		LineDefined: self.proto.LineDefined,
+		PC:          self.pc,
+		Msg:         fmt.Sprintf(format, a...),
+	})
+}
+
+// Verify 检查函数原型（包括嵌套的子函数）中的每一条指令，
+// 保证寄存器、常量、upvalue、子函数的下标和跳转目标都不越界，
+// CALL、VARARG 等指令留在栈顶的不定个数的值紧接着被下一条指令使用，
+// 虚拟机执行不可信的二进制 chunk 之前应该先调用它
+func Verify(proto *Prototype) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(*VerifyError); ok {
+				err = e
+				return
+			}
+			panic(r)
+		}
+	}()
+	verify(proto)
+	return nil
+}
+
+func verify(proto *Prototype) {
+	v := &verifier{proto: proto, pc: -1}
+	v.checkProto()
+	for v.pc = 0; v.pc < len(proto.Code); v.pc++ {
+		v.checkInstruction(Instruction(proto.Code[v.pc]))
+	}
+	for _, p := range proto.Protos {
+		verify(p)
+	}
+}
+
+// 和指令无关的检查
+func (self *verifier) checkProto() {
+	f := self.proto
+	if f.Version != 0 && f.Version != LUAC_VERSION {
+		self.error("version 0x%x is not supported", f.Version)
+	}
+	if len(f.Code) == 0 {
+		self.error("no code")
+	}
+	if int(f.NumParams) > int(f.MaxStackSize) {
+		self.error("%d params exceed %d slots", f.NumParams, f.MaxStackSize)
+	}
+	// 调试信息可以被去掉，但是不能只有一部分
+	if len(f.LineInfo) != 0 && len(f.LineInfo) != len(f.Code) {
+		self.error("%d line infos for %d instructions", len(f.LineInfo), len(f.Code))
+	}
+	if len(f.UpvalueNames) != 0 && len(f.UpvalueNames) != len(f.Upvalues) {
+		self.error("%d upvalue names for %d upvalues", len(f.UpvalueNames), len(f.Upvalues))
+	}
+	for _, locVar := range f.LocVars {
+		if locVar.StartPC > locVar.EndPC || int(locVar.EndPC) > len(f.Code) {
+			self.error("local '%s' has invalid range [%d, %d)", locVar.VarName, locVar.StartPC, locVar.EndPC)
+		}
+	}
+}
+
+func (self *verifier) checkInstruction(i Instruction) {
+	op := i.Opcode()
+	if op >= len(opcodes) {
+		self.error("invalid opcode %d", op)
+	}
+
+	switch i.OpMode() {
+	case IABC:
+		a, b, c := i.ABC()
+		self.checkA(op, a)
+		self.checkArg(i.BMode(), b)
+		self.checkArg(i.CMode(), c)
+		self.checkABC(op, a, b, c)
+		self.checkOpenResults(i)
+	case IABx:
+		a, bx := i.ABx()
+		self.checkReg(a)
+		self.checkABx(op, bx)
+	case IAsBx:
+		a, sbx := i.AsBx()
+		self.checkA(op, a)
+		self.checkJump(sbx)
+		switch op {
+		case OP_FORLOOP, OP_FORPREP:
+			self.checkReg(a + 3)
+		case OP_TFORLOOP:
+			self.checkReg(a + 1)
+		}
+	case IAx: // EXTRAARG 只能跟在 LOADKX 和 SETLIST 后面，检查前面的指令时已经跳过了
+		self.error("unexpected EXTRAARG")
+	}
+
+	switch op {
+	case OP_RETURN, OP_JMP:
+	case OP_EQ, OP_LT, OP_LE, OP_TEST, OP_TESTSET:
+		self.checkNext(2)
+	case OP_LOADBOOL:
+		if _, _, c := i.ABC(); c != 0 {
+			self.checkNext(2)
+		} else {
+			self.checkNext(1)
+		}
+	default:
+		self.checkNext(1)
+	}
+}
+
+// A 通常是寄存器
+func (self *verifier) checkA(op, a int) {
+	switch op {
+	case OP_EQ, OP_LT, OP_LE: // 比较结果的期望值
+	case OP_SETTABUP:
+		self.checkUpval(a)
+	case OP_JMP: // 不为 0 时关闭 R(A-1) 及以上的 upvalue
+		if a != 0 {
+			self.checkReg(a - 1)
+		}
+	case OP_RETURN: // RETURN 0 1 不使用寄存器
+	default:
+		self.checkReg(a)
+	}
+}
+
+func (self *verifier) checkArg(mode byte, x int) {
+	switch mode {
+	case OpArgR:
+		self.checkReg(x)
+	case OpArgK:
+		self.checkRK(x)
+	}
+}
+
+// OpArgU 的操作数按指令各自的含义检查
+func (self *verifier) checkABC(op, a, b, c int) {
+	switch op {
+	case OP_LOADNIL:
+		self.checkReg(a + b)
+	case OP_GETUPVAL, OP_SETUPVAL, OP_GETTABUP:
+		self.checkUpval(b)
+	case OP_SELF:
+		self.checkReg(a + 1)
+	case OP_CONCAT:
+		if b > c {
+			self.error("concat range %d..%d is empty", b, c)
+		}
+	case OP_CALL, OP_TAILCALL:
+		if b != 0 { // 参数
+			self.checkReg(a + b - 1)
+		}
+		if c > 1 { // 返回值
+			self.checkReg(a + c - 2)
+		}
+	case OP_RETURN:
+		if b != 1 {
+			self.checkReg(a)
+		}
+		if b > 1 {
+			self.checkReg(a + b - 2)
+		}
+	case OP_VARARG:
+		if b > 1 {
+			self.checkReg(a + b - 2)
+		}
+	case OP_TFORCALL:
+		self.checkReg(a + 2)
+		self.checkReg(a + 2 + c)
+	case OP_NEWTABLE: // 大小只是提示，但是会按它分配内存
+		if b > 0xff || Fb2int(b) > MAXTABLESIZEHINT || c > 0xff || Fb2int(c) > MAXTABLESIZEHINT {
+			self.error("table size hint %d, %d too large", b, c)
+		}
+	case OP_SETLIST:
+		if b != 0 {
+			self.checkReg(a + b)
+		}
+		if c == 0 {
+			self.checkExtraArg()
+		}
+	}
+}
+
+func (self *verifier) checkABx(op, bx int) {
+	switch op {
+	case OP_LOADK:
+		self.checkConstant(bx)
+	case OP_LOADKX:
+		self.checkConstant(self.checkExtraArg())
+	case OP_CLOSURE:
+		if bx >= len(self.proto.Protos) {
+			self.error("function index %d out of range", bx)
+		}
+		self.checkClosure(self.proto.Protos[bx])
+	}
+}
+
+// 子函数的 upvalue 从当前函数的寄存器或者 upvalue 中捕获
+func (self *verifier) checkClosure(p *Prototype) {
+	for _, upval := range p.Upvalues {
+		if upval.Instack == 1 {
+			self.checkReg(int(upval.Idx))
+		} else {
+			self.checkUpval(int(upval.Idx))
+		}
+	}
+}
+
+// lua: checkopenop
+// 产生不定个数返回值的指令把它们留在栈顶，并压入第一个值所在的寄存器，
+// 下一条指令必须以 B=0 使用它们；反过来 B=0 的这几条指令只能跟在这样的指令后面，
+// 否则虚拟机会把栈顶的其他值当成寄存器下标
+func (self *verifier) checkOpenResults(i Instruction) {
+	code := self.proto.Code
+	if isOpenResults(i) {
+		if self.pc+1 >= len(code) || !usesOpenResults(Instruction(code[self.pc+1])) {
+			self.error("open results not used by the next instruction")
+		}
+		a, _, _ := i.ABC()
+		if nextA, _, _ := Instruction(code[self.pc+1]).ABC(); nextA > a {
+			self.error("open results start below register %d", nextA)
+		}
+	}
+	if usesOpenResults(i) && (self.pc == 0 || !isOpenResults(Instruction(code[self.pc-1]))) {
+		self.error("no open results to use")
+	}
+}
+
+// CALL C=0、VARARG B=0 和 TAILCALL（调用 Go 函数时）把不定个数的值留在栈顶
+func isOpenResults(i Instruction) bool {
+	_, b, c := i.ABC()
+	switch i.Opcode() {
+	case OP_CALL:
+		return c == 0
+	case OP_VARARG:
+		return b == 0
+	case OP_TAILCALL:
+		return true
+	}
+	return false
+}
+
+// B=0 的 CALL、TAILCALL、RETURN 和 SETLIST 使用栈顶不定个数的值
+func usesOpenResults(i Instruction) bool {
+	switch i.Opcode() {
+	case OP_CALL, OP_TAILCALL, OP_RETURN, OP_SETLIST:
+		_, b, _ := i.ABC()
+		return b == 0
+	}
+	return false
+}
+
+// 下一条指令必须是 EXTRAARG，检查通过后跳过它，返回它的操作数
+func (self *verifier) checkExtraArg() int {
+	code := self.proto.Code
+	if self.pc+1 >= len(code) || Instruction(code[self.pc+1]).Opcode() != OP_EXTRAARG {
+		self.error("missing EXTRAARG")
+	}
+	self.pc++
+	return Instruction(code[self.pc]).Ax()
+}
+
+func (self *verifier) checkJump(sbx int) {
+	target := self.pc + 1 + sbx
+	if target < 0 || target >= len(self.proto.Code) {
+		self.error("jump target %d out of range", target+1)
+	}
+	self.checkTarget(target)
+}
+
+// 执行完当前指令后，后面的 n 条指令中最后一条可能被执行，不能越过函数的末尾
+func (self *verifier) checkNext(n int) {
+	if self.pc+n >= len(self.proto.Code) {
+		self.error("control flows off the end of the function")
+	}
+	if n > 1 {
+		self.checkTarget(self.pc + n)
+	}
+}
+
+// 跳转和跳过下一条指令的目标不能是 LOADKX、SETLIST 的操作数，也就是 EXTRAARG，
+// EXTRAARG 没有对应的 action，执行时会 panic；
+// 也不能是使用栈顶不定个数的值的指令，跳过去的时候栈顶没有这些值
+func (self *verifier) checkTarget(target int) {
+	i := Instruction(self.proto.Code[target])
+	if i.Opcode() == OP_EXTRAARG {
+		self.error("jump into the operand of instruction %d", target)
+	}
+	if usesOpenResults(i) {
+		self.error("jump to instruction %d that uses open results", target+1)
+	}
+}
+
+func (self *verifier) checkReg(r int) {
+	if r >= int(self.proto.MaxStackSize) {
+		self.error("register %d out of range", r)
+	}
+}
+
+func (self *verifier) checkRK(rk int) {
+	if rk > 0xff {
+		self.checkConstant(rk & 0xff)
+	} else {
+		self.checkReg(rk)
+	}
+}
+
+func (self *verifier) checkConstant(idx int) {
+	if idx >= len(self.proto.Constants) {
+		self.error("constant index %d out of range", idx)
+	}
+}
+
+func (self *verifier) checkUpval(idx int) {
+	if idx >= len(self.proto.Upvalues) {
+		self.error("upvalue index %d out of range", idx)
+	}
+}
@@ -0,0 +1,120 @@
+package vm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/anccy/luago/go/binchunk"
+)
+
+func iABC(op, a, b, c int) uint32 {
+	return uint32(op | a<<6 | c<<14 | b<<23)
+}
+
+func iABx(op, a, bx int) uint32 {
+	return uint32(op | a<<6 | bx<<14)
+}
+
+func iAsBx(op, a, sbx int) uint32 {
+	return iABx(op, a, sbx+MAXARG_sBx)
+}
+
+func iAx(op, ax int) uint32 {
+	return uint32(op | ax<<6)
+}
+
+// luac.out 和编译器生成的代码都能通过检查
+func TestVerifyLuacOut(t *testing.T) {
+	proto, err := ParseChunkFile("../../lua/luac.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(proto); err != nil {
+		t.Errorf("verify luac.out err: %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ret := iABC(OP_RETURN, 0, 1, 0)
+	tests := []struct {
+		proto *Prototype
+		want  string // 为空表示通过检查
+	}{
+		{&Prototype{MaxStackSize: 2, Code: []uint32{ret}}, ""},
+		{&Prototype{MaxStackSize: 2}, "no code"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_MOVE, 0, 1, 0)}}, "control flows off the end"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_MOVE, 0, 2, 0), ret}}, "register 2 out of range"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_ADD, 0, 1, 0x100), ret}}, "constant index 0 out of range"},
+		{&Prototype{MaxStackSize: 2, Constants: []interface{}{int64(1)},
+			Code: []uint32{iABC(OP_ADD, 0, 1, 0x100), ret}}, ""},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABx(OP_LOADK, 0, 0), ret}}, "constant index 0 out of range"},
+		{&Prototype{MaxStackSize: 2, Constants: []interface{}{"x"},
+			Code: []uint32{iABx(OP_LOADKX, 0, 0), iAx(OP_EXTRAARG, 0), ret}}, ""},
+		{&Prototype{MaxStackSize: 2, Constants: []interface{}{"x"},
+			Code: []uint32{iABx(OP_LOADKX, 0, 0), ret}}, "missing EXTRAARG"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iAx(OP_EXTRAARG, 0), ret}}, "unexpected EXTRAARG"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_GETUPVAL, 0, 0, 0), ret}}, "upvalue index 0 out of range"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iAsBx(OP_JMP, 0, 1), ret}}, "jump target 3 out of range"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iAsBx(OP_JMP, 0, -2), ret}}, "jump target 0 out of range"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_EQ, 1, 0, 1), ret}}, "control flows off the end"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_NEWTABLE, 0, 0x10c, 0), ret}}, "table size hint 268, 0 too large"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_NEWTABLE, 0, 0, 0xbf), ret}}, "table size hint 0, 191 too large"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_NEWTABLE, 0, 0xaf, 0xaf), ret}}, ""}, // 15 << 20
+		// 跳到 EXTRAARG 上
+		{&Prototype{MaxStackSize: 2, Constants: []interface{}{"x"},
+			Code: []uint32{iAsBx(OP_JMP, 0, 1), iABx(OP_LOADKX, 0, 0), iAx(OP_EXTRAARG, 0), ret}},
+			"jump into the operand of instruction 2"},
+		{&Prototype{MaxStackSize: 2,
+			Code: []uint32{iABC(OP_SETLIST, 0, 1, 0), iAx(OP_EXTRAARG, 0), iAsBx(OP_JMP, 0, -2), ret}},
+			"jump into the operand of instruction 1"},
+		{&Prototype{MaxStackSize: 2, Constants: []interface{}{"x"},
+			Code: []uint32{iABC(OP_TEST, 0, 0, 0), iABx(OP_LOADKX, 0, 0), iAx(OP_EXTRAARG, 0), ret}},
+			"jump into the operand of instruction 2"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_CALL, 0, 3, 1), ret}}, "register 2 out of range"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABC(OP_LOADNIL, 0, 2, 0), ret}}, "register 2 out of range"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABx(OP_CLOSURE, 0, 0), ret}}, "function index 0 out of range"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{63, ret}}, "invalid opcode 63"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{ret}, LineInfo: []uint32{1, 2}}, "2 line infos for 1 instructions"},
+		{&Prototype{MaxStackSize: 2, Code: []uint32{ret}, Version: LUAC_VERSION54}, "version 0x54 is not supported"},
+		// 使用栈顶不定个数的值，但是前面没有指令产生它们
+		{&Prototype{Source: "@poc", MaxStackSize: 6, Code: []uint32{iABC(OP_RETURN, 2, 0, 0), ret}},
+			"instruction 1: no open results to use"},
+		{&Prototype{MaxStackSize: 6, Code: []uint32{iABC(OP_TAILCALL, 3, 0, 2), iABC(OP_RETURN, 3, 0, 0), ret}},
+			"instruction 1: no open results to use"},
+		{&Prototype{MaxStackSize: 6, Code: []uint32{iABC(OP_CALL, 2, 0, 1), ret}}, "no open results to use"},
+		{&Prototype{MaxStackSize: 6, Code: []uint32{iABC(OP_NEWTABLE, 0, 0, 0), iABC(OP_SETLIST, 0, 0, 1), ret}},
+			"no open results to use"},
+		{&Prototype{MaxStackSize: 6, Code: []uint32{iABC(OP_VARARG, 1, 0, 0), iABC(OP_MOVE, 0, 1, 0), ret}},
+			"open results not used by the next instruction"},
+		{&Prototype{MaxStackSize: 6, Code: []uint32{iABC(OP_VARARG, 1, 0, 0), iABC(OP_RETURN, 2, 0, 0), ret}},
+			"open results start below register 2"},
+		{&Prototype{MaxStackSize: 6, Code: []uint32{iABC(OP_VARARG, 1, 0, 0), iABC(OP_RETURN, 1, 0, 0)}}, ""},
+		{&Prototype{MaxStackSize: 6, Code: []uint32{iABC(OP_VARARG, 1, 0, 0), iABC(OP_CALL, 0, 0, 0), iABC(OP_RETURN, 0, 0, 0)}}, ""},
+		{&Prototype{MaxStackSize: 6, Code: []uint32{iABC(OP_CALL, 0, 1, 1), iAsBx(OP_JMP, 0, 0), iABC(OP_RETURN, 0, 0, 0)}},
+			"jump to instruction 3 that uses open results"},
+		{&Prototype{MaxStackSize: 6, Code: []uint32{iABC(OP_CONCAT, 0, 2, 1), ret}}, "concat range 2..1 is empty"},
+		// 子函数的 upvalue 越界
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABx(OP_CLOSURE, 0, 0), ret},
+			Protos: []*Prototype{{MaxStackSize: 2, Code: []uint32{ret}, Upvalues: []Upvalue{{Instack: 0, Idx: 0}}}}},
+			"upvalue index 0 out of range"},
+		// 子函数本身的错误
+		{&Prototype{MaxStackSize: 2, Code: []uint32{iABx(OP_CLOSURE, 0, 0), ret},
+			Protos: []*Prototype{{Source: "@f.lua", LineDefined: 3, MaxStackSize: 2,
+				Code: []uint32{iABC(OP_MOVE, 0, 5, 0), ret}}}},
+			"invalid function <f.lua:3> at instruction 1: register 5 out of range"},
+	}
+	for _, test := range tests {
+		err := Verify(test.proto)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("verify %v err, want nil, ret %v", test.proto.Code, err)
+			}
+			continue
+		}
+		var verifyErr *VerifyError
+		if !errors.As(err, &verifyErr) || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("verify %v err, want %q, ret %v", test.proto.Code, test.want, err)
+		}
+	}
+}
@@ -1,48 +1,138 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+
 	"github.com/anccy/luago/go/api"
+	"github.com/anccy/luago/go/binchunk"
 	"github.com/anccy/luago/go/state"
+	"github.com/anccy/luago/go/stdlib"
+	"github.com/anccy/luago/go/vm"
+)
+
+var (
+	list   = flag.Bool("l", false, "list the bytecode instead of running it")
+	strip  = flag.Bool("s", false, "strip debug information")
+	output = flag.String("o", "", "write the binary chunk to `file` instead of running it")
 )
 
 func main() {
+	flag.Parse()
+	path := "./lua/luac.out"
+	if flag.NArg() > 0 {
+		path = flag.Arg(0)
+	}
 	ls := state.New()
+	if err := ls.LoadFile(path); err != nil {
+		fatal(err)
+	}
+	if *strip || *list || *output != "" {
+		if compile(ls) {
+			return
+		}
+	}
 
-	ls.PushBoolean(true)
-	printStack(ls)
-	ls.PushInteger(10)
-	printStack(ls)
-	ls.PushNil()
-	printStack(ls)
-	ls.PushString("hello")
-	printStack(ls)
-	ls.PushValue(-4)
-	printStack(ls)
-	ls.Replace(3)
-	printStack(ls)
-	ls.SetTop(6)
-	printStack(ls)
-	ls.Remove(-3)
-	printStack(ls)
-	ls.SetTop(-5)
-	printStack(ls)
+	ls.PushGlobalTable()
+	ls.SetGlobal("_G")
+	ls.Register("print", print)
+	ls.Register("tostring", tostring)
+	ls.Register("getmetatable", getMetatable)
+	ls.Register("setmetatable", setMetatable)
+	stdlib.OpenCoroutineLib(ls)
+	ls.SetGlobal("coroutine")
+
+	if err := ls.ProtectedCall(0, 0); err != nil {
+		fmt.Fprintln(os.Stderr, "luago:", err)
+		var luaErr *state.LuaError
+		if errors.As(err, &luaErr) {
+			fmt.Fprintln(os.Stderr, luaErr.Traceback)
+		}
+		os.Exit(1)
+	}
 }
 
-func printStack(ls *state.LuaState) {
-	top := ls.GetTop()
-	for i := 1; i <= top; i++ {
-		t := ls.Type(i)
-		switch t {
-		case api.LUA_TBOOLEAN:
-			fmt.Printf("[%t]", ls.ToBoolean(i))
-		case api.LUA_TNUMBER:
-			fmt.Printf("[%g]", ls.ToNumber(i))
-		case api.LUA_TSTRING:
-			fmt.Printf("[%q]", ls.ToString(i))
-		default: // other values
-			fmt.Printf("[%s]", ls.TypeName(t))
+// 和 luac 一样处理 -l、-s 和 -o：把栈顶的函数重新写成二进制 chunk，
+// 列出指令或者写入文件；只有 -s 时换成去掉调试信息的函数继续执行，返回 false
+func compile(ls *state.LuaState) bool {
+	buf := &bytes.Buffer{}
+	if err := ls.Dump(buf, *strip); err != nil {
+		fatal(err)
+	}
+	if *list {
+		proto, err := binchunk.ParseChunk(buf.Bytes())
+		if err != nil {
+			fatal(err)
+		}
+		vm.List(proto)
+	}
+	if *output != "" {
+		if err := os.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+			fatal(err)
 		}
 	}
+	if *list || *output != "" {
+		return true
+	}
+
+	ls.Pop(1)
+	if status := ls.Load(buf.Bytes(), "=?", "b"); status != api.LUA_OK {
+		fatal(errors.New(ls.ToString(-1)))
+	}
+	return false
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "luago:", err)
+	os.Exit(1)
+}
+
+func print(ls api.LuaStateI) int {
+	nArgs := ls.GetTop()
+	for i := 1; i <= nArgs; i++ {
+		if i > 1 {
+			fmt.Print("\t")
+		}
+		fmt.Print(ls.ToString2(i))
+		ls.Pop(1)
+	}
 	fmt.Println()
+	return 0
+}
+
+func tostring(ls api.LuaStateI) int {
+	ls.ToString2(1)
+	return 1
+}
+
+// 元表有 __metatable 字段时返回该字段
+func getMetatable(ls api.LuaStateI) int {
+	if !ls.GetMetatable(1) {
+		ls.PushNil()
+		return 1
+	}
+	if ls.GetField(-1, "__metatable") == api.LUA_TNIL {
+		ls.Pop(1)
+	}
+	return 1
+}
+
+func setMetatable(ls api.LuaStateI) int {
+	if !ls.IsNil(2) && !ls.IsTable(2) {
+		ls.PushString("bad argument #2 to 'setmetatable' (nil or table expected)")
+		return ls.Error()
+	}
+	if ls.GetMetatable(1) {
+		if ls.GetField(-1, "__metatable") != api.LUA_TNIL {
+			ls.PushString("cannot change a protected metatable")
+			return ls.Error()
+		}
+		ls.Pop(2)
+	}
+	ls.SetTop(2)
+	ls.SetMetatable(1)
+	return 1
 }